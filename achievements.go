@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Achievements are unlocked by solves and tournament results. The rules are loaded from
+// achievements.json in the data dir so new ones can be added without a release. A rule unlocks
+// when every condition it sets holds, conditions left at their zero value are ignored
+
+// achievementRule is a single declarative achievement
+type achievementRule struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Emoji       string `json:"emoji"`
+	// solve or tournament
+	Event string `json:"event"`
+	// drops a default rule with the same ID
+	Disabled bool `json:"disabled,omitempty"`
+
+	// solve conditions
+	Optimal    bool   `json:"optimal,omitempty"`    // the solution is optimal
+	Streak     int    `json:"streak,omitempty"`     // optimal solves in a row in the guild
+	Difficulty string `json:"difficulty,omitempty"` // the puzzle is at least this difficult
+	MinMoves   int    `json:"minMoves,omitempty"`   // the solution is at least this long
+	MaxRobots  int    `json:"maxRobots,omitempty"`  // the solution moves at most this many robots
+	Solved     int    `json:"solved,omitempty"`     // distinct puzzles solved
+
+	// tournament conditions
+	Place int `json:"place,omitempty"` // finished at this place or better
+}
+
+const (
+	solveEvent      = "solve"
+	tournamentEvent = "tournament"
+)
+
+func defaultAchievements() []achievementRule {
+	return []achievementRule{
+		{ID: "first-optimal", Name: "Optimizer", Description: "Find an optimal solution", Emoji: ":dart:", Event: solveEvent, Optimal: true},
+		{ID: "optimal-streak-10", Name: "Unstoppable", Description: "Find 10 optimal solutions in a row", Emoji: ":fire:", Event: solveEvent, Streak: 10},
+		{ID: "extreme", Name: "Extremist", Description: "Solve an extreme puzzle", Emoji: ":skull:", Event: solveEvent, Difficulty: EXTREME.String()},
+		{ID: "one-robot", Name: "Lone Wolf", Description: "Solve a puzzle moving only one robot", Emoji: ":wolf:", Event: solveEvent, MaxRobots: 1},
+		{ID: "seventeen", Name: "Barrier Breaker", Description: "Find an optimal solution of 17 or more moves", Emoji: ":rocket:", Event: solveEvent, Optimal: true, MinMoves: 17},
+		{ID: "tournament-win", Name: "Champion", Description: "Win a tournament", Emoji: ":trophy:", Event: tournamentEvent, Place: 1},
+	}
+}
+
+// loadAchievementRules reads the rules at path over the defaults. Rules are matched by ID so a
+// file only needs to list new or changed rules. A missing file uses the defaults
+func loadAchievementRules(path string) ([]achievementRule, error) {
+	rules := defaultAchievements()
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading achievements: %v", err)
+	}
+	var custom []achievementRule
+	if err := json.Unmarshal(buf, &custom); err != nil {
+		return nil, fmt.Errorf("parsing achievements: %v", err)
+	}
+
+	for _, rule := range custom {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid achievement %q: %v", rule.ID, err)
+		}
+		replaced := false
+		for idx := range rules {
+			if rules[idx].ID == rule.ID {
+				rules[idx] = rule
+				replaced = true
+			}
+		}
+		if !replaced {
+			rules = append(rules, rule)
+		}
+	}
+
+	enabled := rules[:0]
+	for _, rule := range rules {
+		if !rule.Disabled {
+			enabled = append(enabled, rule)
+		}
+	}
+	return enabled, nil
+}
+
+func (ar achievementRule) validate() error {
+	if ar.ID == "" {
+		return fmt.Errorf("missing id")
+	}
+	if ar.Disabled {
+		return nil
+	}
+	if ar.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if ar.Streak < 0 || ar.MinMoves < 0 || ar.MaxRobots < 0 || ar.Solved < 0 || ar.Place < 0 {
+		return fmt.Errorf("conditions can't be negative")
+	}
+	switch ar.Event {
+	case solveEvent:
+		if ar.Difficulty != "" && parseDifficulty(ar.Difficulty) == UNKNOWN {
+			return fmt.Errorf("unknown difficulty %q", ar.Difficulty)
+		}
+		if ar.Place != 0 {
+			return fmt.Errorf("place only applies to tournaments")
+		}
+	case tournamentEvent:
+		if ar.Optimal || ar.Streak != 0 || ar.Difficulty != "" || ar.MinMoves != 0 || ar.MaxRobots != 0 || ar.Solved != 0 {
+			return fmt.Errorf("solve conditions don't apply to tournaments")
+		}
+	default:
+		return fmt.Errorf("unknown event %q", ar.Event)
+	}
+	return nil
+}
+
+// achievementFacts describes the solve or tournament result rules are evaluated against
+type achievementFacts struct {
+	event string
+
+	moves   []move
+	optimal int // 0 if unknown
+	diff    difficulty
+	streak  int
+	solved  int
+
+	place int
+}
+
+// solveAchievementFacts builds the facts for the latest submission in subs, the user's full history
+func solveAchievementFacts(sub submission, subs []submission) (achievementFacts, error) {
+	moves, err := parseMoves(sub.Moves)
+	if err != nil {
+		return achievementFacts{}, fmt.Errorf("parsing moves: %v", err)
+	}
+	puzzles := make(map[string]bool)
+	for _, prev := range subs {
+		puzzles[prev.PuzzleID] = true
+	}
+	return achievementFacts{
+		event:   solveEvent,
+		moves:   moves,
+		optimal: sub.Optimal,
+		diff:    parseDifficulty(sub.Difficulty),
+		streak:  optimalStreak(subs, sub.GuildID),
+		solved:  len(puzzles),
+	}, nil
+}
+
+func robotsMoved(moves []move) int {
+	robots := make(map[byte]bool)
+	for _, m := range moves {
+		robots[m.id] = true
+	}
+	return len(robots)
+}
+
+func (ar achievementRule) matches(f achievementFacts) bool {
+	if ar.Event != f.event {
+		return false
+	}
+	if f.event == tournamentEvent {
+		return ar.Place == 0 || f.place <= ar.Place
+	}
+
+	if ar.Optimal && (f.optimal == 0 || len(f.moves) != f.optimal) {
+		return false
+	}
+	if ar.Difficulty != "" && (f.diff == UNKNOWN || f.diff < parseDifficulty(ar.Difficulty)) {
+		return false
+	}
+	if ar.MaxRobots > 0 && robotsMoved(f.moves) > ar.MaxRobots {
+		return false
+	}
+	return f.streak >= ar.Streak && len(f.moves) >= ar.MinMoves && f.solved >= ar.Solved
+}
+
+// unlock is an achievement a user has earned
+type unlock struct {
+	ID        string    `json:"id"`
+	GuildID   string    `json:"guildId"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// unlockStore is the persisted record of every user's achievements keyed by discord user ID
+type unlockStore struct {
+	path string
+
+	lock    sync.RWMutex
+	unlocks map[string][]unlock
+}
+
+// loadUnlocks reads the unlock file at path. A missing file is treated as no achievements
+func loadUnlocks(path string) (*unlockStore, error) {
+	us := &unlockStore{
+		path:    path,
+		unlocks: make(map[string][]unlock),
+	}
+
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return us, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading achievements: %v", err)
+	}
+	if err := json.Unmarshal(buf, &us.unlocks); err != nil {
+		return nil, fmt.Errorf("parsing achievements: %v", err)
+	}
+	return us, nil
+}
+
+// add records the unlock, returning false if the user already has the achievement
+func (us *unlockStore) add(userID string, u unlock) (bool, error) {
+	if us == nil {
+		return false, nil
+	}
+	us.lock.Lock()
+	defer us.lock.Unlock()
+	for _, prev := range us.unlocks[userID] {
+		if prev.ID == u.ID {
+			return false, nil
+		}
+	}
+	us.unlocks[userID] = append(us.unlocks[userID], u)
+	if us.path == "" {
+		return true, nil
+	}
+	return true, writeJSONFile(us.path, us.unlocks)
+}
+
+// forUser returns a copy of the user's unlocks, oldest first
+func (us *unlockStore) forUser(userID string) []unlock {
+	if us == nil {
+		return nil
+	}
+	us.lock.RLock()
+	defer us.lock.RUnlock()
+
+	unlocks := make([]unlock, len(us.unlocks[userID]))
+	copy(unlocks, us.unlocks[userID])
+	return unlocks
+}
+
+// achievementRules returns the server's rules, falling back to the defaults
+func (s *server) achievementRules() []achievementRule {
+	if s.achievements == nil {
+		return defaultAchievements()
+	}
+	return s.achievements
+}
+
+// unlockAchievements records every rule the facts satisfy that the user hasn't unlocked yet and
+// returns them
+func (s *server) unlockAchievements(userID, guildID string, facts achievementFacts) []achievementRule {
+	var unlocked []achievementRule
+	for _, rule := range s.achievementRules() {
+		if !rule.matches(facts) {
+			continue
+		}
+		added, err := s.unlocks.add(userID, unlock{ID: rule.ID, GuildID: guildID, Timestamp: s.clk().Now()})
+		if err != nil {
+			log.Printf("recording achievement: %v", err)
+		}
+		if added {
+			unlocked = append(unlocked, rule)
+		}
+	}
+	return unlocked
+}
+
+// solveAchievements evaluates the rules against a submission the user just made
+func (s *server) solveAchievements(sub submission) []achievementRule {
+	facts, err := solveAchievementFacts(sub, s.history.forUser(sub.UserID))
+	if err != nil {
+		log.Printf("evaluating achievements: %v", err)
+		return nil
+	}
+	return s.unlockAchievements(sub.UserID, sub.GuildID, facts)
+}
+
+// tournamentAchievements evaluates the rules against the user's tournament result
+func (s *server) tournamentAchievements(userID string, result tournamentResult) []achievementRule {
+	return s.unlockAchievements(userID, result.GuildID, achievementFacts{event: tournamentEvent, place: result.Place})
+}
+
+// unlockedAchievements returns the rules the user has unlocked, oldest first. Unlocks of rules
+// that have since been removed are skipped
+func (s *server) unlockedAchievements(userID string) []achievementRule {
+	var unlocked []achievementRule
+	for _, u := range s.unlocks.forUser(userID) {
+		for _, rule := range s.achievementRules() {
+			if rule.ID == u.ID {
+				unlocked = append(unlocked, rule)
+			}
+		}
+	}
+	return unlocked
+}
+
+func unlockContent(name string, unlocked []achievementRule) string {
+	var sb strings.Builder
+	for idx, rule := range unlocked {
+		if idx > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("%s %s unlocked **%s**: %s", rule.Emoji, name, rule.Name, rule.Description))
+	}
+	return sb.String()
+}
+
+// announceUnlocks posts the achievements a user unlocked by solving the active puzzle. During a
+// tournament they're added to the user's reply instead so no one else learns how the solve went
+func (s *server) announceUnlocks(dg messenger, i *discordgo.Interaction, instance *discordInstance, userID, reply string, unlocked []achievementRule) {
+	if len(unlocked) == 0 {
+		return
+	}
+	// unlocks give away that the user solved so they stay private like the solve itself
+	if instance.currentTournament() != nil || !instance.settings().AnnounceSolves {
+		content := reply + "\n" + unlockContent("You", unlocked)
+		if _, err := dg.InteractionResponseEdit(i, &discordgo.WebhookEdit{Content: &content}); err != nil {
+			log.Printf("showing achievements: %v", err)
+		}
+		return
+	}
+	if _, err := dg.ChannelMessageSend(i.ChannelID, unlockContent(s.playerName(userID), unlocked)); err != nil {
+		log.Printf("announcing achievements: %v", err)
+	}
+}
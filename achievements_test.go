@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAchievementRules(t *testing.T) {
+	rules := make(map[string]achievementRule)
+	for _, rule := range defaultAchievements() {
+		if err := rule.validate(); err != nil {
+			t.Fatalf("default achievement %s is invalid: %v", rule.ID, err)
+		}
+		rules[rule.ID] = rule
+	}
+
+	solve := func(moves string, optimal int, diff difficulty, streak int) achievementFacts {
+		sub := submission{Moves: moves, Optimal: optimal, Difficulty: diff.String()}
+		facts, err := solveAchievementFacts(sub, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		facts.streak = streak
+		return facts
+	}
+	long := strings.TrimSuffix(strings.Repeat("RU-BD-", 9), "-")
+
+	tests := []struct {
+		id    string
+		facts achievementFacts
+		want  bool
+	}{
+		{"first-optimal", solve("RU-BL", 2, EASY, 1), true},
+		{"first-optimal", solve("RU-BL-RD", 2, EASY, 0), false},
+		{"first-optimal", solve("RU-BL", 0, EASY, 0), false},
+		{"optimal-streak-10", solve("RU", 1, EASY, 10), true},
+		{"optimal-streak-10", solve("RU", 1, EASY, 9), false},
+		{"extreme", solve("RU", 0, EXTREME, 0), true},
+		{"extreme", solve("RU", 0, HARD, 0), false},
+		{"one-robot", solve("RU-RL-RD", 0, EASY, 0), true},
+		{"one-robot", solve("RU-BL-RD", 0, EASY, 0), false},
+		{"seventeen", solve(long, 18, EXTREME, 1), true},
+		{"seventeen", solve(long, 17, EXTREME, 0), false},
+		{"seventeen", solve("RU-BD", 2, EASY, 1), false},
+		{"tournament-win", achievementFacts{event: tournamentEvent, place: 1}, true},
+		{"tournament-win", achievementFacts{event: tournamentEvent, place: 2}, false},
+		{"tournament-win", solve("RU", 1, EASY, 1), false},
+	}
+	for _, tt := range tests {
+		if got := rules[tt.id].matches(tt.facts); got != tt.want {
+			t.Errorf("%s matched %+v: %v, expected %v", tt.id, tt.facts, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAchievementRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "achievements.json")
+	rules, err := loadAchievementRules(path)
+	if err != nil || len(rules) != len(defaultAchievements()) {
+		t.Fatalf("missing file didn't load the defaults: %v", err)
+	}
+
+	config := `[
+		{"id": "one-robot", "disabled": true},
+		{"id": "extreme", "name": "Daredevil", "description": "Solve an extreme puzzle", "event": "solve", "difficulty": "extreme"},
+		{"id": "regular", "name": "Regular", "description": "Solve 100 puzzles", "emoji": ":calendar:", "event": "solve", "solved": 100}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = loadAchievementRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]achievementRule)
+	for _, rule := range rules {
+		byID[rule.ID] = rule
+	}
+	if _, ok := byID["one-robot"]; ok {
+		t.Fatalf("disabled rule was loaded")
+	}
+	if byID["extreme"].Name != "Daredevil" || byID["regular"].Solved != 100 || len(rules) != len(defaultAchievements()) {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	for _, bad := range []string{
+		`[{"id": "x", "name": "X", "event": "login"}]`,
+		`[{"id": "x", "name": "X", "event": "tournament", "optimal": true}]`,
+		`[{"id": "x", "name": "X", "event": "solve", "difficulty": "impossible"}]`,
+		`[{"name": "X", "event": "solve"}]`,
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadAchievementRules(path); err == nil {
+			t.Fatalf("invalid rules were accepted: %s", bad)
+		}
+	}
+}
+
+func TestAchievementUnlocks(t *testing.T) {
+	puzzles := testPuzzles(t)
+	for _, g := range puzzles {
+		g.set = classicSet
+	}
+	s, fm := newTestServer(t, puzzles)
+	h, err := loadHistory(filepath.Join(t.TempDir(), "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.history = h
+	path := filepath.Join(t.TempDir(), "unlocks.json")
+	s.unlocks, err = loadUnlocks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := puzzles[0]
+
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "puzzle"))
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "solve", "moves", formatMoves(g.moves)))
+	fm.waitFor(t, testChannel, ":dart: <@alice> unlocked **Optimizer**")
+
+	// achievements are only unlocked once
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "solve", "moves", formatMoves(g.moves)))
+	fm.lock.Lock()
+	announced := 0
+	for _, msg := range fm.messages {
+		if msg.channelID == testChannel && strings.Contains(msg.content, "unlocked **Optimizer**") {
+			announced++
+		}
+	}
+	fm.lock.Unlock()
+	if announced != 1 {
+		t.Fatalf("optimizer was announced %d times", announced)
+	}
+
+	// unlocks survive a restart and show up in /stats
+	reloaded, err := loadUnlocks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unlocks := reloaded.forUser("alice"); len(unlocks) == 0 || unlocks[0].ID != "first-optimal" {
+		t.Fatalf("unlock wasn't saved: %+v", unlocks)
+	}
+	stats := command(testGuild, testChannel, "alice", "stats")
+	s.handleInteraction(fm, stats)
+	if reply := fm.reply(stats); !strings.Contains(reply, "Achievements: :dart: **Optimizer**") {
+		t.Fatalf("stats don't list achievements:\n%s", reply)
+	}
+}
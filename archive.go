@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// maxArchiveSolveDepth matches the depth used when generating puzzles
+const maxArchiveSolveDepth = 20
+
+// maxArchiveSolves limits how many old puzzles are scored at once since each can be a deep solve
+const maxArchiveSolves = 2
+
+// optimalCache memoizes the optimal solution length of old puzzles by puzzle key
+// so repeat submissions only pay for the solve once
+type optimalCache struct {
+	lock    sync.Mutex
+	lengths map[string]int
+	pending map[string]chan struct{}
+}
+
+// get returns the optimal solution length for g, solving it if it hasn't been seen before.
+// Concurrent requests for the same puzzle wait on a single solve. Returns 0 if no
+// solution exists within the search limit.
+func (oc *optimalCache) get(g *game) int {
+	key := puzzleKey(g)
+	oc.lock.Lock()
+	if oc.lengths == nil {
+		oc.lengths = make(map[string]int)
+		oc.pending = make(map[string]chan struct{})
+	}
+	if length, ok := oc.lengths[key]; ok {
+		oc.lock.Unlock()
+		return length
+	}
+	if wait, ok := oc.pending[key]; ok {
+		oc.lock.Unlock()
+		<-wait
+		return oc.get(g)
+	}
+	done := make(chan struct{})
+	oc.pending[key] = done
+	oc.lock.Unlock()
+
+	length := optimalLength(g)
+
+	oc.lock.Lock()
+	oc.lengths[key] = length
+	delete(oc.pending, key)
+	oc.lock.Unlock()
+	close(done)
+
+	return length
+}
+
+// optimalLength solves a copy of g. Returns 0 if there is no solution in the move limit
+func optimalLength(g *game) int {
+	// the length carried in the ID isn't trusted since rewards depend on it
+	cpy := g.clone()
+	cpy.activeRobot = cpy.robots[cpy.activeGoal.id]
+	cpy.precomputedMoves = cpy.preCompute(cpy.activeGoal.position)
+	moves, err := parseMoves(cpy.solve(maxArchiveSolveDepth))
+	if err != nil {
+		return 0
+	}
+	return len(moves)
+}
+
+// archiveRewardRule controls whether solving a puzzle that is no longer active earns tokens
+type archiveRewardRule int
+
+const (
+	ARCHIVE_REWARD_NONE    archiveRewardRule = iota // archived solves never pay out
+	ARCHIVE_REWARD_OPTIMAL                          // only optimal archived solves pay out
+	ARCHIVE_REWARD_ALL                              // any archived solve pays out
+)
+
+func parseArchiveRewardRule(s string) (archiveRewardRule, error) {
+	switch s {
+	case "", "none":
+		return ARCHIVE_REWARD_NONE, nil
+	case "optimal":
+		return ARCHIVE_REWARD_OPTIMAL, nil
+	case "all":
+		return ARCHIVE_REWARD_ALL, nil
+	default:
+		return ARCHIVE_REWARD_NONE, fmt.Errorf("unknown archive reward rule: %q", s)
+	}
+}
+
+// archiveReward is the number of tokens earned for an archived solve. Users are only
+// rewarded the first time they solve a given puzzle
+func (s *server) archiveReward(userID, puzzleID string, numMoves, optimal int) int {
+	if optimal == 0 || s.history.hasSolved(userID, puzzleID) {
+		return 0
+	}
+	isOptimal := numMoves == optimal
+
+	switch s.archiveRewards {
+	case ARCHIVE_REWARD_OPTIMAL:
+		if !isOptimal {
+			return 0
+		}
+	case ARCHIVE_REWARD_ALL:
+	default:
+		return 0
+	}
+	rp := s.rewardPolicy()
+	return rp.amount(difficultyFromMoves(optimal)) * rp.Archive
+}
+
+// acquireArchiveSolve reserves one of the slots for scoring an old puzzle, returning false if
+// they're all in use
+func (s *server) acquireArchiveSolve() bool {
+	s.archiveSolvesOnce.Do(func() { s.archiveSolves = make(chan struct{}, maxArchiveSolves) })
+	select {
+	case s.archiveSolves <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *server) releaseArchiveSolve() {
+	<-s.archiveSolves
+}
+
+// scoreArchivedSolve computes the optimal length of an old puzzle, announces how close
+// the user was and records the result. Intended to be run in the background
+func (s *server) scoreArchivedSolve(post func(string) error, guildID, userID string, g *game, moves []move) {
+	optimal := s.optimal.get(g)
+	// metadata carried by the ID must be what the bot would have issued
+	if g.lenOptimalSolution != 0 && g.lenOptimalSolution != optimal {
+		if err := post(fmt.Sprintf(":x: <@%s> puzzle #%s doesn't match the optimal length in its ID", userID, g.id)); err != nil {
+			log.Printf("announcing archived solve: %v", err)
+		}
+		return
+	}
+	// rewards, history and achievements are keyed by the ID the bot issues for the puzzle no
+	// matter how the user spelled it
+	id, err := canonicalID(g, optimal)
+	if err != nil {
+		log.Printf("encoding archived puzzle: %v", err)
+		return
+	}
+
+	reward := 0
+	if s.rewardPolicy().paysIn(guildID) && !g.isCustom() {
+		reason := newRewardReason("archive", guildID, id)
+		reason.add("archive", s.archiveReward(userID, id, len(moves), optimal))
+		s.capReward(userID, &reason)
+		paid, err := s.payReward(userID, reason)
+		if err != nil {
+			log.Printf("Unable to add archived reward to ledger: %v", err)
+		}
+		reward = paid
+	}
+
+	var content string
+	switch {
+	case optimal == 0:
+		content = fmt.Sprintf("<@%s> solved non-active puzzle #**%s** with a %d move solution", userID, id, len(moves))
+	case len(moves) == optimal:
+		content = fmt.Sprintf("<@%s> solved non-active puzzle #**%s** with an :tada:**optimal**:tada: %d move solution", userID, id, len(moves))
+	default:
+		content = fmt.Sprintf("<@%s> solved non-active puzzle #**%s** with a %d move solution (optimal is %d, +%d)", userID, id, len(moves), optimal, len(moves)-optimal)
+	}
+	if reward > 0 {
+		content = fmt.Sprintf("%s +%d <:arena:917512583160930364>", content, reward)
+	}
+	if err := post(content); err != nil {
+		log.Printf("announcing archived solve: %v", err)
+	}
+
+	sub := submission{
+		UserID:     userID,
+		GuildID:    guildID,
+		PuzzleID:   id,
+		Moves:      formatMoves(moves),
+		NumMoves:   len(moves),
+		Optimal:    optimal,
+		Difficulty: difficultyFromMoves(optimal).String(),
+		Archived:   true,
+		Reward:     reward,
+		Timestamp:  s.clk().Now(),
+	}
+	if err := s.history.record(sub); err != nil {
+		log.Printf("recording archived solve: %v", err)
+	}
+	if g.isCustom() {
+		return
+	}
+	if unlocked := s.solveAchievements(sub); len(unlocked) > 0 {
+		if err := post(unlockContent(s.playerName(userID), unlocked)); err != nil {
+			log.Printf("announcing achievements: %v", err)
+		}
+	}
+}
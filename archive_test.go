@@ -0,0 +1,206 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/njones/base58"
+)
+
+func TestOptimalCache(t *testing.T) {
+	g, err := decode("3BxvKmWMqjKASyDq")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cache optimalCache
+	first := cache.get(g)
+	if first == 0 {
+		t.Fatalf("expected puzzle to be solvable")
+	}
+	if second := cache.get(g); second != first {
+		t.Fatalf("cached length %d does not match %d", second, first)
+	}
+}
+
+func TestArchiveReward(t *testing.T) {
+	h, err := loadHistory(filepath.Join(t.TempDir(), "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{history: h, archiveRewards: ARCHIVE_REWARD_OPTIMAL}
+
+	if r := s.archiveReward("user", "puzzle", 12, 10); r != 0 {
+		t.Fatalf("non optimal solve rewarded %d", r)
+	}
+	if r := s.archiveReward("user", "puzzle", 10, 10); r != defaultRewardPolicy().amount(MEDIUM) {
+		t.Fatalf("optimal solve rewarded %d", r)
+	}
+
+	// only the first archived solve of a puzzle pays out
+	h.record(submission{UserID: "user", PuzzleID: "puzzle", NumMoves: 10, Optimal: 10})
+	if r := s.archiveReward("user", "puzzle", 10, 10); r != 0 {
+		t.Fatalf("repeat solve rewarded %d", r)
+	}
+
+	reloaded, err := loadHistory(h.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.forUser("user")) != 1 {
+		t.Fatalf("history was not persisted")
+	}
+}
+
+func TestArchivedPuzzleSpellings(t *testing.T) {
+	g, err := decode("3BxvKmWMqjKASyDq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := g.clone()
+	solver.activeRobot = solver.robots[solver.activeGoal.id]
+	moves, err := parseMoves(solver.solve(maxArchiveSolveDepth))
+	if err != nil || len(moves) == 0 {
+		t.Fatalf("expected puzzle to be solvable")
+	}
+	canonical, err := canonicalID(g, len(moves))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout, err := encodeLayout(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, fm := newTestServer(t, nil)
+	s.history, _ = loadHistory("")
+	ml, _ := loadMemoryLedger("")
+	s.ledger = ml
+	s.rewards = defaultRewardPolicy()
+	s.rewards.Guilds = []string{testGuild}
+	s.archiveRewards = ARCHIVE_REWARD_ALL
+
+	// the legacy ID, a versioned ID without metadata and the ID the bot issues are one puzzle
+	spellings := []string{
+		base58.StdEncoding.EncodeToString(layout),
+		wrapID(ID_VERSION_STANDARD, layout),
+		canonical,
+	}
+	for idx, id := range spellings {
+		i := command(testGuild, testChannel, "alice", "solve", "moves", formatMoves(moves), "puzzle_id", id)
+		s.handleInteraction(fm, i)
+		if reply := fm.reply(i); !strings.Contains(reply, "Puzzle Solved") {
+			t.Fatalf("%s was rejected: %q", id, reply)
+		}
+		deadline := time.Now().Add(time.Second * 10)
+		for len(s.history.forUser("alice")) != idx+1 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 5)
+		}
+	}
+
+	subs := s.history.forUser("alice")
+	if len(subs) != len(spellings) {
+		t.Fatalf("recorded %d solves", len(subs))
+	}
+	for _, sub := range subs {
+		if sub.PuzzleID != canonical {
+			t.Fatalf("solve recorded under %s instead of %s", sub.PuzzleID, canonical)
+		}
+	}
+	if balance, want := ml.balance("alice"), s.rewards.amount(difficultyFromMoves(len(moves)))*s.rewards.Archive; balance != want {
+		t.Fatalf("paid %d for one puzzle, expected %d", balance, want)
+	}
+
+	// spellings the bot never issues are rejected
+	rotated := append([]byte{}, layout...)
+	rotated[10] = 1
+	wrongDifficulty := append(append([]byte{}, layout...), byte(len(moves)), byte(UNKNOWN))
+	for _, id := range []string{wrapID(ID_VERSION_STANDARD, rotated), wrapID(ID_VERSION_STANDARD, wrongDifficulty)} {
+		if _, err := decode(id); err == nil {
+			t.Fatalf("decoded %s", id)
+		}
+	}
+	cpy := *g
+	cpy.lenOptimalSolution = len(moves) + 1
+	cpy.difficulty = difficultyFromMoves(len(moves) + 1)
+	wrongOptimal, err := encode(&cpy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.handleInteraction(fm, command(testGuild, testChannel, "bob", "solve", "moves", formatMoves(moves), "puzzle_id", wrongOptimal))
+	fm.waitFor(t, testChannel, "doesn't match the optimal length")
+	if len(s.history.forUser("bob")) != 0 || ml.balance("bob") != 0 {
+		t.Fatalf("solve of a puzzle with forged metadata was accepted")
+	}
+
+	// scoring old puzzles is limited so they can't tie up every CPU
+	deadline := time.Now().Add(time.Second * 10)
+	for len(s.archiveSolves) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 5)
+	}
+	for n := 0; n < maxArchiveSolves; n++ {
+		if !s.acquireArchiveSolve() {
+			t.Fatalf("slot %d wasn't available", n)
+		}
+	}
+	busy := command(testGuild, testChannel, "carol", "solve", "moves", formatMoves(moves), "puzzle_id", canonical)
+	s.handleInteraction(fm, busy)
+	if reply := fm.reply(busy); !strings.Contains(reply, "try again") {
+		t.Fatalf("archived solve ran with every slot in use: %q", reply)
+	}
+	for n := 0; n < maxArchiveSolves; n++ {
+		s.releaseArchiveSolve()
+	}
+
+	// fixed sets only use the first layout byte, junk in the others is another spelling
+	mini := boardSetByName("mini")
+	var small *game
+	var smallMoves []move
+	for attempts := 0; smallMoves == nil; attempts++ {
+		if attempts == 1000 {
+			t.Fatalf("no easy mini puzzle")
+		}
+		small = randomGameFromSet(mini)
+		solver := small.clone()
+		solver.precomputedMoves = solver.preCompute(solver.activeGoal.position)
+		// easy puzzles so the solve is quick and still pays out
+		if found, err := parseMoves(solver.solve(9)); err == nil && difficultyFromMoves(len(found)) == EASY {
+			smallMoves = found
+		}
+	}
+	smallLayout, err := encodeLayout(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	junk := append([]byte{}, smallLayout...)
+	junk[1], junk[2], junk[3] = 7, 1, 200
+	for _, id := range []string{base58.StdEncoding.EncodeToString(junk), wrapID(ID_VERSION_STANDARD, junk)} {
+		if _, err := decode(id); err == nil {
+			t.Fatalf("decoded %s with junk in the unused layout bytes", id)
+		}
+	}
+	smallCanonical, err := canonicalID(small, len(smallMoves))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx, id := range []string{base58.StdEncoding.EncodeToString(smallLayout), smallCanonical} {
+		s.handleInteraction(fm, command(testGuild, testChannel, "dave", "solve", "moves", formatMoves(smallMoves), "puzzle_id", id))
+		deadline := time.Now().Add(time.Second * 10)
+		for len(s.history.forUser("dave")) != idx+1 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 5)
+		}
+	}
+	if subs := s.history.forUser("dave"); len(subs) != 2 {
+		t.Fatalf("recorded %d mini solves", len(subs))
+	}
+	for _, sub := range s.history.forUser("dave") {
+		if sub.PuzzleID != smallCanonical {
+			t.Fatalf("mini solve recorded under %s instead of %s", sub.PuzzleID, smallCanonical)
+		}
+	}
+	if balance, want := ml.balance("dave"), s.rewards.amount(EASY)*s.rewards.Archive; want == 0 || balance != want {
+		t.Fatalf("paid %d for one mini puzzle, expected %d", balance, want)
+	}
+}
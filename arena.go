@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
@@ -12,121 +13,167 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
-func tokenReward(diff difficulty) int {
-	switch diff {
-	case EASY:
-		return 10
-	case MEDIUM:
-		return 15
-	case HARD:
-		return 20
-	case EXTREME:
-		return 30
-	default:
-		return 0
-	}
+// postgresLedger pays rewards into the arena database
+type postgresLedger struct {
+	conn *pgxpool.Pool
+}
+
+func (pl postgresLedger) LinkedAccount(discordUserID string) (string, error) {
+	return arenaIDFromLinkedAccount(pl.conn, discordUserID)
+}
+
+func (pl postgresLedger) AddReward(entry rewardEntry) (bool, error) {
+	return addSolveRewardLedgerEntry(pl.conn, entry)
+}
+
+func (pl postgresLedger) Rewards(since time.Time) ([]rewardEntry, error) {
+	return rewardLedgerEntries(pl.conn, since)
 }
 
+// rewardNamespace scopes the uuids derived from reward keys
+var rewardNamespace = uuid.MustParse("3f7f4c52-0c3e-4b0b-9a43-7d8a2c6e1b90")
+
+// the id is derived from the entry's key so inserting the same reward twice does nothing
 var addRewardLedgerEntryQuery = `
 	INSERT INTO public."TokenLedger"
 	(id, "createdAt", "updatedAt", "amount", "userId", "type", "data", "uniqueId")
 	VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (id) DO NOTHING
 `
 
-func addSolveRewardLedgerEntry(conn *pgxpool.Pool, userID string, amount int) error {
-	timestamp := time.Now()
-	uniqueID := uuid.New()
+// addSolveRewardLedgerEntry writes the entry to the ledger. Returns false if the entry was
+// already recorded
+func addSolveRewardLedgerEntry(conn *pgxpool.Pool, entry rewardEntry) (bool, error) {
+	if entry.Amount == 0 {
+		return false, nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return false, fmt.Errorf("encoding reward: %v", err)
+	}
+	uniqueID := uuid.NewSHA1(rewardNamespace, []byte(entry.Key))
 
 	tx, err := conn.Begin(context.TODO())
 	if err != nil {
-		return fmt.Errorf("starting transaction: %v", err)
+		return false, fmt.Errorf("starting transaction: %v", err)
 	}
+	// rolling back after the commit does nothing
+	defer tx.Rollback(context.TODO())
 
-	_, err = tx.Exec(context.Background(), addRewardLedgerEntryQuery,
+	tag, err := tx.Exec(context.Background(), addRewardLedgerEntryQuery,
 		fmt.Sprintf("RR-%s", uniqueID),
-		timestamp,
-		timestamp,
-		amount,
-		userID,
+		entry.Timestamp,
+		entry.Timestamp,
+		entry.Amount,
+		entry.AccountID,
 		"MANUAL",
-		`{"reason": "ricochet-robotbot"}`,
+		string(data),
 		uniqueID,
 	)
 	if err != nil {
-		return fmt.Errorf("executing discord reward query: %v\n", err)
+		return false, fmt.Errorf("executing discord reward query: %v", err)
 	}
-	/*
-		fmt.Println(userID, amount, tag.RowsAffected(), tag.String())
-		spew.Dump(tag)
-		spew.Dump(conn)
-	*/
 
 	// commit the result
 	err = tx.Commit(context.TODO())
 	if err != nil {
-		return fmt.Errorf("committing transaction: %v", err)
+		return false, fmt.Errorf("committing transaction: %v", err)
 	}
 
-	return nil
+	return tag.RowsAffected() == 1, nil
 }
 
-func arenaSolution(dg *discordgo.Session, i *discordgo.Interaction, instance *discordInstance, db *pgxpool.Pool, moves []move) error {
+var rewardLedgerEntriesQuery = `
+	SELECT l."amount", l."userId", l."data", l."createdAt"
+	FROM public."TokenLedger" l
+	WHERE l.id LIKE 'RR-%' AND l."createdAt" >= $1
+	ORDER BY l."createdAt";
+`
 
-	activeGame := instance.activeGame
-	id, err := encode(activeGame)
+// rewardLedgerEntries returns the bot's ledger entries created since the given time. Entries
+// written before rewards carried a reason only have their amount and account
+func rewardLedgerEntries(conn *pgxpool.Pool, since time.Time) ([]rewardEntry, error) {
+	rows, err := conn.Query(context.Background(), rewardLedgerEntriesQuery, since)
 	if err != nil {
-		return fmt.Errorf("encoding solution game: %v", err)
+		return nil, fmt.Errorf("querying ledger: %v", err)
 	}
-	currentSolutions := instance.getSolutions(id)
+	defer rows.Close()
+
+	var entries []rewardEntry
+	for rows.Next() {
+		var amount int
+		var accountID, data sql.NullString
+		var createdAt time.Time
+		if err := rows.Scan(&amount, &accountID, &data, &createdAt); err != nil {
+			return nil, fmt.Errorf("reading ledger entry: %v", err)
+		}
+		var entry rewardEntry
+		if data.Valid {
+			// legacy entries don't parse into a reward, which is fine
+			json.Unmarshal([]byte(data.String), &entry)
+		}
+		entry.Amount = amount
+		entry.AccountID = accountID.String
+		entry.Timestamp = createdAt
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading ledger: %v", err)
+	}
+	return entries, nil
+}
 
-	firstSolve := currentSolutions.numSubmitted() == 0
-	isOptimal := len(moves) == instance.activeGame.lenOptimalSolution
-	tokensEarned := 0
-	if firstSolve {
-		tokensEarned += tokenReward(instance.activeGame.difficulty)
+// arenaSolution records the solution and pays out any tokens earned under the reward policy.
+// Returns the number of tokens that were added to the ledger. Not used during tournaments, which
+// pay out for the final standings
+func (s *server) arenaSolution(dg messenger, i *discordgo.Interaction, instance *discordInstance, activeGame *game, moves []move) (int, error) {
+
+	currentSolutions := instance.getSolutions(activeGame.id)
+
+	// the first optimal solution is the first one matching the optimal length, no matter who
+	// solved first or how many times they resubmitted
+	isOptimal := len(moves) == activeGame.lenOptimalSolution
+	best := currentSolutions.currentBest()
+	facts := solveFacts{
+		difficulty:   activeGame.difficulty,
+		firstSolver:  currentSolutions.numSubmitted() == 0,
+		firstOptimal: isOptimal && (best == nil || len(best) > activeGame.lenOptimalSolution),
 	}
 	if isOptimal {
-		// bonus points if first optimal solution
-		if currentSolutions.numSubmitted() == 0 || len(moves) < len(currentSolutions.currentBest()) {
-			tokensEarned += tokenReward(instance.activeGame.difficulty)
-		}
+		facts.streak = optimalStreak(s.history.forUser(i.Member.User.ID), i.GuildID) + 1
 	}
+	reason := newRewardReason("solve", i.GuildID, activeGame.id)
+	s.rewardPolicy().solveAwards(&reason, facts)
+	s.capReward(i.Member.User.ID, &reason)
+
+	// only announce what actually made it into the ledger
+	tokensEarned, payErr := s.payReward(i.Member.User.ID, reason)
 
 	current := currentSolutions.get(i.Member.User.ID)
 	if len(current) == 0 || len(moves) <= len(current) {
 		currentSolutions.set(i.Member.User.ID, moves)
 	}
 
-	// only print solve messages if there is not an active tournament
-	if instance.activeTournament == nil {
-		var content string
-		if isOptimal {
-			content = fmt.Sprintf("<@%s> solved with an :tada:**optimal**:tada: %d move solution", i.Member.User.ID, len(moves))
-		} else {
-			content = fmt.Sprintf("<@%s> solved with a %d move solution", i.Member.User.ID, len(moves))
-		}
-		if tokensEarned > 0 {
-			content = fmt.Sprintf("%s +%d <:arena:917512583160930364>", content, tokensEarned)
-		}
+	var content string
+	if isOptimal {
+		content = fmt.Sprintf("<@%s> solved with an :tada:**optimal**:tada: %d move solution", i.Member.User.ID, len(moves))
+	} else {
+		content = fmt.Sprintf("<@%s> solved with a %d move solution", i.Member.User.ID, len(moves))
+	}
+	if tokensEarned > 0 {
+		content = fmt.Sprintf("%s +%d <:arena:917512583160930364>", content, tokensEarned)
+	}
+	if instance.settings().AnnounceSolves {
 		if _, err := dg.ChannelMessageSend(i.ChannelID, content); err != nil {
 			log.Printf("Sending arena solution message: %v", err)
-			return err
+			return tokensEarned, err
 		}
 	}
 
-	// look up linked arena account if exists
-	arenaID, err := arenaIDFromLinkedAccount(db, i.Member.User.ID)
-	if err != nil || arenaID == "" {
-		log.Printf("Solver does not have a linked arena account")
-		return nil
+	if payErr != nil {
+		return tokensEarned, fmt.Errorf("Unable to add reward to ledger: %v", payErr)
 	}
-
-	// add token reward to arena db
-	if err := addSolveRewardLedgerEntry(db, arenaID, tokensEarned); err != nil {
-		return fmt.Errorf("Unable to add reward to ledger: %v", err)
-	}
-
-	return nil
+	return tokensEarned, nil
 }
 
 var linkedAccountQuery = `
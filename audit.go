@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// how often the ledger is checked against recorded solves, and how far back each check looks
+var reconcileInterval = time.Hour * 6
+var reconcileWindow = time.Hour * 24
+
+// maximum ledger entries listed by /rewards audit
+const auditEntryLimit = 10
+
+// rewardDiscrepancy is a puzzle where the reward recorded with a user's solves doesn't match what
+// the ledger paid them
+type rewardDiscrepancy struct {
+	userID   string
+	puzzleID string
+	recorded int
+	paid     int
+}
+
+// reconcileRewards compares the solve and archive rewards recorded in history against the ledger.
+// Tournament rewards aren't recorded with solves so they aren't checked
+func reconcileRewards(subs []submission, entries []rewardEntry) []rewardDiscrepancy {
+	type solveKey struct{ userID, puzzleID string }
+	recorded := make(map[solveKey]int)
+	paid := make(map[solveKey]int)
+	for _, sub := range subs {
+		if sub.Reward != 0 {
+			recorded[solveKey{sub.UserID, sub.PuzzleID}] += sub.Reward
+		}
+	}
+	for _, entry := range entries {
+		if entry.Type != "solve" && entry.Type != "archive" {
+			continue
+		}
+		paid[solveKey{entry.UserID, entry.PuzzleID}] += entry.Amount
+	}
+
+	var discrepancies []rewardDiscrepancy
+	check := func(key solveKey) {
+		if recorded[key] != paid[key] {
+			discrepancies = append(discrepancies, rewardDiscrepancy{
+				userID:   key.userID,
+				puzzleID: key.puzzleID,
+				recorded: recorded[key],
+				paid:     paid[key],
+			})
+		}
+	}
+	for key := range recorded {
+		check(key)
+	}
+	for key := range paid {
+		if _, ok := recorded[key]; !ok {
+			check(key)
+		}
+	}
+
+	sort.Slice(discrepancies, func(a, b int) bool {
+		if discrepancies[a].userID != discrepancies[b].userID {
+			return discrepancies[a].userID < discrepancies[b].userID
+		}
+		return discrepancies[a].puzzleID < discrepancies[b].puzzleID
+	})
+	return discrepancies
+}
+
+// checkRewards reconciles everything paid in the guild since t. An empty guildID checks every guild
+func (s *server) checkRewards(guildID string, since time.Time) ([]rewardEntry, []rewardDiscrepancy, error) {
+	entries, err := s.rewardLedger().Rewards(since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading ledger: %v", err)
+	}
+	subs := s.history.since(since)
+	if guildID != "" {
+		var guildEntries []rewardEntry
+		for _, entry := range entries {
+			if entry.GuildID == guildID {
+				guildEntries = append(guildEntries, entry)
+			}
+		}
+		entries = guildEntries
+		var guildSubs []submission
+		for _, sub := range subs {
+			if sub.GuildID == guildID {
+				guildSubs = append(guildSubs, sub)
+			}
+		}
+		subs = guildSubs
+	}
+	return entries, reconcileRewards(subs, entries), nil
+}
+
+// reconcileLoop periodically logs any rewards that don't match the ledger
+func (s *server) reconcileLoop() {
+	for {
+		s.clk().Sleep(reconcileInterval)
+		_, discrepancies, err := s.checkRewards("", s.clk().Now().Add(-reconcileWindow))
+		if err != nil {
+			log.Printf("reconciling rewards: %v", err)
+			continue
+		}
+		for _, d := range discrepancies {
+			log.Printf("reward mismatch: user %s puzzle %s recorded %d, ledger paid %d", d.userID, d.puzzleID, d.recorded, d.paid)
+		}
+	}
+}
+
+func (s *server) handleRewards(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding rewards ack: %v", err)
+	}
+	respond := func(content string) error {
+		_, err := dg.InteractionResponseEdit(i.Interaction,
+			&discordgo.WebhookEdit{
+				Content: &content,
+			},
+		)
+		return err
+	}
+
+	if i.GuildID == "" {
+		return respond(":x: Rewards can only be audited from a server")
+	}
+	data := i.Interaction.ApplicationCommandData()
+	if len(data.Options) == 0 || data.Options[0].Name != "audit" {
+		return respond(":x: Unknown rewards command")
+	}
+	days := 1
+	var userID string
+	for _, opt := range data.Options[0].Options {
+		switch opt.Name {
+		case "days":
+			days = int(opt.IntValue())
+		case "user":
+			userID = opt.Value.(string)
+		}
+	}
+
+	// admins only see payouts made in their own guild
+	since := s.clk().Now().Add(-time.Hour * 24 * time.Duration(days))
+	entries, discrepancies, err := s.checkRewards(i.GuildID, since)
+	if err != nil {
+		respond(":x: Unable to read the reward ledger, please try again later")
+		return fmt.Errorf("auditing rewards: %v", err)
+	}
+	if userID != "" {
+		var filtered []rewardEntry
+		for _, entry := range entries {
+			if entry.UserID == userID {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+		var filteredDiscrepancies []rewardDiscrepancy
+		for _, d := range discrepancies {
+			if d.userID == userID {
+				filteredDiscrepancies = append(filteredDiscrepancies, d)
+			}
+		}
+		discrepancies = filteredDiscrepancies
+	}
+
+	return respond(auditContent(days, userID, entries, discrepancies))
+}
+
+func auditContent(days int, userID string, entries []rewardEntry, discrepancies []rewardDiscrepancy) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Reward Audit** for the last %d day(s)", days))
+	if userID != "" {
+		sb.WriteString(fmt.Sprintf(" for <@%s>", userID))
+	}
+	total := 0
+	for _, entry := range entries {
+		total += entry.Amount
+	}
+	sb.WriteString(fmt.Sprintf("\n**%d** ledger entries paying **%d** tokens\n", len(entries), total))
+
+	start := 0
+	if len(entries) > auditEntryLimit {
+		start = len(entries) - auditEntryLimit
+	}
+	for _, entry := range entries[start:] {
+		who := "unknown user"
+		if entry.UserID != "" {
+			who = fmt.Sprintf("<@%s>", entry.UserID)
+		}
+		sb.WriteString(fmt.Sprintf("<t:%d:R> %s +%d", entry.Timestamp.Unix(), who, entry.Amount))
+		if entry.Rule != "" {
+			sb.WriteString(fmt.Sprintf(" %s %s", entry.Type, entry.Rule))
+		}
+		if entry.PuzzleID != "" {
+			sb.WriteString(fmt.Sprintf(" #%s", entry.PuzzleID))
+		}
+		if entry.Capped > 0 {
+			sb.WriteString(fmt.Sprintf(" (%d over the daily cap)", entry.Capped))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(discrepancies) == 0 {
+		sb.WriteString("\n:white_check_mark: Every rewarded solve matches the ledger")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("\n:warning: **%d** solves don't match the ledger:\n", len(discrepancies)))
+	for idx, d := range discrepancies {
+		if idx == auditEntryLimit {
+			sb.WriteString(fmt.Sprintf("...and %d more", len(discrepancies)-idx))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("<@%s> #%s recorded %d, ledger paid %d\n", d.userID, d.puzzleID, d.recorded, d.paid))
+	}
+	return sb.String()
+}
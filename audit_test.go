@@ -0,0 +1,98 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestReconcileRewards(t *testing.T) {
+	subs := []submission{
+		{UserID: "alice", PuzzleID: "p1", Reward: 30},
+		{UserID: "alice", PuzzleID: "p2", Reward: 15},
+		{UserID: "bob", PuzzleID: "p1"},
+		{UserID: "bob", PuzzleID: "p2", Reward: 15},
+	}
+	entries := []rewardEntry{
+		{UserID: "alice", PuzzleID: "p1", Type: "solve", Amount: 15},
+		{UserID: "alice", PuzzleID: "p1", Type: "solve", Amount: 15},
+		{UserID: "alice", PuzzleID: "p2", Type: "archive", Amount: 15},
+		{UserID: "bob", PuzzleID: "p1", Type: "solve", Amount: 15},
+		{UserID: "bob", PuzzleID: "t1", Type: "tournament", Amount: 50},
+	}
+
+	want := []rewardDiscrepancy{
+		{userID: "bob", puzzleID: "p1", recorded: 0, paid: 15},
+		{userID: "bob", puzzleID: "p2", recorded: 15, paid: 0},
+	}
+	if got := reconcileRewards(subs, entries); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got discrepancies %+v, expected %+v", got, want)
+	}
+}
+
+// auditCommand builds /rewards audit with the given subcommand options
+func auditCommand(options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	i := command(ArenaServerID, "arena", "admin", "rewards")
+	data := i.ApplicationCommandData()
+	data.Options = []*discordgo.ApplicationCommandInteractionDataOption{{
+		Name:    "audit",
+		Type:    discordgo.ApplicationCommandOptionSubCommand,
+		Options: options,
+	}}
+	i.Data = data
+	return i
+}
+
+func TestRewardsAudit(t *testing.T) {
+	puzzles := testPuzzles(t)
+	for _, g := range puzzles {
+		g.difficulty = MEDIUM
+		g.set = classicSet
+	}
+	s, fm := newTestServer(t, puzzles)
+	h, err := loadHistory(filepath.Join(t.TempDir(), "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.history = h
+	ml, _ := loadMemoryLedger("")
+	s.ledger = ml
+	s.setPrimaryChannel(ArenaServerID, "arena", defaultGuildConfig())
+	g := puzzles[0]
+
+	s.handleInteraction(fm, command(ArenaServerID, "arena", "alice", "puzzle"))
+	s.handleInteraction(fm, command(ArenaServerID, "arena", "alice", "solve", "moves", formatMoves(g.moves)))
+
+	audit := auditCommand()
+	s.handleInteraction(fm, audit)
+	reply := fm.reply(audit)
+	for _, want := range []string{"**2** ledger entries paying **30** tokens", "<@alice> +15 solve first-optimal #" + g.id, "Every rewarded solve matches"} {
+		if !strings.Contains(reply, want) {
+			t.Fatalf("audit missing %q:\n%s", want, reply)
+		}
+	}
+
+	// payouts in other guilds stay private
+	ml.AddReward(rewardEntry{Key: "elsewhere", Type: "solve", UserID: "carol", AccountID: "carol", GuildID: "other-guild", PuzzleID: g.id, Amount: 15, Timestamp: s.clk().Now()})
+	audit = auditCommand()
+	s.handleInteraction(fm, audit)
+	if reply := fm.reply(audit); strings.Contains(reply, "<@carol>") {
+		t.Fatalf("audit showed another guild's payout:\n%s", reply)
+	}
+
+	// a payout no solve accounts for
+	ml.AddReward(rewardEntry{Key: "stray", Type: "solve", UserID: "bob", AccountID: "bob", GuildID: ArenaServerID, PuzzleID: g.id, Amount: 15, Timestamp: s.clk().Now()})
+	audit = auditCommand(&discordgo.ApplicationCommandInteractionDataOption{
+		Name:  "user",
+		Type:  discordgo.ApplicationCommandOptionUser,
+		Value: "bob",
+	})
+	s.handleInteraction(fm, audit)
+	reply = fm.reply(audit)
+	if !strings.Contains(reply, "<@bob> #"+g.id+" recorded 0, ledger paid 15") || strings.Contains(reply, "<@alice>") {
+		t.Fatalf("audit didn't flag bob's payout:\n%s", reply)
+	}
+}
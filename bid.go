@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// how long bids are accepted after the first bid on a puzzle
+var bidDuration = time.Second * 60
+
+// how long each bidder has to demonstrate their solution
+var demonstrateDuration = time.Second * 60
+
+const maxBid = 30
+
+// bidding follows the table game. Players bid how many moves they can solve the puzzle in and
+// once time runs out the lowest bidder must demonstrate. Failed demonstrations pass to the next
+// lowest bidder
+type bidding struct {
+	lock sync.Mutex
+
+	g    *game
+	bids []bid // lowest first, ties go to whoever bid first
+
+	closed   bool          // bidding is over and bidders are demonstrating
+	turn     int           // index into bids of who is demonstrating
+	turnDone chan struct{} // closed when the current bidder submits a solution
+	winner   string
+}
+
+type bid struct {
+	userID string
+	moves  int
+	at     time.Time
+}
+
+func newBidding(g *game) *bidding {
+	return &bidding{g: g}
+}
+
+// placeBid records a bid. Players may bid again but only to lower their bid
+func (b *bidding) placeBid(userID string, moves int, at time.Time) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return fmt.Errorf("bidding has closed")
+	}
+	if moves < 1 || moves > maxBid {
+		return fmt.Errorf("bids must be between 1 and %d moves", maxBid)
+	}
+
+	for idx, existing := range b.bids {
+		if existing.userID != userID {
+			continue
+		}
+		if moves >= existing.moves {
+			return fmt.Errorf("you already bid %d moves, bids can only be lowered", existing.moves)
+		}
+		b.bids = append(b.bids[:idx], b.bids[idx+1:]...)
+		break
+	}
+	b.bids = append(b.bids, bid{userID: userID, moves: moves, at: at})
+	sort.SliceStable(b.bids, func(i, j int) bool {
+		if b.bids[i].moves != b.bids[j].moves {
+			return b.bids[i].moves < b.bids[j].moves
+		}
+		return b.bids[i].at.Before(b.bids[j].at)
+	})
+	return nil
+}
+
+// close stops accepting bids and hands the first turn to the lowest bidder
+func (b *bidding) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	b.turn = 0
+	b.turnDone = make(chan struct{})
+}
+
+// current returns the bidder who must demonstrate next and a channel that is closed once they
+// submit. Returns false once someone has won or every bidder has failed
+func (b *bidding) current() (bid, <-chan struct{}, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.closed || b.winner != "" || b.turn >= len(b.bids) {
+		return bid{}, nil, false
+	}
+	return b.bids[b.turn], b.turnDone, true
+}
+
+// finished reports whether the puzzle has been won or every bidder has failed
+func (b *bidding) finished() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.winner != "" || (b.closed && b.turn >= len(b.bids))
+}
+
+// demonstrate checks a solution from the bidder whose turn it is. A solution wins if it is
+// valid and no longer than the bid, otherwise the turn passes to the next bidder
+func (b *bidding) demonstrate(userID string, moves []move) (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.closed {
+		return false, fmt.Errorf("bidding is still open, use **/bid** to bid on this puzzle")
+	}
+	if b.winner != "" || b.turn >= len(b.bids) {
+		return false, fmt.Errorf("this puzzle's bidding is over")
+	}
+	current := b.bids[b.turn]
+	if current.userID != userID {
+		return false, fmt.Errorf("it is <@%s>'s turn to demonstrate", current.userID)
+	}
+
+	success := len(moves) <= current.moves && validate(b.g, b.g.board, moves, b.g.activeGoal)
+	if success {
+		b.winner = userID
+	} else {
+		b.turn++
+	}
+	close(b.turnDone)
+	b.turnDone = make(chan struct{})
+	return success, nil
+}
+
+// pass skips the bidder if it is still their turn i.e. they ran out of time
+func (b *bidding) pass(userID string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.winner != "" || b.turn >= len(b.bids) || b.bids[b.turn].userID != userID {
+		return
+	}
+	b.turn++
+	close(b.turnDone)
+	b.turnDone = make(chan struct{})
+}
+
+func (b *bidding) getWinner() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.winner
+}
+
+func (s *server) handleBid(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding bid ack: %v", err)
+	}
+
+	respond := func(content string) error {
+		_, err := dg.InteractionResponseEdit(i.Interaction,
+			&discordgo.WebhookEdit{
+				Content: &content,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("editing bid response: %v", err)
+		}
+		return nil
+	}
+
+	if i.Interaction.Member == nil {
+		return respond("Bids can only be placed in a server")
+	}
+	if len(i.Interaction.ApplicationCommandData().Options) == 0 {
+		return respond("Please provide the number of moves you are bidding")
+	}
+	moves := int(i.Interaction.ApplicationCommandData().Options[0].IntValue())
+
+	// look up instance
+	instance := s.instanceFor(i)
+	if reason := instance.modeDisabled("bid"); reason != "" {
+		return respond(reason)
+	}
+	if instance.gameInProgress() {
+		return respond("Bidding isn't available during a tournament, race or speed round")
+	}
+
+	// the first bid on a puzzle starts the countdown
+	b, first := instance.biddingFor()
+	if b == nil {
+		return respond("There is no active puzzle. Please use **/puzzle** to create one")
+	}
+	if err := b.placeBid(i.Interaction.Member.User.ID, moves, instance.clk().Now()); err != nil {
+		return respond(fmt.Sprintf(":x: %v", err))
+	}
+
+	content := fmt.Sprintf("<@%s> bids **%d** moves", i.Interaction.Member.User.ID, moves)
+	if first {
+		content += fmt.Sprintf(". Bidding closes **<t:%d:R>**", instance.clk().Now().Add(bidDuration).Unix())
+		go s.runBidding(dg, instance, b)
+	}
+	if _, err := dg.ChannelMessageSend(instance.channelID, content); err != nil {
+		log.Printf("announcing bid: %v", err)
+	}
+	return respond(fmt.Sprintf("Bid of %d moves placed", moves))
+}
+
+// runBidding waits for bidding to close then asks each bidder to demonstrate in turn
+func (s *server) runBidding(dg messenger, instance *discordInstance, b *bidding) {
+	post := func(content string) {
+		if _, err := dg.ChannelMessageSend(instance.channelID, content); err != nil {
+			log.Printf("announcing bidding: %v", err)
+		}
+	}
+
+	instance.clk().Sleep(bidDuration)
+	b.close()
+
+	for {
+		current, done, ok := b.current()
+		if !ok {
+			break
+		}
+		post(fmt.Sprintf("Bidding closed. <@%s> demonstrate your **%d** move solution with **/solve** **<t:%d:R>**",
+			current.userID, current.moves, instance.clk().Now().Add(demonstrateDuration).Unix()))
+
+		select {
+		case <-done:
+		case <-instance.clk().After(demonstrateDuration):
+			b.pass(current.userID)
+		}
+		if b.getWinner() == "" {
+			post(fmt.Sprintf("<@%s> was unable to demonstrate a %d move solution", current.userID, current.moves))
+		}
+	}
+
+	if winner := b.getWinner(); winner != "" {
+		post(fmt.Sprintf(":tada: <@%s> wins the bidding for puzzle #%s", winner, b.g.id))
+	} else {
+		post(fmt.Sprintf("No bidder could demonstrate their solution for puzzle #%s", b.g.id))
+	}
+}
+
+// solveBid handles /solve for the active puzzle while it is being bid on
+func (s *server) solveBid(dg messenger, i *discordgo.InteractionCreate, instance *discordInstance, b *bidding, moves []move) error {
+	success, err := b.demonstrate(i.Interaction.Member.User.ID, moves)
+
+	var content string
+	var unlocked []achievementRule
+	switch {
+	case err != nil:
+		content = fmt.Sprintf(":x: %v", err)
+	case success:
+		content = fmt.Sprintf(":white_check_mark: Puzzle Solved: %s", formatMoves(moves))
+		instance.getSolutions(b.g.id).set(i.Interaction.Member.User.ID, moves)
+		instance.solvedActiveRound(b.g, moves)
+		unlocked = s.recordSolve(instance, i.Interaction.Member.User.ID, b.g, moves, 0, instance.clk().Since(instance.postedAt()))
+	default:
+		content = fmt.Sprintf(":x: %s does not solve the puzzle within your bid", formatMoves(moves))
+	}
+
+	_, err = dg.InteractionResponseEdit(i.Interaction,
+		&discordgo.WebhookEdit{
+			Content: &content,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("editing bid solve response: %v", err)
+	}
+	s.announceUnlocks(dg, i.Interaction, instance, i.Interaction.Member.User.ID, content, unlocked)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBidding(t *testing.T) {
+	g, err := parseBoard(raceBoard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.precomputedMoves = g.preCompute(g.activeGoal.position)
+	solver := g.clone()
+	solution, _ := parseMoves(solver.solve(10))
+	if len(solution) == 0 {
+		t.Fatalf("expected a solution")
+	}
+
+	b := newBidding(&g)
+	start := time.Now()
+	if err := b.placeBid("alice", len(solution)+2, start); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.placeBid("bob", len(solution), start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.placeBid("carol", len(solution)-1, start.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	// raising a bid is not allowed, lowering overrides
+	if err := b.placeBid("alice", len(solution)+3, start.Add(3*time.Second)); err == nil {
+		t.Fatalf("expected raised bid to be rejected")
+	}
+	if err := b.placeBid("alice", len(solution), start.Add(4*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.demonstrate("carol", solution); err == nil {
+		t.Fatalf("demonstrating before bidding closes should fail")
+	}
+
+	b.close()
+	if err := b.placeBid("dave", 1, start.Add(5*time.Second)); err == nil {
+		t.Fatalf("expected bid after close to be rejected")
+	}
+
+	// carol bid lowest but can't solve it in her bid
+	current, done, ok := b.current()
+	if !ok || current.userID != "carol" {
+		t.Fatalf("expected carol to demonstrate first, got %+v", current)
+	}
+	if _, err := b.demonstrate("bob", solution); err == nil {
+		t.Fatalf("expected out of turn demonstration to be rejected")
+	}
+	if success, err := b.demonstrate("carol", solution); err != nil || success {
+		t.Fatalf("solution longer than the bid should fail")
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatalf("turn should end after demonstrating")
+	}
+
+	// bob and alice bid the same, bob bid first. bob times out
+	current, _, _ = b.current()
+	if current.userID != "bob" {
+		t.Fatalf("expected bob to demonstrate next, got %s", current.userID)
+	}
+	b.pass("bob")
+	b.pass("bob") // stale timeouts don't skip the next bidder
+
+	current, _, _ = b.current()
+	if current.userID != "alice" {
+		t.Fatalf("expected alice to demonstrate next, got %s", current.userID)
+	}
+	if success, err := b.demonstrate("alice", solution); err != nil || !success {
+		t.Fatalf("expected alice to win: %v", err)
+	}
+	if !b.finished() || b.getWinner() != "alice" {
+		t.Fatalf("expected alice to win the bidding")
+	}
+	if _, _, ok := b.current(); ok {
+		t.Fatalf("no one should demonstrate after a win")
+	}
+}
@@ -1,671 +0,0 @@
-package main
-
-import (
-	"fmt"
-	"math/rand"
-	"strings"
-)
-
-var fullBoard = `
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•
-|                   |                   |                       |
-•   •   •   •   •   •   •   •   •   •   •   •   •---•   •   •   •
-|                         y |                   | r             |
-•   •---•   •   •   •   •---•   •   •   •   •   •   •   •   •---•
-|   | g               R                                         |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                                   | b                         |
-•   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •   •
-|         r                                               g |   |
-•   •   •   •   •   •   •---•   •   •   •---•   •   •   •---•   •
-|                         b |             y |                   |
-•---•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|           | r                               G                 |
-•   •   •   •---•   •   •   •---•---•   •   •   •   •   •   •   •
-|     B                     |       |                           |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                           |       |                           |
-•   •   •   •   •   •   •   •---•---•   •   •   •   •   •   •---•
-|             b |                                               |
-•   •   •   •---•   •   •   •   •---•   •   •   •   •---•   •   •
-|                               |                   | r         |
-•---•   •   •   •   •---•   •   •   •   •   •   •   •   •   •   •
-|                   | r       Y           g |                   |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •
-|                                                       | y     |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•   •
-|   | y                                                         |
-•   •---•   •   •   •   •---•   •   •---•   •   •   •   •   •   •
-|                         g |         b |                       |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                   |                           |               |
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•`
-
-var topHalfs = []string{blueYellowHalf1, blueYellowHalf2, blueYellowHalf3, blueYellowHalf4}
-var bottomHalfs = []string{redGreenHalf1, redGreenHalf2, redGreenHalf3, redGreenHalf4}
-
-func boardFromQuadrants(tl, tr, bl, br int) (string, error) {
-	if tl >= len(blueQuandrants) || tr >= len(yellowQuandrants) || bl >= len(redQuandrants) || br >= len(greenQuandrants) {
-		return "", fmt.Errorf("invalid quadrants")
-	}
-
-	q1 := blueQuandrants[tl]
-	q2 := yellowQuandrants[tr]
-	q3 := redQuandrants[bl]
-	q4 := greenQuandrants[br]
-
-	stitched := stitchQuandrants(q1, q2, q3, q4)
-	return strings.TrimSpace(stitched), nil
-}
-
-func randomBoard() (string, []int) {
-	//	top := strings.TrimSpace(topHalfs[rand.Intn(len(topHalfs))])
-	//	bot := strings.TrimSpace(bottomHalfs[rand.Intn(len(bottomHalfs))])
-	tlIdx := rand.Intn(len(blueQuandrants))
-	trIdx := rand.Intn(len(yellowQuandrants))
-	blIdx := rand.Intn(len(redQuandrants))
-	brIdx := rand.Intn(len(greenQuandrants))
-
-	tl := blueQuandrants[tlIdx]
-	tr := yellowQuandrants[trIdx]
-	bl := redQuandrants[blIdx]
-	br := greenQuandrants[brIdx]
-
-	stitched := stitchQuandrants(tl, tr, bl, br)
-	return strings.TrimSpace(stitched), []int{tlIdx, trIdx, blIdx, brIdx}
-
-	//return fmt.Sprintf("%s\n%s", top, bot)
-}
-
-func stitchQuandrants(tl, tr, bl, br string) string {
-
-	var sb strings.Builder
-
-	lrows := strings.Split(tl, "\n")[1:] // newline added to get pretty formatting
-	rrows := strings.Split(tr, "\n")[1:]
-	for idx := range lrows {
-		sb.WriteString(lrows[idx])
-		sb.WriteString(rrows[idx])
-		sb.WriteString("\n")
-	}
-
-	lrows = strings.Split(bl, "\n")[1:] // newline added to get pretty formatting
-	rrows = strings.Split(br, "\n")[1:]
-
-	for idx := range lrows {
-		sb.WriteString(lrows[idx])
-		sb.WriteString(rrows[idx])
-		if idx != len(lrows)-1 {
-			sb.WriteString("\n")
-		}
-	}
-
-	return sb.String()
-}
-
-//var debugBoard = fmt.Sprintf("%s\n%s", strings.TrimSpace(blueYellowHalf1), strings.TrimSpace(redGreenHalf2))
-var debugBoard = `
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•
-|                   |               |                           |
-•   •   •---•   •   •   •   •   •   •   •   •   •   •   •   •   •
-|       |                                           |           |
-•   •   •   •   •   •   •   •   •   •   •   •   •---•   •   •   •
-|                                       |                       |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •
-|                       | b                                     |
-•   •   •   •   •   •   •---•   •   •   •   •   •   •   •   •---•
-|                                                               |
-•---•   •   •   •---•   •   •   •   •   •   •---•   •   •   •   •
-|                   |                           |               |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•   •
-|       |                                               |       |
-•   •---•   •   •   •   •   •---•---•   •   •   •   •   •   •   •
-|                 G         |       |   |                       |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •
-|                     B     |       |                           |
-•   •   •   •   •   •   •   •---•---•   •   •   •   •   •   •---•
-|                                           |                   |
-•   •   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •
-|                   |     Y                                     |
-•   •---•   •   •   •---•   •   •   •   •   •   •   •   •---•   •
-|   |                                                       |   |
-•   •   •   •   •   •   •---•   •   •---•   •   •   •   •   •   •
-|                           |       | R                         |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                                                               |
-•---•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|               |                                   |           |
-•   •   •   •---•   •   •   •   •   •   •   •   •---•   •   •   •
-|                       |                               |       |
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•`
-
-var blueYellowHalf1 = `
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•
-| R   B   G   Y     |               |                           |
-•   •   •---•   •   •   •   •   •   •   •   •   •   •   •   •   •
-|       | y                                       y |           |
-•   •   •   •   •   •   •   •   •   •   •   •   •---•   •   •   •
-|                                       | r                     |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •
-|                       | b                                     |
-•   •   •   •   •   •   •---•   •   •   •   •   •   •   •   •---•
-|                                                               |
-•---•   •   •   •---•   •   •   •   •   •   •---•   •   •   •   •
-|                 r |                         g |               |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•   •
-|     g |                                               | b     |
-•   •---•   •   •   •   •   •---•---•   •   •   •   •   •   •   •
-|                           |       |   | r                     |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •`
-
-var blueQuandrants = []string{
-	`
-•---•---•---•---•---•---•---•---•
-| R   B   G   Y     |            
-•   •   •---•   •   •   •   •   •
-|       | y                      
-•   •   •   •   •   •   •   •   •
-|                                
-•   •   •   •   •   •   •   •   •
-|                       | b      
-•   •   •   •   •   •   •---•   •
-|                                
-•---•   •   •   •---•   •   •   •
-|                 r |            
-•   •   •   •   •   •   •   •   •
-|     g |                        
-•   •---•   •   •   •   •   •---•
-|                           |    
-•   •   •   •   •   •   •   •   •`,
-	`
-•---•---•---•---•---•---•---•---•
-| R   B   G   Y         |        
-•   •   •   •   •   •   •   •   •
-|                                
-•   •   •   •---•   •   •   •   •
-|           | y                  
-•   •   •   •   •   •   •   •   •
-|                   | b          
-•---•   •---•   •   •---•   •   •
-|         r |                    
-•   •   •   •   •   •   •   •   •
-|                 g |            
-•   •   •   •   •---•   •   •   •
-|                                
-•   •   •   •   •   •   •   •---•
-|                           |    
-•   •   •   •   •   •   •   •   •`,
-	`
-•---•---•---•---•---•---•---•---•
-| R   B   G   Y |                
-•   •   •   •   •   •   •   •   •
-|                     g |        
-•   •   •   •   •   •---•   •   •
-|   | r                          
-•   •---•   •   •   •   •   •   •
-|                                
-•---•   •   •   •   •   •---•   •
-|                       | y      
-•   •   •   •   •   •   •   •   •
-|                                
-•   •   •---•   •   •   •   •   •
-|         b |                    
-•   •   •   •   •   •   •   •---•
-|                           |    
-•   •   •   •   •   •   •   •   •`,
-	`
-•---•---•---•---•---•---•---•---•
-| R   B   G | Y                  
-•   •   •   •   •   •   •   •   •
-|                                
-•   •   •   •   •   •   •---•   •
-|                     g | y      
-•   •   •   •   •   •---•   •   •
-|                                
-•   •   •   •   •   •   •   •   •
-|                                
-•   •   •   •   •   •   •   •   •
-|   | r                          
-•   •---•   •   •   •   •   •   •
-|                                
-•---•   •   •   •---•   •   •---•
-|                 b |       |    
-•   •   •   •   •   •   •   •   •`,
-}
-
-var yellowQuandrants = []string{
-	`
----•---•---•---•---•---•---•---•
-               |               |
-   •   •   •   •   •   •   •   •
-     y |                       |
-   •---•   •   •   •   •   •   •
-                               |
-   •   •   •   •   •   •   •---•
-           | r                 |
-   •   •   •---•   •   •   •   •
-                               |
-   •   •   •   •   •   •---•   •
-                       | b     |
-   •   •---•   •   •   •   •   •
-         g |                   |
----•   •   •   •   •   •   •   •
-   |               | y         |
-   •   •   •   •   •---•   •   •`,
-	`
----•---•---•---•---•---•---•---•
-   |                           |
-   •   •   •   •   •   •   •   •
-                 y |           |
-   •   •   •   •---•   •   •   •
-       | r                     |
-   •   •---•   •   •   •   •   •
-                               |
-   •   •   •   •   •   •   •---•
-                               |
-   •   •   •---•   •   •   •   •
-             g |               |
-   •   •   •   •   •   •---•   •
-                       | b     |
----•   •   •   •   •   •   •   •
-   |   | r                     |
-   •   •---•   •   •   •   •   •`,
-	`
----•---•---•---•---•---•---•---•
-           |                   |
-   •   •   •   •---•   •   •   •
-               | g             |
-   •   •   •   •   •   •   •   •
- r |                   | r     |
----•   •   •   •   •   •---•   •
-                               |
-   •   •   •   •   •   •   •   •
-                               |
-   •   •   •   •   •   •   •---•
-     b |                       |
-   •---•   •---•   •   •   •   •
-             y |               |
----•   •   •   •   •   •   •   •
-   |                           |
-   •   •   •   •   •   •   •   •`,
-	`
----•---•---•---•---•---•---•---•
-   |                           |
-   •   •   •   •   •   •   •   •
-       | r                     |
-   •   •---•   •   •   •   •   •
-                 b |           |
-   •   •   •   •---•   •   •   •
-               | g             |
----•   •   •   •   •   •   •   •
- r |                           |
-   •   •   •   •   •   •   •   •
-                               |
-   •   •   •   •   •---•   •   •
-                     y |       |
----•   •   •   •   •   •   •   •
-   |                           |
-   •   •   •   •   •   •   •   •`,
-}
-
-var blueYellowHalf2 = `
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•
-| R   B   G   Y     |                           |               |
-•   •   •---•   •   •   •   •   •   •   •   •   •   •   •   •   •
-|       | y                           y |                       |
-•   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •   •
-|                                                               |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•
-|                       | b                 | r                 |
-•   •   •   •   •   •   •---•   •   •   •   •---•   •   •   •   •
-|                                                               |
-•---•   •   •   •---•   •   •   •   •   •   •   •   •   •---•   •
-|                 r |                                   | b     |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •
-|     g |                                 g |                   |
-•   •---•   •   •   •   •   •---•---•   •   •   •   •   •   •   •
-|                           |       |               | y         |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •---•   •   •`
-
-var blueYellowHalf3 = `
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•
-| R   B   G   Y         |                       |               |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                                     y |                       |
-•   •   •   •---•   •   •   •   •   •---•   •   •   •   •   •   •
-|           | y                                                 |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•
-|                   | b                     | r                 |
-•---•   •---•   •   •---•   •   •   •   •   •---•   •   •   •   •
-|         r |                                                   |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•   •
-|                 g |                                   | b     |
-•   •   •   •   •---•   •   •   •   •   •---•   •   •   •   •   •
-|                                         g |                   |
-•   •   •   •   •   •   •   •---•---•   •   •   •   •   •   •   •
-|                           |       |               | y         |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •---•   •   •`
-
-var blueYellowHalf4 = `
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•
-| R   B   G   Y         |           |                           |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                                                 y |           |
-•   •   •   •---•   •   •   •   •   •   •   •   •---•   •   •   •
-|           | y                         | r                     |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •
-|                   | b                                         |
-•---•   •---•   •   •---•   •   •   •   •   •   •   •   •   •---•
-|         r |                                                   |
-•   •   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •
-|                 g |                         g |               |
-•   •   •   •   •---•   •   •   •   •   •   •   •   •   •---•   •
-|                                                       | b     |
-•   •   •   •   •   •   •   •---•---•   •   •   •   •   •   •   •
-|                           |       |   | r                     |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •`
-
-var redQuandrants = []string{
-	`
-|                   | y     |    
-•   •   •---•   •   •---•   •---•
-|       | g                      
-•   •   •   •   •   •   •   •   •
-|                                
-•   •   •   •   •   •   •   •   •
-|                                
-•---•   •   •   •   •   •   •   •
-|                                
-•   •   •   •   •---•   •   •   •
-|                 b |            
-•   •   •   •   •   •   •   •   •
-|     r |                        
-•   •---•   •   •   •   •   •   •
-|                       |        
-•---•---•---•---•---•---•---•---•`,
-	`
-|                           |    
-•   •   •   •   •   •   •   •---•
-|                                
-•   •   •   •   •   •   •   •   •
-|                   | y          
-•   •---•   •   •   •---•   •   •
-|   | g                          
-•   •   •   •   •   •   •---•   •
-|                         b |    
-•   •   •   •   •   •   •   •   •
-|                                
-•---•   •   •   •   •   •   •   •
-|             r |                
-•   •   •   •---•   •   •   •   •
-|                       |        
-•---•---•---•---•---•---•---•---•`,
-	`
-|                     r |   |    
-•   •   •   •   •   •---•   •---•
-|                                
-•   •   •---•   •   •   •   •   •
-|         b |                    
-•   •   •   •   •   •   •   •   •
-|                                
-•---•   •   •   •   •   •   •   •
-|                                
-•   •   •   •   •---•   •   •   •
-|               | g              
-•   •   •   •   •   •   •   •   •
-|                       | y      
-•   •   •   •   •   •   •---•   •
-|                   |            
-•---•---•---•---•---•---•---•---•`,
-	`
-|                           |    
-•   •   •---•   •   •   •   •---•
-|         b |                    
-•   •   •   •   •   •   •   •   •
-|                       | y      
-•---•   •   •   •   •   •---•   •
-|                                
-•   •   •   •   •   •   •   •   •
-|                                
-•   •   •   •   •   •   •   •   •
-|                 r |            
-•   •   •   •   •---•   •   •   •
-|               | g              
-•   •   •   •   •   •   •   •   •
-|           |                    
-•---•---•---•---•---•---•---•---•`,
-}
-
-var greenQuandrants = []string{
-	`
-   |                           |
----•   •   •   •   •   •   •---•
-           | b                 |
-   •   •   •---•   •   •   •   •
-                               |
-   •   •   •   •   •   •---•   •
-                         r |   |
-   •---•   •   •   •   •   •   •
-   | y                         |
-   •   •   •   •   •   •   •   •
-                               |
-   •   •   •   •   •   •   •   •
-                 g |           |
-   •   •   •   •---•   •   •   •
-                       |       |
----•---•---•---•---•---•---•---•`,
-	`
-   |                           |
----•   •   •   •---•   •   •   •
-                 r |           |
-   •   •   •   •   •   •   •---•
-   | b                         |
-   •---•   •   •   •   •   •   •
-                               |
-   •   •   •   •   •   •   •   •
-                               |
-   •   •   •   •   •   •   •   •
-                         g |   |
-   •---•   •   •   •   •---•   •
-   | y                         |
-   •   •   •   •   •   •   •   •
-           |                   |
----•---•---•---•---•---•---•---•`,
-	`
-   |                           |
----•   •   •   •---•   •   •   •
-                 b |           |
-   •   •   •   •   •   •   •---•
-                               |
-   •   •   •   •   •   •   •   •
-                               |
-   •---•   •   •   •   •   •   •
-   | y                         |
-   •   •   •   •   •   •   •   •
-                       | g     |
-   •   •   •   •   •   •---•   •
-             r |               |
-   •   •   •---•   •   •   •   •
-                       |       |
----•---•---•---•---•---•---•---•`,
-	`
-   |                           |
----•   •   •   •---•   •   •   •
-                 b | g         |
-   •   •   •   •   •---•   •---•
-                               |
-   •---•   •   •   •   •   •   •
-   | y                         |
-   •   •   •   •   •   •   •   •
-                         r |   |
-   •   •   •   •   •   •---•   •
-                               |
-   •   •   •   •   •   •   •   •
-                               |
-   •   •   •   •   •   •   •   •
-       |                       |
----•---•---•---•---•---•---•---•`,
-}
-
-var redGreenHalf1 = `
-|                   | y     |       |                           |
-•   •   •---•   •   •---•   •---•---•   •   •   •   •   •   •---•
-|       | g                                 | b                 |
-•   •   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •
-|                                                               |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•   •
-|                                                         r |   |
-•---•   •   •   •   •   •   •   •   •---•   •   •   •   •   •   •
-|                                   | y                         |
-•   •   •   •   •---•   •   •   •   •   •   •   •   •   •   •   •
-|                 b |                                           |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|     r |                                         g |           |
-•   •---•   •   •   •   •   •   •   •   •   •   •---•   •   •   •
-|                       |                               |       |
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•`
-
-var redGreenHalf2 = `
-|                           |       |                           |
-•   •   •   •   •   •   •   •---•---•   •   •   •   •   •   •---•
-|                                           | b                 |
-•   •   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •
-|                   | y                                         |
-•   •---•   •   •   •---•   •   •   •   •   •   •   •   •---•   •
-|   | g                                                   r |   |
-•   •   •   •   •   •   •---•   •   •---•   •   •   •   •   •   •
-|                         b |       | y                         |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                                                               |
-•---•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|             r |                                 g |           |
-•   •   •   •---•   •   •   •   •   •   •   •   •---•   •   •   •
-|                       |                               |       |
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•`
-
-var redGreenHalf3 = `
-|                           |       |                           |
-•   •   •   •   •   •   •   •---•---•   •   •   •---•   •   •   •
-|                                                 r |           |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•
-|                   | y             | b                         |
-•   •---•   •   •   •---•   •   •   •---•   •   •   •   •   •   •
-|   | g                                                         |
-•   •   •   •   •   •   •---•   •   •   •   •   •   •   •   •   •
-|                         b |                                   |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                                                         g |   |
-•---•   •   •   •   •   •   •   •   •---•   •   •   •   •---•   •
-|             r |                   | y                         |
-•   •   •   •---•   •   •   •   •   •   •   •   •   •   •   •   •
-|                       |                   |                   |
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•`
-
-var redGreenHalf4 = `
-|                   | y     |       |                           |
-•   •   •---•   •   •---•   •---•---•   •   •   •---•   •   •   •
-|       | g                                       r |           |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•
-|                                   | b                         |
-•   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •   •
-|                                                               |
-•---•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                                                               |
-•   •   •   •   •---•   •   •   •   •   •   •   •   •   •   •   •
-|                 b |                                     g |   |
-•   •   •   •   •   •   •   •   •   •---•   •   •   •   •---•   •
-|     r |                           | y                         |
-•   •---•   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                       |                   |                   |
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•`
-
-/*
-var redQuad1 = `
-•---•---•---•---•---•---•---•---•
-|                   |
-•   •   •---•   •   •   •   •   •
-|       | y
-•   •   •   •   •   •   •   •   •
-|
-•   •   •   •   •   •   •   •   •
-|                       | b
-•   •   •   •   •   •   •---•   •
-|
-•---•   •   •   •---•   •   •   •
-|                 r |
-•   •   •   •   •   •   •   •   •
-|     g |
-•   •---•   •   •   •   •   •---•
-|                           |
-•   •   •   •   •   •   •   •   •`
-*/
-
-var extremeBoard = `
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•
-|                   |                   |                       |
-•   •   •   •   •   •   •   •   •   •   •   •   •---•   •   •   •
-|                           |                   |               |
-•   •---•   •   •   •   •---•   •   •   •   •   •   •   •   •---•
-|   |                 R                   y   b                 |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|             r   y   b   g         |         g   b   y         |
-•   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •   •
-|         r   g   g   b                           y         |   |
-•   •   •   •   •   •   •---•   •   •   •---•   •   •   •---•   •
-|                 r         |     r         |         r         |
-•---•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|           |         r                       G       g         |
-•   •   •   •---•   •   •   •---•---•   •   •   •   •   •   •   •
-|     B           r         |       |     b           b         |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|         y       g         |       |     g   y       y         |
-•   •   •   •   •   •   •   •---•---•   •   •   •   •   •   •---•
-|               |     b                   r       b             |
-•   •   •   •---•   •   •   •   •---•   •   •   •   •---•   •   •
-|                 b             |             r     |           |
-•---•   •   •   •   •---•   •   •   •   •   •   •   •   •   •   •
-|         g         |         Y             |                   |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •
-|             g   y       g   b   r   y       g   g     | y     |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•   •
-|   |         r                               r                 |
-•   •---•   •   •   •   •---•   •   •---•   •   •   •   •   •   •
-|                           |           |                       |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                   |                           |               |
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•`
-
-var blank = `
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•
-|                   |                   |                       |
-•   •   •   •   •   •   •   •   •   •   •   •   •---•   •   •   •
-|                         y |                   | r             |
-•   •---•   •   •   •   •---•   •   •   •   •   •   •   •   •---•
-|   | g                                                         |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                                   | b                         |
-•   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •   •
-|                                                         g |   |
-•   •   •   •   •   •   •---•   •   •   •---•   •   •   •---•   •
-|                         b |             y |                   |
-•---•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|           | r                                                 |
-•   •   •   •---•   •   •   •---•---•   •   •   •   •   •   •   •
-|                           |       |                           |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                           |       |                           |
-•   •   •   •   •   •   •   •---•---•   •   •   •   •   •   •---•
-|             b |                                               |
-•   •   •   •---•   •   •   •   •---•   •   •   •   •---•   •   •
-|                                   |               | r         |
-•---•   •   •   •   •---•   •   •   •   •   •   •   •   •   •   •
-|                   | r                   g |                   |
-•   •   •   •   •   •   •   •   •   •   •---•   •   •   •   •   •
-|                                                       | y     |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •---•   •
-|   | y                                                         |
-•   •---•   •   •   •   •---•   •   •---•   •   •   •   •   •   •
-|                         g |         b |                       |
-•   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •   •
-|                   |                           |               |
-•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•---•`
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Board sets live in a directory each with a set.json manifest. Quadrant sets keep their
+// tiles in top-left, top-right, bottom-left and bottom-right subdirectories, fixed sets keep
+// complete boards directly in the set directory. Boards and tiles are named by their index
+// i.e. 0.txt, 1.json since the index is stored in puzzle IDs.
+//
+// Files ending in .txt use the ascii format read by parseBoard, files ending in .json use
+// boardJSON.
+
+var quadrantDirs = [4]string{"top-left", "top-right", "bottom-left", "bottom-right"}
+
+type boardSetManifest struct {
+	Name         string   `json:"name"`
+	Index        int      `json:"index"`
+	Description  string   `json:"description"`
+	Difficulties []string `json:"difficulties"`
+	// minimum number of goals of each color on every tile (quadrant sets) or board (fixed sets)
+	MinGoalsPerColor int `json:"minGoalsPerColor"`
+}
+
+// boardJSON is the json variant of the board format. Walls are listed per square using the side
+// letters U, D, L and R and are mirrored onto the neighbouring square automatically
+type boardJSON struct {
+	Width   int          `json:"width"`
+	Height  int          `json:"height"`
+	Walls   []squareJSON `json:"walls"`
+	Goals   []squareJSON `json:"goals"`
+	Blocked []squareJSON `json:"blocked"`
+	Robots  []squareJSON `json:"robots"`
+}
+
+type squareJSON struct {
+	Row   int    `json:"row"`
+	Col   int    `json:"col"`
+	Sides string `json:"sides,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
+// loadBoardSets loads and validates every board set in dir
+func loadBoardSets(dir string) ([]*boardSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading board sets: %v", err)
+	}
+
+	var sets []*boardSet
+	names := make(map[string]bool)
+	indices := make(map[byte]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		bs, err := loadBoardSet(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("board set %s: %v", entry.Name(), err)
+		}
+		if names[bs.name] {
+			return nil, fmt.Errorf("duplicate board set name: %s", bs.name)
+		}
+		if indices[bs.index] {
+			return nil, fmt.Errorf("duplicate board set index: %d", bs.index)
+		}
+		names[bs.name] = true
+		indices[bs.index] = true
+		sets = append(sets, bs)
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].index < sets[j].index
+	})
+	return sets, nil
+}
+
+func loadBoardSet(dir string) (*boardSet, error) {
+	buf, err := os.ReadFile(filepath.Join(dir, "set.json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %v", err)
+	}
+	var manifest boardSetManifest
+	if err := json.Unmarshal(buf, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %v", err)
+	}
+	if manifest.Name == "" {
+		return nil, fmt.Errorf("manifest is missing a name")
+	}
+	if manifest.Index < 0 || manifest.Index > 255 {
+		return nil, fmt.Errorf("index %d does not fit in a puzzle ID", manifest.Index)
+	}
+	if manifest.MinGoalsPerColor == 0 {
+		manifest.MinGoalsPerColor = 1
+	}
+
+	bs := &boardSet{
+		name:        manifest.Name,
+		description: manifest.Description,
+		index:       byte(manifest.Index),
+	}
+	for _, d := range manifest.Difficulties {
+		diff := parseDifficulty(d)
+		if diff == UNKNOWN {
+			return nil, fmt.Errorf("unknown difficulty: %s", d)
+		}
+		bs.difficulties = append(bs.difficulties, diff)
+	}
+	if len(bs.difficulties) == 0 {
+		return nil, fmt.Errorf("manifest does not list any difficulties")
+	}
+
+	// quadrant set
+	if _, err := os.Stat(filepath.Join(dir, quadrantDirs[0])); err == nil {
+		for corner, name := range quadrantDirs {
+			tiles, err := loadNumberedBoards(filepath.Join(dir, name), true)
+			if err != nil {
+				return nil, err
+			}
+			for idx, tile := range tiles {
+				if err := validateTile(corner, tile, manifest.MinGoalsPerColor); err != nil {
+					return nil, fmt.Errorf("%s tile %d: %v", name, idx, err)
+				}
+			}
+			bs.tiles[corner] = tiles
+		}
+		if err := bs.validateCombinations(); err != nil {
+			return nil, err
+		}
+		return bs, nil
+	}
+
+	// fixed set
+	boards, err := loadNumberedBoards(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	for idx, b := range boards {
+		if err := validateBoard(b); err != nil {
+			return nil, fmt.Errorf("board %d: %v", idx, err)
+		}
+		if err := validateGoalCounts(b, manifest.MinGoalsPerColor); err != nil {
+			return nil, fmt.Errorf("board %d: %v", idx, err)
+		}
+	}
+	bs.boards = boards
+	return bs, nil
+}
+
+// loadNumberedBoards loads 0.txt, 1.json, ... from dir. Indices must be contiguous
+func loadNumberedBoards(dir string, tiles bool) ([]*game, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading boards: %v", err)
+	}
+
+	files := make(map[int]string)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || entry.Name() == "set.json" || (ext != ".txt" && ext != ".json") {
+			continue
+		}
+		idx, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ext))
+		if err != nil {
+			return nil, fmt.Errorf("%s: board files must be named by their index", entry.Name())
+		}
+		if _, ok := files[idx]; ok {
+			return nil, fmt.Errorf("duplicate board index %d in %s", idx, dir)
+		}
+		files[idx] = filepath.Join(dir, entry.Name())
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no boards in %s", dir)
+	}
+
+	boards := make([]*game, len(files))
+	for idx := range boards {
+		path, ok := files[idx]
+		if !ok {
+			return nil, fmt.Errorf("missing board %d in %s", idx, dir)
+		}
+		b, err := loadBoardFile(path, tiles)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		boards[idx] = b
+	}
+	return boards, nil
+}
+
+// loadBoardFile reads a single board or tile in either the ascii or json format
+func loadBoardFile(path string, tile bool) (*game, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".json" {
+		return parseBoardJSON(buf)
+	}
+	var g game
+	if tile {
+		g, err = parseTile(string(buf))
+	} else {
+		g, err = parseBoard(string(buf), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func parseBoardJSON(buf []byte) (*game, error) {
+	var bj boardJSON
+	if err := json.Unmarshal(buf, &bj); err != nil {
+		return nil, fmt.Errorf("parsing board json: %v", err)
+	}
+	if bj.Width <= 0 || bj.Height <= 0 {
+		return nil, fmt.Errorf("board must have a positive width and height")
+	}
+	if bj.Width*bj.Height > maxBoardSquares {
+		return nil, fmt.Errorf("board is %dx%d, at most %d squares are supported", bj.Width, bj.Height, maxBoardSquares)
+	}
+
+	g := &game{
+		size:   bj.Width,
+		board:  make([]square, bj.Width*bj.Height),
+		robots: make(map[byte]*robot),
+		cache:  make(map[uint32]int),
+	}
+	position := func(sq squareJSON) (int, error) {
+		if sq.Row < 0 || sq.Row >= bj.Height || sq.Col < 0 || sq.Col >= bj.Width {
+			return 0, fmt.Errorf("square (%d, %d) is outside the board", sq.Row, sq.Col)
+		}
+		return sq.Row*bj.Width + sq.Col, nil
+	}
+
+	for _, w := range bj.Walls {
+		pos, err := position(w)
+		if err != nil {
+			return nil, err
+		}
+		for _, side := range strings.ToUpper(w.Sides) {
+			switch side {
+			case 'U':
+				g.board[pos] |= square(UP)
+			case 'D':
+				g.board[pos] |= square(DOWN)
+			case 'L':
+				g.board[pos] |= square(LEFT)
+			case 'R':
+				g.board[pos] |= square(RIGHT)
+			default:
+				return nil, fmt.Errorf("square (%d, %d) has unknown wall side %q", w.Row, w.Col, side)
+			}
+		}
+	}
+	mirrorWalls(g)
+
+	for _, b := range bj.Blocked {
+		pos, err := position(b)
+		if err != nil {
+			return nil, err
+		}
+		g.board[pos] |= square(BLOCKED)
+	}
+	for _, goal := range bj.Goals {
+		pos, err := position(goal)
+		if err != nil {
+			return nil, err
+		}
+		id, err := colorID(goal.Color)
+		if err != nil {
+			return nil, err
+		}
+		g.goals = append(g.goals, Goal{id: id, position: uint32(pos)})
+	}
+	for _, r := range bj.Robots {
+		pos, err := position(r)
+		if err != nil {
+			return nil, err
+		}
+		id, err := colorID(r.Color)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := g.robots[id]; ok {
+			return nil, fmt.Errorf("duplicate %s robot", r.Color)
+		}
+		g.board[pos] |= square(ROBOT)
+		g.robots[id] = &robot{id: id, position: uint32(pos)}
+	}
+	if len(g.goals) > 0 {
+		g.activeGoal = g.goals[0]
+	}
+	g.activeRobot = g.robots[g.activeGoal.id]
+
+	return g, nil
+}
+
+// colorID converts a color name i.e. "red" or "R" into a robot id
+func colorID(color string) (byte, error) {
+	switch strings.ToLower(color) {
+	case "r", "red":
+		return 'R', nil
+	case "g", "green":
+		return 'G', nil
+	case "b", "blue":
+		return 'B', nil
+	case "y", "yellow":
+		return 'Y', nil
+	default:
+		return 0, fmt.Errorf("unknown color: %q", color)
+	}
+}
+
+// mirrorWalls makes sure a wall on one side of an edge also blocks the square on the other side
+func mirrorWalls(g *game) {
+	rows := g.height()
+	for pos := range g.board {
+		row, col := pos/g.size, pos%g.size
+		if g.board[pos]&square(RIGHT) != 0 && col+1 < g.size {
+			g.board[pos+1] |= square(LEFT)
+		}
+		if g.board[pos]&square(LEFT) != 0 && col > 0 {
+			g.board[pos-1] |= square(RIGHT)
+		}
+		if g.board[pos]&square(DOWN) != 0 && row+1 < rows {
+			g.board[pos+g.size] |= square(UP)
+		}
+		if g.board[pos]&square(UP) != 0 && row > 0 {
+			g.board[pos-g.size] |= square(DOWN)
+		}
+	}
+}
+
+// validateBoard checks that a complete board can be played on: the outer edge is walled so
+// robots can't leave the board, walls are consistent on both sides and blocked squares are
+// walled off so robots can never enter them
+func validateBoard(g *game) error {
+	rows := g.height()
+	for pos, sq := range g.board {
+		row, col := pos/g.size, pos%g.size
+		if row == 0 && sq&square(UP) == 0 {
+			return fmt.Errorf("square (%d, %d): top edge of the board is not walled", row, col)
+		}
+		if row == rows-1 && sq&square(DOWN) == 0 {
+			return fmt.Errorf("square (%d, %d): bottom edge of the board is not walled", row, col)
+		}
+		if col == 0 && sq&square(LEFT) == 0 {
+			return fmt.Errorf("square (%d, %d): left edge of the board is not walled", row, col)
+		}
+		if col == g.size-1 && sq&square(RIGHT) == 0 {
+			return fmt.Errorf("square (%d, %d): right edge of the board is not walled", row, col)
+		}
+
+		if col+1 < g.size && (sq&square(RIGHT) != 0) != (g.board[pos+1]&square(LEFT) != 0) {
+			return fmt.Errorf("square (%d, %d): right wall does not match its neighbour", row, col)
+		}
+		if row+1 < rows && (sq&square(DOWN) != 0) != (g.board[pos+g.size]&square(UP) != 0) {
+			return fmt.Errorf("square (%d, %d): bottom wall does not match its neighbour", row, col)
+		}
+
+		// blocked squares must be enclosed from every open neighbour
+		if sq&square(BLOCKED) == 0 {
+			continue
+		}
+		neighbours := []struct {
+			dir  direction
+			pos  int
+			edge bool
+		}{
+			{UP, pos - g.size, row == 0},
+			{DOWN, pos + g.size, row == rows-1},
+			{LEFT, pos - 1, col == 0},
+			{RIGHT, pos + 1, col == g.size-1},
+		}
+		for _, n := range neighbours {
+			if n.edge || g.board[n.pos]&square(BLOCKED) != 0 {
+				continue
+			}
+			if sq&square(n.dir) == 0 {
+				return fmt.Errorf("square (%d, %d): blocked square is not enclosed on its %s side", row, col, n.dir)
+			}
+		}
+	}
+
+	for _, goal := range g.goals {
+		if g.board[goal.position]&square(BLOCKED) != 0 {
+			return fmt.Errorf("goal at square %d is blocked", goal.position)
+		}
+	}
+	return nil
+}
+
+// validateTile checks a quadrant tile before it is combined with others. Edges on the outside
+// of the board must be walled. Edges shared with another tile are drawn by the top or left
+// tile only, so the bottom and right tiles must leave them open. The tile's corner of the
+// board center must be blocked
+func validateTile(corner int, tile *game, minGoalsPerColor int) error {
+	size := tile.size
+	if len(tile.board) != size*size {
+		return fmt.Errorf("quadrant tiles must be square")
+	}
+	top, left := corner < 2, corner%2 == 0
+
+	for i := 0; i < size; i++ {
+		topSq := tile.board[i]
+		bottomSq := tile.board[(size-1)*size+i]
+		leftSq := tile.board[i*size]
+		rightSq := tile.board[i*size+size-1]
+
+		if top && topSq&square(UP) == 0 {
+			return fmt.Errorf("top edge is not walled at column %d", i)
+		}
+		if !top && topSq&square(UP) != 0 {
+			return fmt.Errorf("top edge is shared with the tile above and must be open at column %d", i)
+		}
+		if !top && bottomSq&square(DOWN) == 0 {
+			return fmt.Errorf("bottom edge is not walled at column %d", i)
+		}
+		if left && leftSq&square(LEFT) == 0 {
+			return fmt.Errorf("left edge is not walled at row %d", i)
+		}
+		if !left && leftSq&square(LEFT) != 0 {
+			return fmt.Errorf("left edge is shared with the tile to the left and must be open at row %d", i)
+		}
+		if !left && rightSq&square(RIGHT) == 0 {
+			return fmt.Errorf("right edge is not walled at row %d", i)
+		}
+	}
+
+	centerRow, centerCol := size-1, size-1
+	if !top {
+		centerRow = 0
+	}
+	if !left {
+		centerCol = 0
+	}
+	if tile.board[centerRow*size+centerCol]&square(BLOCKED) == 0 {
+		return fmt.Errorf("center square (%d, %d) is not blocked", centerRow, centerCol)
+	}
+
+	if len(tile.robots) > 0 {
+		return fmt.Errorf("tiles can't contain robots")
+	}
+
+	return validateGoalCounts(tile, minGoalsPerColor)
+}
+
+func validateGoalCounts(g *game, minGoalsPerColor int) error {
+	counts := make(map[byte]int)
+	for _, goal := range g.goals {
+		counts[goal.id]++
+	}
+	for _, id := range possibleRobots {
+		if counts[id] < minGoalsPerColor {
+			return fmt.Errorf("has %d %c goals, needs at least %d", counts[id], id, minGoalsPerColor)
+		}
+	}
+	return nil
+}
+
+// validateCombinations builds every combination of tiles to make sure they fit together
+func (bs *boardSet) validateCombinations() error {
+	size := bs.tiles[0][0].size
+	for corner, tiles := range bs.tiles {
+		for idx, tile := range tiles {
+			if tile.size != size {
+				return fmt.Errorf("%s tile %d is %dx%d but other tiles are %dx%d", quadrantDirs[corner], idx, tile.size, tile.size, size, size)
+			}
+		}
+	}
+
+	for tl := range bs.tiles[0] {
+		for tr := range bs.tiles[1] {
+			for bl := range bs.tiles[2] {
+				for br := range bs.tiles[3] {
+					layout := []int{tl, tr, bl, br}
+					g, err := bs.build(layout)
+					if err != nil {
+						return fmt.Errorf("layout %v: %v", layout, err)
+					}
+					if err := validateBoard(&g); err != nil {
+						return fmt.Errorf("layout %v: %v", layout, err)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
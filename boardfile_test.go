@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadBoardSets(t *testing.T) {
+	sets, err := loadBoardSets("boards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx, bs := range sets {
+		if idx > 0 && sets[idx-1].index >= bs.index {
+			t.Fatalf("board sets not sorted by index")
+		}
+	}
+}
+
+func TestParseBoardJSON(t *testing.T) {
+	input := `{
+		"width": 3,
+		"height": 3,
+		"walls": [
+			{"row": 0, "col": 0, "sides": "UL"}, {"row": 0, "col": 1, "sides": "U"}, {"row": 0, "col": 2, "sides": "UR"},
+			{"row": 1, "col": 0, "sides": "L"}, {"row": 1, "col": 2, "sides": "R"},
+			{"row": 2, "col": 0, "sides": "DL"}, {"row": 2, "col": 1, "sides": "D"}, {"row": 2, "col": 2, "sides": "DR"},
+			{"row": 1, "col": 1, "sides": "UDLR"}
+		],
+		"blocked": [{"row": 1, "col": 1}],
+		"goals": [
+			{"row": 0, "col": 0, "color": "red"}, {"row": 0, "col": 2, "color": "green"},
+			{"row": 2, "col": 0, "color": "blue"}, {"row": 2, "col": 2, "color": "yellow"}
+		]
+	}`
+	g, err := parseBoardJSON([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateBoard(g); err != nil {
+		t.Fatalf("expected valid board: %v", err)
+	}
+
+	// walls are mirrored so the ascii form round trips
+	formatted := formatBoard(g)
+	parsed, err := parseBoard(formatted, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatBoard(&parsed) != formatted {
+		t.Fatalf("json board does not round trip through ascii:\n%s", formatted)
+	}
+
+	// opening the center leaves the blocked square reachable
+	g.board[1] &^= square(DOWN)
+	g.board[4] &^= square(UP)
+	if err := validateBoard(g); err == nil || !strings.Contains(err.Error(), "not enclosed") {
+		t.Fatalf("expected unenclosed blocked square to be rejected, got: %v", err)
+	}
+}
+
+func TestParseBoardJSONActiveRobot(t *testing.T) {
+	input := `{
+		"width": 2,
+		"height": 1,
+		"walls": [{"row": 0, "col": 0, "sides": "UDL"}, {"row": 0, "col": 1, "sides": "UDR"}],
+		"robots": [{"row": 0, "col": 0, "color": "red"}, {"row": 0, "col": 1, "color": "blue"}],
+		"goals": [{"row": 0, "col": 0, "color": "blue"}]
+	}`
+	g, err := parseBoardJSON([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.activeRobot == nil || g.activeRobot.id != 'B' {
+		t.Fatalf("active robot should match the goal color, got %+v", g.activeRobot)
+	}
+}
+
+func TestValidateTile(t *testing.T) {
+	for corner, tiles := range classicSet.tiles {
+		for idx, tile := range tiles {
+			if err := validateTile(corner, tile, 1); err != nil {
+				t.Fatalf("%s tile %d: %v", quadrantDirs[corner], idx, err)
+			}
+		}
+	}
+
+	// the top right tile may not draw the edge it shares with the top left tile
+	tile := classicSet.tiles[1][0].clone()
+	tile.board[tile.size] |= square(LEFT)
+	if err := validateTile(1, &tile, 1); err == nil {
+		t.Fatalf("expected wall on shared edge to be rejected")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// boardSet is a family of boards that puzzles can be generated from. Quadrant sets build a
+// board by picking one tile for each corner, fixed sets pick one complete board. Board sets
+// are loaded from the boards directory on startup by loadBoards, see boardfile.go
+type boardSet struct {
+	name        string
+	description string
+	// index is stored in puzzle IDs so it must never change once assigned
+	index byte
+
+	// tiles for the top left, top right, bottom left and bottom right corners
+	tiles  [4][]*game
+	boards []*game
+
+	// difficulties this set can reliably generate puzzles for
+	difficulties []difficulty
+}
+
+var boardSets []*boardSet
+var classicSet *boardSet
+
+// loadBoards makes the board sets in dir available for puzzles. It must be called before any
+// puzzles are generated or decoded
+func loadBoards(dir string) error {
+	sets, err := loadBoardSets(dir)
+	if err != nil {
+		return err
+	}
+	boardSets = sets
+
+	classicSet = boardSetByName("classic")
+	if classicSet == nil {
+		return fmt.Errorf("missing classic board set in %s", dir)
+	}
+	return nil
+}
+
+func boardSetByName(name string) *boardSet {
+	for _, bs := range boardSets {
+		if bs.name == name {
+			return bs
+		}
+	}
+	return nil
+}
+
+func boardSetByIndex(index byte) *boardSet {
+	for _, bs := range boardSets {
+		if bs.index == index {
+			return bs
+		}
+	}
+	return nil
+}
+
+func (bs *boardSet) isQuadrantSet() bool {
+	return len(bs.tiles[0]) > 0
+}
+
+func (bs *boardSet) supports(diff difficulty) bool {
+	for _, d := range bs.difficulties {
+		if d == diff {
+			return true
+		}
+	}
+	return false
+}
+
+// build creates the board described by layout. For quadrant sets layout holds the index of each
+// corner tile, for fixed sets the first entry is the index of the board
+func (bs *boardSet) build(layout []int) (game, error) {
+	if len(layout) != 4 {
+		return game{}, fmt.Errorf("invalid board layout: %v", layout)
+	}
+
+	var g game
+	if bs.isQuadrantSet() {
+		var tiles [4]*game
+		for corner, idx := range layout {
+			if idx < 0 || idx >= len(bs.tiles[corner]) {
+				return game{}, fmt.Errorf("invalid tile %d for corner %d of set %s", idx, corner, bs.name)
+			}
+			tiles[corner] = bs.tiles[corner][idx]
+		}
+		g = stitchTiles(tiles)
+	} else {
+		if layout[0] < 0 || layout[0] >= len(bs.boards) {
+			return game{}, fmt.Errorf("invalid board index %d for set %s", layout[0], bs.name)
+		}
+		// anything else in the layout would be another spelling of the same board
+		if layout[1] != 0 || layout[2] != 0 || layout[3] != 0 {
+			return game{}, fmt.Errorf("set %s only uses the first layout entry, got %v", bs.name, layout)
+		}
+		g = bs.boards[layout[0]].clone()
+	}
+
+	g.quadrants = layout
+	g.set = bs
+	g.ensureRobots()
+	return g, nil
+}
+
+// stitchTiles places 4 quadrant tiles into a single board. Walls drawn on an edge shared by two
+// tiles block both sides
+func stitchTiles(tiles [4]*game) game {
+	half := tiles[0].size
+	size := half * 2
+
+	g := game{
+		size:   size,
+		board:  make([]square, size*size),
+		robots: make(map[byte]*robot),
+		cache:  make(map[uint32]int),
+	}
+	for corner, tile := range tiles {
+		rowOffset := (corner / 2) * half
+		colOffset := (corner % 2) * half
+		toBoard := func(pos uint32) uint32 {
+			row, col := int(pos)/half, int(pos)%half
+			return uint32((row+rowOffset)*size + col + colOffset)
+		}
+
+		for pos, sq := range tile.board {
+			g.board[toBoard(uint32(pos))] = sq
+		}
+		for _, goal := range tile.goals {
+			g.goals = append(g.goals, Goal{id: goal.id, position: toBoard(goal.position)})
+		}
+	}
+	mirrorWalls(&g)
+	g.activeGoal = g.goals[0]
+
+	return g
+}
+
+// random picks a random board from the set and returns the layout needed to rebuild it
+func (bs *boardSet) random() (game, []int) {
+	layout := []int{0, 0, 0, 0}
+	if bs.isQuadrantSet() {
+		for corner := range layout {
+			layout[corner] = rand.Intn(len(bs.tiles[corner]))
+		}
+	} else {
+		layout[0] = rand.Intn(len(bs.boards))
+	}
+
+	g, err := bs.build(layout)
+	if err != nil {
+		panic(fmt.Sprintf("random layout out of range: %v", err))
+	}
+	return g, layout
+}
+
+// parseBoardSets parses a comma separated list of board set names
+func parseBoardSets(in string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(in, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if boardSetByName(name) == nil {
+			return nil, fmt.Errorf("unknown board set: %s", name)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no board sets provided")
+	}
+	return names, nil
+}
+
+func (s *server) handleBoards(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding boards ack: %v", err)
+	}
+
+	// look up instance
+	instance := s.instanceFor(i)
+
+	var sb strings.Builder
+	if len(i.Interaction.ApplicationCommandData().Options) == 1 {
+		names, err := parseBoardSets(i.Interaction.ApplicationCommandData().Options[0].StringValue())
+		if err != nil {
+			sb.WriteString(fmt.Sprintf(":x: %v\n\n", err))
+		} else {
+			if err := s.updateConfig(instance, func(gc *guildConfig) { gc.BoardSets = names }); err != nil {
+				log.Printf("saving board sets: %v", err)
+			}
+			for _, bs := range instance.enabledSets() {
+				s.ensureCategorizer(bs)
+			}
+			s.wakeGenerator()
+			sb.WriteString(":white_check_mark: Board sets updated\n\n")
+		}
+	}
+
+	enabled := make(map[string]bool)
+	for _, bs := range instance.enabledSets() {
+		enabled[bs.name] = true
+	}
+	sb.WriteString("**Board Sets**:\n")
+	for _, bs := range boardSets {
+		marker := ":black_large_square:"
+		if enabled[bs.name] {
+			marker = ":white_check_mark:"
+		}
+		sb.WriteString(fmt.Sprintf("%s **%s**: %s\n", marker, bs.name, bs.description))
+	}
+
+	content := sb.String()
+	_, err = dg.InteractionResponseEdit(i.Interaction,
+		&discordgo.WebhookEdit{
+			Content: &content,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("sending boards response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// the board sets are loaded once for every test, as the server does on startup
+func TestMain(m *testing.M) {
+	if err := loadBoards("boards"); err != nil {
+		fmt.Fprintf(os.Stderr, "loading board sets: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+func TestBoardSetsEncodeDecode(t *testing.T) {
+	for _, bs := range boardSets {
+		g := randomGameFromSet(bs)
+		id, err := encode(g)
+		if err != nil {
+			t.Fatalf("%s: %v", bs.name, err)
+		}
+
+		decoded, err := decode(id)
+		if err != nil {
+			t.Fatalf("%s: %v", bs.name, err)
+		}
+		if decoded.set != bs {
+			t.Fatalf("%s: decoded into board set %s", bs.name, decoded.set.name)
+		}
+
+		printed := printBoard(g.board, g.size, g.robots, g.activeGoal)
+		if printed != printBoard(decoded.board, decoded.size, decoded.robots, decoded.activeGoal) {
+			t.Fatalf("%s: printed boards don't match", bs.name)
+		}
+
+		if _, err := render(decoded); err != nil {
+			t.Fatalf("%s: rendering: %v", bs.name, err)
+		}
+	}
+}
+
+func TestParseBoardSets(t *testing.T) {
+	names, err := parseBoardSets(" Classic, mini ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "classic" || names[1] != "mini" {
+		t.Fatalf("unexpected board sets: %v", names)
+	}
+
+	if _, err := parseBoardSets("classic,nope"); err == nil {
+		t.Fatalf("expected unknown board set to be rejected")
+	}
+}
@@ -10,12 +10,52 @@ import (
 )
 
 type categorizer struct {
+	set     *boardSet
 	easy    chan (*game)
 	medium  chan (*game)
 	hard    chan (*game)
 	extreme chan (*game)
 }
 
+func newCategorizer(set *boardSet) *categorizer {
+	return &categorizer{
+		set:     set,
+		easy:    make(chan (*game), gameBuffer),
+		medium:  make(chan (*game), gameBuffer),
+		hard:    make(chan (*game), gameBuffer),
+		extreme: make(chan (*game), gameBuffer),
+	}
+}
+
+func (c *categorizer) bank(diff difficulty) chan (*game) {
+	switch diff {
+	case EASY:
+		return c.easy
+	case MEDIUM:
+		return c.medium
+	case HARD:
+		return c.hard
+	case EXTREME:
+		return c.extreme
+	default:
+		return nil
+	}
+}
+
+// isFull reports whether every difficulty the board set supports has a full buffer.
+// Extreme puzzles are only collected opportunistically
+func (c *categorizer) isFull() bool {
+	for _, diff := range c.set.difficulties {
+		if diff == EXTREME {
+			continue
+		}
+		if len(c.bank(diff)) < gameBuffer {
+			return false
+		}
+	}
+	return true
+}
+
 func weightSolution(solution string) int {
 	moves := strings.Split(solution, "-")
 	//TODO: count how many unique robots used? or is just moves better
@@ -33,9 +73,11 @@ var possibleRobots = []byte{'R', 'G', 'B', 'Y'}
 // select random starting locations
 
 func randomGame() *game {
-	//g := parseBoard(fullBoard)
-	boardStr, quadrants := randomBoard()
-	g := parseBoard(boardStr, quadrants)
+	return randomGameFromSet(classicSet)
+}
+
+func randomGameFromSet(set *boardSet) *game {
+	g, _ := set.random()
 	//g.quadrants = quandrants
 	// select random goal
 	rand.Seed(time.Now().UnixNano())
@@ -49,27 +91,28 @@ func randomGame() *game {
 	// randomly place robots
 	// can't be where another robot is
 	// can't be on goal
-	// TODO: can't be on middle / maybe X character marks invalid squares
+	// can't be on a blocked square i.e. middle
 
 	// grab random squares for each robot that aren't a goal tile
-	possibleSquares := make([]uint32, g.size*g.size)
-	for i := 0; i < g.size*g.size; i++ {
+	possibleSquares := make([]uint32, len(g.board))
+	for i := 0; i < len(g.board); i++ {
 		possibleSquares[i] = uint32(i)
 	}
 	rand.Shuffle(len(possibleSquares), func(i, j int) { possibleSquares[i], possibleSquares[j] = possibleSquares[j], possibleSquares[i] })
 
+	// toggle off existing robot bits first so a robot placed on another robot's old square
+	// doesn't lose its bit when that robot moves
 	for _, robot := range g.robots {
-		// toggle off existing robot bit
 		g.board[robot.position] = g.board[robot.position] &^ square(ROBOT)
-
+	}
+	for _, robot := range g.robots {
 		// grab a random square from candidate list,
 		// try again if grabbed square is invalid
 		for {
 			pop := possibleSquares[len(possibleSquares)-1]
 			possibleSquares = possibleSquares[:len(possibleSquares)-1]
 
-			// TODO: check other reasons a spot may be invalid i.e middle
-			if pop != g.activeGoal.position {
+			if pop != g.activeGoal.position && g.board[pop]&square(BLOCKED) == 0 {
 				g.board[pop] |= square(ROBOT)
 				robot.position = pop
 				break
@@ -85,8 +128,29 @@ func randomGame() *game {
 	return &g
 }
 
+// wakeGenerator asks the generator to top up the puzzle buffers. The first call starts the
+// generator. Calls made while it is already generating are merged into one more pass
+func (s *server) wakeGenerator() {
+	s.generatorOnce.Do(func() {
+		s.generatorWake = make(chan struct{}, 1)
+		go s.superviseGenerator()
+	})
+	select {
+	case s.generatorWake <- struct{}{}:
+	default:
+	}
+}
+
+// superviseGenerator is the only caller of lookForSolutions once the bot is running so there
+// is never more than one set of workers filling the buffers
+func (s *server) superviseGenerator() {
+	for range s.generatorWake {
+		lookForSolutions(s)
+	}
+}
+
+// lookForSolutions generates puzzles until every enabled board set's buffers are full
 func lookForSolutions(s *server) {
-	s.isSearching = true // not thread-safe but probably will never matter
 	var wg sync.WaitGroup
 	for x := 0; x < 4; x++ {
 		wg.Add(1)
@@ -94,11 +158,19 @@ func lookForSolutions(s *server) {
 			defer wg.Done()
 
 			for {
-				if len(s.categorizer.easy) == gameBuffer && len(s.categorizer.medium) == gameBuffer && len(s.categorizer.hard) == gameBuffer {
+				// generate for whichever enabled board set still needs puzzles
+				var c *categorizer
+				for _, candidate := range s.activeCategorizers() {
+					if !candidate.isFull() {
+						c = candidate
+						break
+					}
+				}
+				if c == nil {
 					break
 				}
 
-				rg := randomGame()
+				rg := randomGameFromSet(c.set)
 				rg.precomputedMoves = rg.preCompute(rg.activeGoal.position)
 				res := rg.solve(20)
 				moves, _ := parseMoves(res)
@@ -107,46 +179,22 @@ func lookForSolutions(s *server) {
 
 				// Add solution to proper buffer. Discard if that buffer already has enough solutions
 				// of that length
-				if numMoves >= 6 && numMoves <= 8 {
-					select {
-					case s.categorizer.easy <- rg:
-						rg.difficulty = EASY
-						fmt.Println("Easy found:")
-					default:
-						//			fmt.Println("discarding easy")
-					}
-				} else if numMoves >= 9 && numMoves <= 12 {
-					select {
-					case s.categorizer.medium <- rg:
-						rg.difficulty = MEDIUM
-						fmt.Println("Medium found:")
-					default:
-						//			fmt.Println("discarding medium")
-					}
-				} else if numMoves >= 13 && numMoves <= 16 {
-					select {
-					case s.categorizer.hard <- rg:
-						rg.difficulty = HARD
-						fmt.Println("Hard found:", numMoves)
-					default:
-						fmt.Println("Hard found:", numMoves)
-						//			fmt.Println("discarding hard")
-					}
-				} else if numMoves >= 17 && numMoves <= 20 {
-					fmt.Println("EXTREME found:", numMoves)
-					select {
-					case s.categorizer.extreme <- rg:
-						rg.difficulty = EXTREME
-						fmt.Println("EXTREME found:", numMoves)
-					default:
-						//			fmt.Println("discarding hard")
-					}
+				diff := difficultyFromMoves(numMoves)
+				if !c.set.supports(diff) {
+					continue
+				}
+				rg.difficulty = diff
+				// re-encode now the solution is known so the ID carries it
+				if id, err := encode(rg); err == nil {
+					rg.id = id
+				}
+				select {
+				case c.bank(diff) <- rg:
+					fmt.Printf("%s %s found: %d\n", c.set.name, diff, numMoves)
+				default:
 				}
-
 			}
 		}()
 	}
 	wg.Wait()
-	s.isSearching = false
-
 }
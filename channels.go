@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Each game channel runs its own puzzles, tournaments and rounds. A guild always has a primary
+// channel, admins can add more with /config add_channel. s.instances and s.primaryChannels
+// are guarded by s.instancesLock since guilds join while commands are being handled
+
+// instanceFor returns the game channel a command was used in. Commands used outside a game
+// channel fall back to the guild's primary channel. DMs get a private practice instance
+func (s *server) instanceFor(i *discordgo.InteractionCreate) *discordInstance {
+	if i.GuildID == "" {
+		return s.practiceInstance(i)
+	}
+	s.instancesLock.RLock()
+	defer s.instancesLock.RUnlock()
+	if instance, ok := s.instances[i.ChannelID]; ok {
+		return instance
+	}
+	return s.instances[s.primaryChannels[i.GuildID]]
+}
+
+// primaryChannel returns the channel used for commands outside a game channel
+func (s *server) primaryChannel(guildID string) string {
+	s.instancesLock.RLock()
+	defer s.instancesLock.RUnlock()
+	return s.primaryChannels[guildID]
+}
+
+// guildInstances returns every game channel in the guild
+func (s *server) guildInstances(guildID string) []*discordInstance {
+	s.instancesLock.RLock()
+	defer s.instancesLock.RUnlock()
+	var instances []*discordInstance
+	for _, instance := range s.instances {
+		if instance.serverID == guildID {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+// addGameChannel starts tracking a channel's puzzles. Does nothing if it is already a game
+// channel
+func (s *server) addGameChannel(guildID, channelID string, config guildConfig) *discordInstance {
+	s.instancesLock.Lock()
+	defer s.instancesLock.Unlock()
+	return s.addGameChannelLocked(guildID, channelID, config)
+}
+
+// addGameChannelLocked must be called with s.instancesLock held
+func (s *server) addGameChannelLocked(guildID, channelID string, config guildConfig) *discordInstance {
+	if instance, ok := s.instances[channelID]; ok {
+		return instance
+	}
+	instance := &discordInstance{
+		serverID:  guildID,
+		channelID: channelID,
+		config:    config,
+		clock:     s.clock,
+	}
+	s.instances[channelID] = instance
+	return instance
+}
+
+// removeGameChannel stops tracking a channel's puzzles. The primary channel can't be removed
+func (s *server) removeGameChannel(guildID, channelID string) error {
+	s.instancesLock.Lock()
+	defer s.instancesLock.Unlock()
+
+	if s.primaryChannels[guildID] == channelID {
+		return fmt.Errorf("<#%s> is the primary channel, choose a different **channel** first", channelID)
+	}
+	instance, ok := s.instances[channelID]
+	if !ok || instance.serverID != guildID {
+		return fmt.Errorf("<#%s> is not a game channel", channelID)
+	}
+	if instance.gameInProgress() {
+		return fmt.Errorf("<#%s> has a game in progress, try again once it is over", channelID)
+	}
+	delete(s.instances, channelID)
+	return nil
+}
+
+// setPrimaryChannel makes channelID the guild's primary channel. The old primary channel keeps
+// running only if it was also added as an extra channel
+func (s *server) setPrimaryChannel(guildID, channelID string, config guildConfig) {
+	s.instancesLock.Lock()
+	defer s.instancesLock.Unlock()
+
+	old := s.primaryChannels[guildID]
+	s.addGameChannelLocked(guildID, channelID, config)
+	s.primaryChannels[guildID] = channelID
+	if old == "" || old == channelID || containsString(config.Channels, old) {
+		return
+	}
+	if instance, ok := s.instances[old]; ok && !instance.gameInProgress() {
+		delete(s.instances, old)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func interactionIn(guildID, channelID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{GuildID: guildID, ChannelID: channelID},
+	}
+}
+
+func TestGameChannels(t *testing.T) {
+	s := &server{
+		instances:       make(map[string]*discordInstance),
+		primaryChannels: make(map[string]string),
+	}
+	config := defaultGuildConfig()
+	s.setPrimaryChannel("guild", "ricochet", config)
+	s.addGameChannel("guild", "ricochet-hard", config)
+	s.setPrimaryChannel("other", "other-ricochet", config)
+
+	// commands are routed to the channel they were used in
+	primary := s.instanceFor(interactionIn("guild", "ricochet"))
+	hard := s.instanceFor(interactionIn("guild", "ricochet-hard"))
+	if primary == nil || hard == nil || primary == hard {
+		t.Fatalf("expected independent instances per channel")
+	}
+	if got := s.instanceFor(interactionIn("guild", "general")); got != primary {
+		t.Fatalf("command outside a game channel was not routed to the primary channel")
+	}
+	if n := len(s.guildInstances("guild")); n != 2 {
+		t.Fatalf("expected 2 game channels, got %d", n)
+	}
+
+	// settings are shared across the guild but not with other guilds
+	if err := s.updateConfig(hard, func(gc *guildConfig) { gc.LockoutMinutes = 1 }); err != nil {
+		t.Fatal(err)
+	}
+	if primary.config.LockoutMinutes != 1 {
+		t.Fatalf("config change did not reach the primary channel")
+	}
+	if s.instanceFor(interactionIn("other", "other-ricochet")).config.LockoutMinutes != 5 {
+		t.Fatalf("config change leaked into another guild")
+	}
+
+	if err := s.removeGameChannel("guild", "ricochet"); err == nil {
+		t.Fatalf("removed the primary channel")
+	}
+	if err := s.removeGameChannel("other", "ricochet-hard"); err == nil {
+		t.Fatalf("removed another guild's channel")
+	}
+	if err := s.removeGameChannel("guild", "ricochet-hard"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.instanceFor(interactionIn("guild", "ricochet-hard")); got != primary {
+		t.Fatalf("removed channel still has its own instance")
+	}
+
+	// moving the primary channel drops the old one
+	s.setPrimaryChannel("guild", "ricochet-new", config)
+	if _, ok := s.instances["ricochet"]; ok {
+		t.Fatalf("old primary channel was not removed")
+	}
+	if s.instanceFor(interactionIn("guild", "general")).channelID != "ricochet-new" {
+		t.Fatalf("commands not routed to the new primary channel")
+	}
+}
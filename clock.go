@@ -0,0 +1,35 @@
+package main
+
+import "time"
+
+// clock is how the server reads and waits on time. Tests swap in a fake clock to fast forward
+// through tournaments, rounds and lockouts
+type clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clockOrReal returns c, falling back to the real clock if none was set
+func clockOrReal(c clock) clock {
+	if c == nil {
+		return realClock{}
+	}
+	return c
+}
+
+func (s *server) clk() clock {
+	return clockOrReal(s.clock)
+}
+
+func (di *discordInstance) clk() clock {
+	return clockOrReal(di.clock)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when the test advances it
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return fc.now
+}
+
+func (fc *fakeClock) Since(t time.Time) time.Duration {
+	return fc.Now().Sub(t)
+}
+
+func (fc *fakeClock) Sleep(d time.Duration) {
+	<-fc.After(d)
+}
+
+func (fc *fakeClock) After(d time.Duration) <-chan time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- fc.now
+		return c
+	}
+	fc.timers = append(fc.timers, fakeTimer{at: fc.now.Add(d), c: c})
+	return c
+}
+
+// Advance moves the clock forward, firing every timer that comes due
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.now = fc.now.Add(d)
+	sort.Slice(fc.timers, func(a, b int) bool { return fc.timers[a].at.Before(fc.timers[b].at) })
+	var pending []fakeTimer
+	for _, timer := range fc.timers {
+		if timer.at.After(fc.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.c <- fc.now
+	}
+	fc.timers = pending
+}
+
+// waitForTimers waits until n timers are waiting on the clock, so advancing it can't race
+// with the code about to sleep
+func (fc *fakeClock) waitForTimers(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 10)
+	for time.Now().Before(deadline) {
+		fc.lock.Lock()
+		waiting := len(fc.timers)
+		fc.lock.Unlock()
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d timers waiting on the clock", n)
+}
+
+func TestFakeClock(t *testing.T) {
+	fc := newFakeClock()
+	start := fc.Now()
+	woke := make(chan time.Time)
+	go func() {
+		fc.Sleep(time.Minute)
+		woke <- fc.Now()
+	}()
+
+	fc.waitForTimers(t, 1)
+	fc.Advance(time.Second * 59)
+	select {
+	case <-woke:
+		t.Fatalf("woke up before the minute was up")
+	case <-time.After(time.Millisecond * 20):
+	}
+	fc.Advance(time.Second)
+	if at := <-woke; at.Sub(start) != time.Minute {
+		t.Fatalf("woke up after %v, expected 1m", at.Sub(start))
+	}
+}
+
+func TestPuzzleLockout(t *testing.T) {
+	puzzles := testPuzzles(t)
+	s, fm := newTestServer(t, puzzles)
+	fc := s.clock.(*fakeClock)
+
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "puzzle"))
+	fm.waitFor(t, testChannel, "#__"+puzzles[0].id+"__")
+
+	fc.Advance(defaultGuildConfig().lockout() - time.Second)
+	early := command(testGuild, testChannel, "bob", "puzzle")
+	s.handleInteraction(fm, early)
+	if reply := fm.reply(early); !strings.Contains(reply, "must be solved optimally") {
+		t.Fatalf("expected lockout, got %q", reply)
+	}
+
+	fc.Advance(time.Second * 2)
+	later := command(testGuild, testChannel, "bob", "puzzle")
+	s.handleInteraction(fm, later)
+	if reply := fm.reply(later); reply != "Puzzle created successfully" {
+		t.Fatalf("puzzle still locked out: %q", reply)
+	}
+	fm.waitFor(t, testChannel, "#__"+puzzles[1].id+"__")
+}
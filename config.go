@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// modes that admins can turn on and off with /config
+var configurableModes = []string{"tournament", "race", "speed", "bid", "create"}
+
+// guildConfig holds the settings an admin can change with /config
+type guildConfig struct {
+	// channel puzzles are posted in. Empty means look for a channel named ricochet
+	ChannelID string `json:"channelId,omitempty"`
+	// additional channels that run their own puzzles
+	Channels []string `json:"channels,omitempty"`
+	// how long a puzzle must be open before another can be requested, unless solved optimally
+	LockoutMinutes    int    `json:"lockoutMinutes"`
+	DefaultDifficulty string `json:"defaultDifficulty"`
+	// post a message in the channel whenever someone solves the active puzzle
+	AnnounceSolves bool `json:"announceSolves"`
+	// hide shared solutions behind spoiler tags
+	Spoilers bool `json:"spoilers"`
+	// enabled modes, null means every mode is enabled and empty means none are
+	Modes []string `json:"modes"`
+	// names of the board sets puzzles are drawn from. Defaults to classic
+	BoardSets []string `json:"boardSets,omitempty"`
+}
+
+func defaultGuildConfig() guildConfig {
+	return guildConfig{
+		LockoutMinutes:    5,
+		DefaultDifficulty: "medium",
+		AnnounceSolves:    true,
+		Spoilers:          true,
+	}
+}
+
+func (gc guildConfig) lockout() time.Duration {
+	return time.Minute * time.Duration(gc.LockoutMinutes)
+}
+
+// closeTimeout is how long a puzzle stays open without an optimal solution. Rounds stay open
+// at least as long as the lockout since a closed puzzle can't block the next one
+func (gc guildConfig) closeTimeout() time.Duration {
+	if lockout := gc.lockout(); lockout > puzzleCloseTimeout {
+		return lockout
+	}
+	return puzzleCloseTimeout
+}
+
+func (gc guildConfig) defaultDifficulty() difficulty {
+	switch d := parseDifficulty(gc.DefaultDifficulty); d {
+	case EASY, MEDIUM, HARD:
+		return d
+	default:
+		return MEDIUM
+	}
+}
+
+func (gc guildConfig) modeEnabled(mode string) bool {
+	return gc.Modes == nil || containsString(gc.Modes, mode)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// removeString returns list without any occurrences of s
+func removeString(list []string, s string) []string {
+	var out []string
+	for _, item := range list {
+		if item != s {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseModes parses a comma separated list of modes to enable. "all" enables every mode
+func parseModes(in string) ([]string, error) {
+	if strings.TrimSpace(strings.ToLower(in)) == "all" {
+		return nil, nil
+	}
+	modes := []string{}
+	for _, part := range strings.Split(in, ",") {
+		mode := strings.TrimSpace(strings.ToLower(part))
+		if mode == "" || mode == "none" {
+			continue
+		}
+		if !containsString(configurableModes, mode) {
+			return nil, fmt.Errorf("unknown mode %q, expected one of: %s", mode, strings.Join(configurableModes, ", "))
+		}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
+// guildConfigs is the persisted settings of every guild keyed by guild ID
+type guildConfigs struct {
+	path string
+
+	lock    sync.RWMutex
+	configs map[string]guildConfig
+}
+
+// loadGuildConfigs reads the config file at path. A missing file means every guild uses the
+// defaults
+func loadGuildConfigs(path string) (*guildConfigs, error) {
+	gcs := &guildConfigs{
+		path:    path,
+		configs: make(map[string]guildConfig),
+	}
+
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return gcs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading guild configs: %v", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &raw); err != nil {
+		return nil, fmt.Errorf("parsing guild configs: %v", err)
+	}
+	// settings missing from the file keep their defaults
+	for guildID, data := range raw {
+		gc := defaultGuildConfig()
+		if err := json.Unmarshal(data, &gc); err != nil {
+			return nil, fmt.Errorf("parsing config for guild %s: %v", guildID, err)
+		}
+		gcs.configs[guildID] = gc
+	}
+	return gcs, nil
+}
+
+// get returns the guild's settings or the defaults if it has never been configured
+func (gcs *guildConfigs) get(guildID string) guildConfig {
+	if gcs == nil {
+		return defaultGuildConfig()
+	}
+	gcs.lock.RLock()
+	defer gcs.lock.RUnlock()
+
+	gc, ok := gcs.configs[guildID]
+	if !ok {
+		return defaultGuildConfig()
+	}
+	return gc
+}
+
+// set replaces the guild's settings and persists them
+func (gcs *guildConfigs) set(guildID string, gc guildConfig) error {
+	if gcs == nil {
+		return nil
+	}
+	gcs.lock.Lock()
+	defer gcs.lock.Unlock()
+
+	gcs.configs[guildID] = gc
+	if gcs.path == "" {
+		return nil
+	}
+	return writeJSONFile(gcs.path, gcs.configs)
+}
+
+// updateConfig applies changes to the guild's settings, persists them and shares them with
+// every game channel in the guild
+func (s *server) updateConfig(instance *discordInstance, update func(gc *guildConfig)) error {
+	gc := instance.settings()
+	update(&gc)
+	for _, other := range s.guildInstances(instance.serverID) {
+		other.setConfig(gc)
+	}
+	return s.guildConfigs.set(instance.serverID, gc)
+}
+
+// modeDisabled returns the message shown when an admin has turned off a mode, or ""
+func (instance *discordInstance) modeDisabled(mode string) string {
+	if instance.settings().modeEnabled(mode) {
+		return ""
+	}
+	return fmt.Sprintf(":x: **/%s** has been disabled by a server admin", mode)
+}
+
+func (s *server) handleConfig(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding config ack: %v", err)
+	}
+
+	// look up instance
+	instance := s.instanceFor(i)
+
+	var sb strings.Builder
+	updated := instance.settings()
+	var problems []string
+	var removeChannel string
+	data := i.Interaction.ApplicationCommandData()
+	for _, opt := range data.Options {
+		switch opt.Name {
+		case "channel":
+			channelID, ok := textChannelOption(data, opt)
+			if !ok {
+				problems = append(problems, "channel must be a text channel")
+				continue
+			}
+			updated.ChannelID = channelID
+		case "add_channel":
+			channelID, ok := textChannelOption(data, opt)
+			if !ok {
+				problems = append(problems, "add_channel must be a text channel")
+				continue
+			}
+			if !containsString(updated.Channels, channelID) {
+				updated.Channels = append(updated.Channels, channelID)
+			}
+		case "remove_channel":
+			removeChannel = opt.Value.(string)
+			updated.Channels = removeString(updated.Channels, removeChannel)
+		case "lockout":
+			updated.LockoutMinutes = int(opt.IntValue())
+		case "difficulty":
+			updated.DefaultDifficulty = opt.StringValue()
+		case "announce_solves":
+			updated.AnnounceSolves = opt.BoolValue()
+		case "spoilers":
+			updated.Spoilers = opt.BoolValue()
+		case "modes":
+			modes, err := parseModes(opt.StringValue())
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			updated.Modes = modes
+		}
+	}
+
+	if len(problems) == 0 && removeChannel != "" {
+		if err := s.removeGameChannel(i.GuildID, removeChannel); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, p := range problems {
+			sb.WriteString(fmt.Sprintf(":x: %s\n", p))
+		}
+		sb.WriteString("\n")
+	} else if len(i.Interaction.ApplicationCommandData().Options) > 0 {
+		if err := s.updateConfig(instance, func(gc *guildConfig) { *gc = updated }); err != nil {
+			return fmt.Errorf("saving guild config: %v", err)
+		}
+		for _, channelID := range updated.Channels {
+			s.addGameChannel(i.GuildID, channelID, updated)
+		}
+		if updated.ChannelID != "" {
+			s.setPrimaryChannel(i.GuildID, updated.ChannelID, updated)
+		}
+		sb.WriteString(":white_check_mark: Settings updated\n\n")
+	}
+	sb.WriteString(configContent(s.instanceFor(i), s.primaryChannel(i.GuildID)))
+
+	content := sb.String()
+	_, err = dg.InteractionResponseEdit(i.Interaction,
+		&discordgo.WebhookEdit{
+			Content: &content,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("sending config response: %v", err)
+	}
+	return nil
+}
+
+// textChannelOption returns the ID of the channel chosen for opt, checking it is a text channel
+// when discord included its details
+func textChannelOption(data discordgo.ApplicationCommandInteractionData, opt *discordgo.ApplicationCommandInteractionDataOption) (string, bool) {
+	channelID, ok := opt.Value.(string)
+	if !ok || channelID == "" {
+		return "", false
+	}
+	if data.Resolved != nil {
+		if ch, ok := data.Resolved.Channels[channelID]; ok && ch.Type != discordgo.ChannelTypeGuildText {
+			return "", false
+		}
+	}
+	return channelID, true
+}
+
+func configContent(instance *discordInstance, primaryChannelID string) string {
+	gc := instance.settings()
+	modes := "all"
+	if gc.Modes != nil {
+		modes = strings.Join(gc.Modes, ", ")
+		if modes == "" {
+			modes = "none"
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("**Settings**:\n")
+	sb.WriteString(fmt.Sprintf("  **channel**: <#%s>\n", primaryChannelID))
+	if len(gc.Channels) > 0 {
+		var channels []string
+		for _, channelID := range gc.Channels {
+			channels = append(channels, fmt.Sprintf("<#%s>", channelID))
+		}
+		sb.WriteString(fmt.Sprintf("  **game channels**: %s\n", strings.Join(channels, ", ")))
+	}
+	sb.WriteString(fmt.Sprintf("  **lockout**: %d minutes\n", gc.LockoutMinutes))
+	sb.WriteString(fmt.Sprintf("  **difficulty**: %s\n", gc.defaultDifficulty()))
+	sb.WriteString(fmt.Sprintf("  **announce_solves**: %t\n", gc.AnnounceSolves))
+	sb.WriteString(fmt.Sprintf("  **spoilers**: %t\n", gc.Spoilers))
+	sb.WriteString(fmt.Sprintf("  **modes**: %s\n", modes))
+	return sb.String()
+}
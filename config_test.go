@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGuildConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guilds.json")
+	gcs, err := loadGuildConfigs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gc := gcs.get("guild"); !reflect.DeepEqual(gc, defaultGuildConfig()) {
+		t.Fatalf("unconfigured guild got %+v", gc)
+	}
+
+	gc := defaultGuildConfig()
+	gc.ChannelID = "channel"
+	gc.LockoutMinutes = 0
+	gc.AnnounceSolves = false
+	gc.Modes = []string{}
+	gc.BoardSets = []string{"mini"}
+	if err := gcs.set("guild", gc); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := loadGuildConfigs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.get("guild"); !reflect.DeepEqual(got, gc) {
+		t.Fatalf("reloaded config %+v does not match %+v", got, gc)
+	}
+	if reloaded.get("guild").modeEnabled("race") {
+		t.Fatalf("disabling every mode did not survive a reload")
+	}
+}
+
+func TestGuildConfigDefaults(t *testing.T) {
+	// settings added after a guild was configured fall back to their defaults
+	path := filepath.Join(t.TempDir(), "guilds.json")
+	if err := os.WriteFile(path, []byte(`{"guild": {"lockoutMinutes": 2}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gcs, err := loadGuildConfigs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gc := gcs.get("guild")
+	if gc.lockout() != 2*time.Minute {
+		t.Fatalf("lockout %s, expected 2m", gc.lockout())
+	}
+	if !gc.AnnounceSolves || !gc.Spoilers || gc.defaultDifficulty() != MEDIUM || !gc.modeEnabled("tournament") {
+		t.Fatalf("missing settings did not default: %+v", gc)
+	}
+
+	gc.DefaultDifficulty = "extreme"
+	if gc.defaultDifficulty() != MEDIUM {
+		t.Fatalf("unsupported difficulty was not replaced with medium")
+	}
+}
+
+func TestParseModes(t *testing.T) {
+	modes, err := parseModes("Race, speed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(modes, []string{"race", "speed"}) {
+		t.Fatalf("parsed %v", modes)
+	}
+	gc := guildConfig{Modes: modes}
+	if !gc.modeEnabled("race") || gc.modeEnabled("bid") {
+		t.Fatalf("unexpected enabled modes for %v", modes)
+	}
+
+	if modes, err := parseModes("all"); err != nil || modes != nil {
+		t.Fatalf("all parsed as %v, %v", modes, err)
+	}
+	if modes, err := parseModes("none"); err != nil || modes == nil || len(modes) != 0 {
+		t.Fatalf("none parsed as %v, %v", modes, err)
+	}
+	if _, err := parseModes("race,chess"); err == nil {
+		t.Fatalf("expected unknown mode to be rejected")
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxCustomBoardBytes limits the size of uploaded boards. A full 16x16 ascii board is ~2.5KB
+const maxCustomBoardBytes = 64 * 1024
+
+// maxCustomSolveDepth is how deep custom boards are searched for a solution
+const maxCustomSolveDepth = maxArchiveSolveDepth
+
+// maxCustomSolves limits how many custom boards are solved at once across every guild
+const maxCustomSolves = 2
+
+// customSolveTimeout is how long a custom board is searched before it's rejected
+var customSolveTimeout = 30 * time.Second
+
+var customBoardClient = &http.Client{Timeout: 10 * time.Second}
+
+func (s *server) handleCreate(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding create ack: %v", err)
+	}
+
+	respond := func(content string) error {
+		_, err := dg.InteractionResponseEdit(i.Interaction,
+			&discordgo.WebhookEdit{
+				Content: &content,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("editing create response: %v", err)
+		}
+		return nil
+	}
+
+	if i.Interaction.Member == nil {
+		return respond("Custom puzzles can only be created in a server")
+	}
+
+	// look up instance
+	instance := s.instanceFor(i)
+	if reason := instance.modeDisabled("create"); reason != "" {
+		return respond(reason)
+	}
+	if reason := instance.newPuzzleBlocked(); reason != "" {
+		return respond(reason)
+	}
+
+	input, filename, err := customBoardInput(i.ApplicationCommandData())
+	if err != nil {
+		return respond(fmt.Sprintf(":x: %v", err))
+	}
+
+	g, err := parseCustomBoard(input, filename)
+	if err != nil {
+		return respond(fmt.Sprintf(":x: Invalid board: %v", err))
+	}
+	g.author = displayName(i.Interaction.Member)
+
+	// the channel and a solve slot are reserved before solving so /create can't be spammed
+	if reason := instance.claimPuzzle(); reason != "" {
+		return respond(reason)
+	}
+	if !s.acquireCustomSolve() {
+		instance.releasePuzzle()
+		return respond(":x: Too many custom boards are being solved right now, please try again in a minute")
+	}
+
+	// solving can take a while so it doesn't hold up the handler
+	go func() {
+		defer s.releaseCustomSolve()
+		if err := s.startCustomPuzzle(dg, instance, i.Interaction.Member, g, respond); err != nil {
+			log.Printf("creating custom puzzle: %v", err)
+		}
+	}()
+	return nil
+}
+
+// acquireCustomSolve reserves one of the slots for solving a custom board, returning false if
+// they're all in use
+func (s *server) acquireCustomSolve() bool {
+	s.customSolvesOnce.Do(func() { s.customSolves = make(chan struct{}, maxCustomSolves) })
+	select {
+	case s.customSolves <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *server) releaseCustomSolve() {
+	<-s.customSolves
+}
+
+// startCustomPuzzle solves a parsed custom board and posts it as the next puzzle. The channel
+// must already be claimed, it is released if the board can't be played
+func (s *server) startCustomPuzzle(dg messenger, instance *discordInstance, member *discordgo.Member, g *game, respond func(string) error) error {
+	if err := prepareCustomPuzzle(g); err != nil {
+		instance.releasePuzzle()
+		return respond(fmt.Sprintf(":x: %v", err))
+	}
+	s.startRound(dg, instance, g)
+
+	err := postPuzzle(dg, instance.channelID, puzzleContent(member, g), g)
+	if err != nil {
+		respond(":x: Unable to post puzzle, please try again later")
+		return err
+	}
+
+	return respond(fmt.Sprintf("Puzzle #%s created successfully. The optimal solution is %d moves", g.id, g.lenOptimalSolution))
+}
+
+// customBoardInput downloads the uploaded board or falls back to the json option. Returns the
+// board along with the uploaded filename, if any
+func customBoardInput(data discordgo.ApplicationCommandInteractionData) ([]byte, string, error) {
+	for _, opt := range data.Options {
+		switch opt.Name {
+		case "file":
+			if data.Resolved == nil {
+				return nil, "", fmt.Errorf("missing uploaded file")
+			}
+			attachment, ok := data.Resolved.Attachments[opt.Value.(string)]
+			if !ok {
+				return nil, "", fmt.Errorf("missing uploaded file")
+			}
+			if attachment.Size > maxCustomBoardBytes {
+				return nil, "", fmt.Errorf("board file is too large")
+			}
+			buf, err := downloadCustomBoard(attachment.URL)
+			if err != nil {
+				log.Printf("downloading custom board: %v", err)
+				return nil, "", fmt.Errorf("unable to download board file")
+			}
+			return buf, attachment.Filename, nil
+		case "json":
+			return []byte(opt.StringValue()), "board.json", nil
+		}
+	}
+	return nil, "", fmt.Errorf("Please upload a board file or provide a json board. See boards/ in the bot's repository for examples")
+}
+
+func downloadCustomBoard(url string) ([]byte, error) {
+	resp, err := customBoardClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxCustomBoardBytes))
+}
+
+// parseCustomBoard reads a board in either the ascii or json format. json is detected by file
+// extension or a leading {
+func parseCustomBoard(input []byte, filename string) (*game, error) {
+	if filepath.Ext(filename) == ".json" || bytes.HasPrefix(bytes.TrimSpace(input), []byte("{")) {
+		return parseBoardJSON(input)
+	}
+	g, err := parseBoard(string(input), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// prepareCustomPuzzle checks a user designed board is playable, solves it and assigns its ID.
+// The first goal on the board is the one that must be reached
+func prepareCustomPuzzle(g *game) error {
+	if err := validateBoard(g); err != nil {
+		return fmt.Errorf("Invalid board: %v", err)
+	}
+	for _, id := range possibleRobots {
+		r, ok := g.robots[id]
+		if !ok {
+			return fmt.Errorf("Board is missing the %c robot", id)
+		}
+		if g.board[r.position]&square(BLOCKED) != 0 {
+			return fmt.Errorf("%c robot is on a blocked square", id)
+		}
+	}
+	if len(g.goals) == 0 {
+		return fmt.Errorf("Board has no goal")
+	}
+	g.activeGoal = g.goals[0]
+	g.activeRobot = g.robots[g.activeGoal.id]
+	if g.activeRobot.position == g.activeGoal.position {
+		return fmt.Errorf("%c robot is already on its goal", g.activeGoal.id)
+	}
+
+	g.precomputedMoves = g.preCompute(g.activeGoal.position)
+	cpy := g.clone()
+	cpy.deadline = time.Now().Add(customSolveTimeout)
+	moves, err := parseMoves(cpy.solve(maxCustomSolveDepth))
+	if cpy.timedOut {
+		return fmt.Errorf("Board took too long to solve, try a board with a shorter solution")
+	}
+	if err != nil || len(moves) == 0 {
+		return fmt.Errorf("Board has no solution in %d moves or less", maxCustomSolveDepth)
+	}
+	g.moves = moves
+	g.lenOptimalSolution = len(moves)
+	g.difficulty = difficultyFromMoves(len(moves))
+
+	id, err := encodeCustom(g)
+	if err != nil {
+		return fmt.Errorf("encoding custom board: %v", err)
+	}
+	g.id = id
+	return nil
+}
+
+// isCustom reports whether the board was designed by a player rather than drawn from a board
+// set. The author knows the answer so custom puzzles never earn rewards
+func (g *game) isCustom() bool {
+	return g.set == nil
+}
+
+func displayName(member *discordgo.Member) string {
+	if member.Nick != "" {
+		return member.Nick
+	}
+	return member.User.Username
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/njones/base58"
+)
+
+const customBoard = `
+•---•---•---•---•---•
+| R           G     |
+•   •   •---•   •   •
+|       | X |       |
+•   •   •---•   •   •
+|     b   Y |       |
+•---•   •   •   •   •
+| B                 |
+•---•---•---•---•---•
+`
+
+func TestCreateCustomPuzzle(t *testing.T) {
+	g, err := parseCustomBoard([]byte(customBoard), "board.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareCustomPuzzle(g); err != nil {
+		t.Fatal(err)
+	}
+	if !g.isCustom() || g.lenOptimalSolution == 0 || !validate(g, g.board, g.moves, g.activeGoal) {
+		t.Fatalf("expected a solved custom puzzle, got %d moves", g.lenOptimalSolution)
+	}
+
+	decoded, err := decode(g.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatBoard(decoded) != formatBoard(g) {
+		t.Fatalf("decoded board does not match:\n%s\n%s", formatBoard(g), formatBoard(decoded))
+	}
+	if decoded.activeGoal != g.activeGoal || decoded.activeRobot.id != 'B' {
+		t.Fatalf("decoded goal does not match")
+	}
+	if optimalLength(decoded) != g.lenOptimalSolution {
+		t.Fatalf("decoded puzzle has a different solution length")
+	}
+
+	// truncated ids must be rejected rather than panic
+	buf, _ := base58.StdEncoding.DecodeString(g.id)
+	if _, err := decode(base58.StdEncoding.EncodeToString(buf[:len(buf)-1])); err == nil {
+		t.Fatalf("expected error decoding truncated custom id")
+	}
+}
+
+func TestCreateInvalidCustomPuzzle(t *testing.T) {
+	tests := []struct {
+		name  string
+		board string
+		err   string
+	}{
+		{"no goal", strings.Replace(customBoard, "b", " ", 1), "no goal"},
+		{"missing robot", strings.Replace(customBoard, "Y", " ", 1), "missing the Y robot"},
+		{"open edge", strings.Replace(customBoard, "| B", "  B", 1), "edge of the board must be walled"},
+		{"unsolvable", strings.Replace(customBoard, "•---•   •   •   •   •", "•---•---•---•---•---•", 1), "no solution"},
+	}
+	for _, tc := range tests {
+		g, err := parseCustomBoard([]byte(tc.board), "board.txt")
+		if err == nil {
+			err = prepareCustomPuzzle(g)
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected error containing %q, got %v", tc.name, tc.err, err)
+		}
+	}
+
+	// searches that run out of time are rejected rather than reported as unsolvable
+	defer func(timeout time.Duration) { customSolveTimeout = timeout }(customSolveTimeout)
+	customSolveTimeout = -time.Second
+	g, err := parseCustomBoard([]byte(customBoard), "board.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareCustomPuzzle(g); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Fatalf("expected solve to time out, got %v", err)
+	}
+}
+
+func TestCreateCommand(t *testing.T) {
+	s, fm := newTestServer(t, nil)
+	board := `{
+		"width": 3,
+		"height": 3,
+		"walls": [
+			{"row": 0, "col": 0, "sides": "UL"}, {"row": 0, "col": 1, "sides": "U"}, {"row": 0, "col": 2, "sides": "UR"},
+			{"row": 1, "col": 0, "sides": "L"}, {"row": 1, "col": 2, "sides": "R"},
+			{"row": 2, "col": 0, "sides": "DL"}, {"row": 2, "col": 1, "sides": "D"}, {"row": 2, "col": 2, "sides": "DR"}
+		],
+		"robots": [
+			{"row": 0, "col": 0, "color": "red"}, {"row": 0, "col": 2, "color": "green"},
+			{"row": 2, "col": 0, "color": "blue"}, {"row": 2, "col": 2, "color": "yellow"}
+		],
+		"goals": [{"row": 1, "col": 0, "color": "red"}]
+	}`
+
+	// custom boards are only solved while a slot is free
+	for n := 0; n < maxCustomSolves; n++ {
+		if !s.acquireCustomSolve() {
+			t.Fatalf("slot %d wasn't available", n)
+		}
+	}
+	busy := command(testGuild, testChannel, "alice", "create", "json", board)
+	s.handleInteraction(fm, busy)
+	if reply := fm.reply(busy); !strings.Contains(reply, "try again") {
+		t.Fatalf("custom board solved with every slot in use: %q", reply)
+	}
+	instance := s.instanceFor(busy)
+	if reason := instance.newPuzzleBlocked(); reason != "" {
+		t.Fatalf("rejected create left the channel blocked: %s", reason)
+	}
+	for n := 0; n < maxCustomSolves; n++ {
+		s.releaseCustomSolve()
+	}
+
+	create := command(testGuild, testChannel, "alice", "create", "json", board)
+	s.handleInteraction(fm, create)
+	// the channel is reserved while the board is solved
+	if reason := instance.newPuzzleBlocked(); reason == "" {
+		t.Fatalf("channel wasn't reserved for the custom board")
+	}
+	fm.waitFor(t, testChannel, "#__")
+	deadline := time.Now().Add(time.Second * 10)
+	for !strings.Contains(fm.reply(create), "created successfully") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 5)
+	}
+	if reply := fm.reply(create); !strings.Contains(reply, "optimal solution is 1 moves") {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+}
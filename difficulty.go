@@ -24,3 +24,35 @@ func (d difficulty) String() string {
 		return "unknown"
 	}
 }
+
+// difficultyFromMoves buckets an optimal solution length the same way the categorizer does
+func difficultyFromMoves(numMoves int) difficulty {
+	switch {
+	case numMoves >= 6 && numMoves <= 8:
+		return EASY
+	case numMoves >= 9 && numMoves <= 12:
+		return MEDIUM
+	case numMoves >= 13 && numMoves <= 16:
+		return HARD
+	case numMoves >= 17 && numMoves <= 20:
+		return EXTREME
+	default:
+		return UNKNOWN
+	}
+}
+
+// parseDifficulty converts a command option into a difficulty. Returns UNKNOWN if not recognized
+func parseDifficulty(s string) difficulty {
+	switch s {
+	case "easy":
+		return EASY
+	case "medium":
+		return MEDIUM
+	case "hard":
+		return HARD
+	case "extreme":
+		return EXTREME
+	default:
+		return UNKNOWN
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"log"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -36,11 +35,11 @@ func findChannel(dg *discordgo.Session, guildID string) (*discordgo.Channel, err
 	return nil, nil
 }
 
-func (s *server) handleHowToPlay(dg *discordgo.Session, i *discordgo.InteractionCreate) error {
+func (s *server) handleHowToPlay(dg messenger, i *discordgo.InteractionCreate) error {
 	return s.handleHelp(dg, i)
 }
 
-func (s *server) handleHelp(dg *discordgo.Session, i *discordgo.InteractionCreate) error {
+func (s *server) handleHelp(dg messenger, i *discordgo.InteractionCreate) error {
 	// ack
 	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
@@ -63,9 +62,20 @@ func (s *server) handleHelp(dg *discordgo.Session, i *discordgo.InteractionCreat
 	sb.WriteString("  **/share**: Share your solution to the current puzzle\n")
 	sb.WriteString("  **/how-to-play**: Additional explanation of game rules\n")
 	sb.WriteString("  **/tournament**: Start a 3 puzzle timed tournament\n")
+	sb.WriteString("  **/speed**: Timed puzzle where faster solutions score more points\n")
+	sb.WriteString("  **/bid**: Bid how many moves you can solve the current puzzle in\n")
+	sb.WriteString("  **/race**: Play through every goal on one board, robots stay where they end\n")
+	sb.WriteString("  **/boards**: Choose which board sets puzzles are drawn from\n")
+	sb.WriteString("  **/create**: Upload your own board as the next puzzle\n")
+	sb.WriteString("  **/stats**: Show your puzzle history, rating and achievements, or another player's\n")
+	sb.WriteString("  **/link**: Link your account on a community site to earn rewards\n")
+	sb.WriteString("  **/unlink**: Remove your linked account\n")
+	sb.WriteString("  **/config**: Change this server's settings (admin only)\n")
+	sb.WriteString("  **/rewards audit**: Check recent token rewards against the ledger (admin only)\n")
+	sb.WriteString("\nDM the bot **/puzzle** to practice privately at your own pace. Once you have a rating puzzles match your skill\n")
+	sb.WriteString(fmt.Sprintf("\nPuzzles close after %d minutes or the server's lockout if that's longer, or shortly after an optimal solution is found, with a recap of everyone's solutions\n", int(puzzleCloseTimeout.Minutes())))
 	sb.WriteString("\n**Coming Soon**:\n")
 	sb.WriteString("- Load specific puzzles\n")
-	sb.WriteString("- More Boards\n")
 	sb.WriteString("- Rules Variants\n")
 	sb.WriteString("\n**How to Play**:\n")
 	sb.WriteString("1. robots may only move Up, Down, Left, or Right\n")
@@ -103,7 +113,7 @@ func (s *server) handleHelp(dg *discordgo.Session, i *discordgo.InteractionCreat
 	return nil
 }
 
-func (s *server) handlePuzzle(dg *discordgo.Session, i *discordgo.InteractionCreate) error {
+func (s *server) handlePuzzle(dg messenger, i *discordgo.InteractionCreate) error {
 
 	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
@@ -117,28 +127,49 @@ func (s *server) handlePuzzle(dg *discordgo.Session, i *discordgo.InteractionCre
 	}
 
 	// look up instance
-	instance := s.instances[i.GuildID]
+	instance := s.instanceFor(i)
 
-	// haven't solved current puzzle
-	if instance.activeGame != nil {
-		//if instance.solutionTracker.numSubmitted() == 0
-		optimalFound := len(instance.getSolutions(instance.activeGame.id).currentBest()) == instance.activeGame.lenOptimalSolution
-		timePassed := time.Since(instance.puzzleTimestamp) > (time.Second * 60 * 5)
-		if !optimalFound && !timePassed {
-			content := "Current puzzle must be solved optimally or 5 minutes have passed before requesting a new one"
-			dg.InteractionResponseEdit(i.Interaction,
-				&discordgo.WebhookEdit{
-					Content: &content,
-				},
-			)
-			return nil
+	if reason := instance.claimPuzzle(); reason != "" {
+		_, err := dg.InteractionResponseEdit(i.Interaction,
+			&discordgo.WebhookEdit{
+				Content: &reason,
+			},
+		)
+		return err
+	}
+
+	diff := instance.settings().defaultDifficulty()
+	// practice puzzles match the player's skill once they have an established rating
+	if instance.practice {
+		if r := s.ratings.get(interactionUserID(i), s.clk().Now()); !r.provisional() {
+			diff = r.difficulty()
 		}
 	}
+	if len(i.Interaction.ApplicationCommandData().Options) > 0 {
+		if d := parseDifficulty(i.Interaction.ApplicationCommandData().Options[0].StringValue()); d == EASY || d == MEDIUM || d == HARD {
+			diff = d
+		}
+	}
+	g := s.servePuzzle(instance, diff)
+	s.startRound(dg, instance, g)
 
-	// tournament already in progress
-	if instance.activeTournament != nil {
-		content := "There is currently a tournament in progress. You may only request a puzzle after it is over"
-		_, err := dg.InteractionResponseEdit(i.Interaction,
+	var moveStrs []string
+	for _, m := range g.moves {
+		moveStrs = append(moveStrs, m.String())
+	}
+	fmt.Println("Optimal:", strings.Join(moveStrs, "-"))
+
+	// DMs have no member to credit the puzzle to
+	var content string
+	if instance.practice || i.Interaction.Member == nil {
+		content = practicePuzzleContent(g)
+	} else {
+		content = puzzleContent(i.Interaction.Member, g)
+	}
+	err = postPuzzle(dg, instance.channelID, content, g)
+	if err != nil {
+		content := ":x: Unable to create puzzle, please try again later"
+		dg.InteractionResponseEdit(i.Interaction,
 			&discordgo.WebhookEdit{
 				Content: &content,
 			},
@@ -146,38 +177,67 @@ func (s *server) handlePuzzle(dg *discordgo.Session, i *discordgo.InteractionCre
 		return err
 	}
 
-	var g *game
-	if len(i.Interaction.ApplicationCommandData().Options) == 0 {
-		g = <-s.categorizer.medium
-		g.difficulty = MEDIUM
-	} else if i.Interaction.ApplicationCommandData().Options[0].Value == "easy" {
-		g = <-s.categorizer.easy
-		g.difficulty = EASY
-	} else if i.Interaction.ApplicationCommandData().Options[0].Value == "medium" {
-		g = <-s.categorizer.medium
-		g.difficulty = MEDIUM
-	} else if i.Interaction.ApplicationCommandData().Options[0].Value == "hard" {
-		g = <-s.categorizer.hard
-		g.difficulty = HARD
-	} else {
-		g = <-s.categorizer.medium
-		g.difficulty = MEDIUM
+	content = "Puzzle created successfully"
+	_, err = dg.InteractionResponseEdit(i.Interaction,
+		&discordgo.WebhookEdit{
+			Content: &content,
+		},
+	)
+	if err != nil {
+		log.Printf("unable to respond to puzzle creation request: %v\n", err)
+		return fmt.Errorf("Editing response with puzzle: %v", err)
+	}
+
+	return nil
+}
+
+// newPuzzleBlocked returns why the active puzzle can't be replaced yet, or "" if it can
+func (instance *discordInstance) newPuzzleBlocked() string {
+	instance.lock.Lock()
+	defer instance.lock.Unlock()
+	return instance.newPuzzleBlockedLocked()
+}
+
+// newPuzzleBlockedLocked must be called with instance.lock held
+func (instance *discordInstance) newPuzzleBlockedLocked() string {
+	// another command is generating a puzzle
+	if instance.starting {
+		return "A new puzzle is already being created"
 	}
 
-	if !s.isSearching {
-		go lookForSolutions(s)
+	// haven't solved current puzzle
+	if instance.activeGame != nil {
+		optimalFound := len(instance.getSolutions(instance.activeGame.id).currentBest()) == instance.activeGame.lenOptimalSolution
+		timePassed := instance.clk().Since(instance.puzzleTimestamp) > instance.config.lockout()
+		if !optimalFound && !timePassed {
+			return fmt.Sprintf("Current puzzle must be solved optimally or %d minutes have passed before requesting a new one", instance.config.LockoutMinutes)
+		}
 	}
 
-	instance.activeGame = g
-	//instance.solution = &solutionTracker{}
-	instance.puzzleTimestamp = time.Now()
+	// tournament already in progress
+	if instance.activeTournament != nil {
+		return "There is currently a tournament in progress. You may only request a puzzle after it is over"
+	}
 
-	var moveStrs []string
-	for _, m := range g.moves {
-		moveStrs = append(moveStrs, m.String())
+	// race already in progress
+	if instance.activeRace != nil {
+		return "There is currently a race in progress. You may only request a puzzle after it is over"
 	}
-	fmt.Println("Optimal:", strings.Join(moveStrs, "-"))
 
+	// speed round already in progress
+	if instance.activeSpeed != nil {
+		return "There is currently a speed round in progress. You may only request a puzzle after it is over"
+	}
+
+	// bidding on the current puzzle hasn't finished
+	if b := instance.bidding; b != nil && b.g == instance.activeGame && !b.finished() {
+		return "The current puzzle is being bid on. You may only request a puzzle after bidding is over"
+	}
+	return ""
+}
+
+// postPuzzle renders the board and posts it to the channel
+func postPuzzle(dg messenger, channelID string, content string, g *game) error {
 	img, err := render(g)
 	if err != nil {
 		return fmt.Errorf("rendering board: %v", err)
@@ -187,7 +247,6 @@ func (s *server) handlePuzzle(dg *discordgo.Session, i *discordgo.InteractionCre
 	if err := png.Encode(&buf, img); err != nil {
 		return fmt.Errorf("encoding board image: %v", err)
 	}
-	instance.puzzleIdx += 1
 
 	file := &discordgo.File{
 		Name:        "board.png",
@@ -195,58 +254,30 @@ func (s *server) handlePuzzle(dg *discordgo.Session, i *discordgo.InteractionCre
 		Reader:      &buf,
 	}
 
-	_, err = dg.ChannelMessageSendComplex(instance.channelID, &discordgo.MessageSend{
-		Content: puzzleContent(i.Interaction.Member, instance.activeGame),
+	_, err = dg.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content: content,
 		Files:   []*discordgo.File{file},
 	})
 	if err != nil {
-		content := ":x: Unable to create puzzle, please try again later"
-		dg.InteractionResponseEdit(i.Interaction,
-			&discordgo.WebhookEdit{
-				Content: &content,
-			},
-		)
 		return fmt.Errorf("uploading puzzle to discord: %v", err)
 	}
-
-	content := "Puzzle created successfully"
-	_, err = dg.InteractionResponseEdit(i.Interaction,
-		&discordgo.WebhookEdit{
-			Content: &content,
-		},
-	)
-	if err != nil {
-		log.Printf("unable to respond to puzzle creation request: %v\n", err)
-		return fmt.Errorf("Editing response with puzzle: %v", err)
-	}
-
 	return nil
 }
 
 func puzzleContent(member *discordgo.Member, g *game) string {
 
-	var displayName string
-	if member.Nick != "" {
-		displayName = member.Nick
-	} else {
-		displayName = member.User.Username
-	}
+	displayName := displayName(member)
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("%s used **/puzzle**\n", displayName))
-	sb.WriteString(fmt.Sprintf("**Puzzle:** #__%s__ -- %s\n", g.id, g.difficulty))
-
-	var color string
-	switch g.activeGoal.id {
-	case 'R':
-		color = "Red"
-	case 'B':
-		color = "Blue"
-	case 'Y':
-		color = "Yellow"
-	case 'G':
-		color = "Green"
+	if g.author != "" {
+		sb.WriteString(fmt.Sprintf("%s used **/create**\n", displayName))
+		sb.WriteString(fmt.Sprintf("**Puzzle:** #__%s__ -- custom board by **%s**\n", g.id, g.author))
+	} else {
+		sb.WriteString(fmt.Sprintf("%s used **/puzzle**\n", displayName))
+		sb.WriteString(fmt.Sprintf("**Puzzle:** #__%s__ -- %s\n", g.id, g.difficulty))
 	}
+
+	color := goalColorName(g.activeGoal.id)
 	colorEmoji := fmt.Sprintf(":%s_square:", strings.ToLower(color))
 
 	sb.WriteString(fmt.Sprintf("Get the %s robot to the goal %s", color, colorEmoji))
@@ -254,6 +285,14 @@ func puzzleContent(member *discordgo.Member, g *game) string {
 	return sb.String()
 }
 
+// admin only commands require the manage server permission
+var manageServerPermission int64 = discordgo.PermissionManageServer
+
+var minRaceDuration float64 = 1
+var minBid float64 = 1
+var minLockout float64 = 0
+var minAuditDays float64 = 1
+
 var slashCommands = []*discordgo.ApplicationCommand{
 	{
 		Name:        "puzzle",
@@ -351,16 +390,265 @@ var slashCommands = []*discordgo.ApplicationCommand{
 			},
 		},
 	},
+	{
+		Name:        "speed",
+		Description: "timed puzzle where points decay the longer you take to solve it",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "difficulty",
+				Description: "easy, medium, or hard",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{
+						Name:  "easy",
+						Value: "easy",
+					},
+					{
+						Name:  "medium",
+						Value: "medium",
+					},
+					{
+						Name:  "hard",
+						Value: "hard",
+					},
+				},
+			},
+			{
+				Name:        "duration",
+				Description: "how many minutes the round lasts (Min: 1, Max: 10)",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    false,
+				MinValue:    &minRaceDuration,
+				MaxValue:    10,
+			},
+		},
+	},
+	{
+		Name:        "bid",
+		Description: "bid how many moves you can solve the current puzzle in. The lowest bidder must demonstrate",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "moves",
+				Description: "number of moves in your solution",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    true,
+				MinValue:    &minBid,
+				MaxValue:    maxBid,
+			},
+		},
+	},
+	{
+		Name:        "race",
+		Description: "play through every goal on a board, robots stay where the winner left them",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "duration",
+				Description: "how many minutes per goal (Min: 1, Max: 10)",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    false,
+				MinValue:    &minRaceDuration,
+				MaxValue:    10,
+			},
+		},
+	},
+	{
+		Name:                     "boards",
+		Description:              "list or choose the board sets puzzles are drawn from",
+		DefaultMemberPermissions: &manageServerPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "sets",
+				Description: "comma separated board sets i.e. classic,mini",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    false,
+			},
+		},
+	},
+	{
+		Name:        "create",
+		Description: "post your own board as the active puzzle",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "file",
+				Description: "ascii board (.txt) or json board (.json)",
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+				Required:    false,
+			},
+			{
+				Name:        "json",
+				Description: "json board description if not uploading a file",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    false,
+			},
+		},
+	},
+	{
+		Name:                     "config",
+		Description:              "show or change this server's settings",
+		DefaultMemberPermissions: &manageServerPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:         "channel",
+				Description:  "primary channel puzzles are posted in",
+				Type:         discordgo.ApplicationCommandOptionChannel,
+				ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				Required:     false,
+			},
+			{
+				Name:         "add_channel",
+				Description:  "another channel to run its own puzzles in",
+				Type:         discordgo.ApplicationCommandOptionChannel,
+				ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				Required:     false,
+			},
+			{
+				Name:         "remove_channel",
+				Description:  "stop running puzzles in a channel added with add_channel",
+				Type:         discordgo.ApplicationCommandOptionChannel,
+				ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				Required:     false,
+			},
+			{
+				Name:        "lockout",
+				Description: "minutes before a puzzle that hasn't been solved optimally can be replaced",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				MinValue:    &minLockout,
+				MaxValue:    60,
+				Required:    false,
+			},
+			{
+				Name:        "difficulty",
+				Description: "difficulty used when none is chosen",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{
+						Name:  "easy",
+						Value: "easy",
+					},
+					{
+						Name:  "medium",
+						Value: "medium",
+					},
+					{
+						Name:  "hard",
+						Value: "hard",
+					},
+				},
+				Required: false,
+			},
+			{
+				Name:        "announce_solves",
+				Description: "post a message when someone solves the active puzzle",
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Required:    false,
+			},
+			{
+				Name:        "spoilers",
+				Description: "hide shared solutions behind spoiler tags",
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Required:    false,
+			},
+			{
+				Name:        "modes",
+				Description: "comma separated modes to enable i.e. tournament,race,speed,bid,create or all",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    false,
+			},
+		},
+	},
+	{
+		Name:        "stats",
+		Description: "show a player's puzzle history, rating and achievements",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "user",
+				Description: "player to show, defaults to you",
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Required:    false,
+			},
+		},
+	},
+	{
+		Name:        "link",
+		Description: "link your account on a community site",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "code",
+				Description: "one-time code from the site, leave empty to see where to get one",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    false,
+			},
+		},
+	},
+	{
+		Name:        "unlink",
+		Description: "remove your linked account",
+	},
+	{
+		Name:                     "rewards",
+		Description:              "inspect token rewards",
+		DefaultMemberPermissions: &manageServerPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "audit",
+				Description: "list recent rewards and check them against recorded solves",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "user",
+						Description: "only show rewards paid to this user",
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Required:    false,
+					},
+					{
+						Name:        "days",
+						Description: "how many days back to look",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						MinValue:    &minAuditDays,
+						MaxValue:    30,
+						Required:    false,
+					},
+				},
+			},
+		},
+	},
 }
 
-// registerCommands fully refreshes the slashCommand list for the provided guild
+// registerCommands fully refreshes the slashCommand list for the provided guild. Commands that
+// also work in DMs are registered globally instead, see registerGlobalCommands
 func registerCommands(dg *discordgo.Session, guildID string) error {
+	var guildCommands []*discordgo.ApplicationCommand
+	for _, cmd := range slashCommands {
+		if !practiceCommands[cmd.Name] {
+			guildCommands = append(guildCommands, cmd)
+		}
+	}
 
 	// overwrite old commands and update new commands
-	_, err := dg.ApplicationCommandBulkOverwrite(DiscordApplicationID, guildID, slashCommands)
+	_, err := dg.ApplicationCommandBulkOverwrite(DiscordApplicationID, guildID, guildCommands)
 	if err != nil {
 		log.Printf("Registering application commands: %v, for guild: %s", err, guildID)
 	}
 
 	return nil
 }
+
+var dmPermission = true
+
+// registerGlobalCommands registers the commands available both in guilds and in DMs
+func registerGlobalCommands(dg *discordgo.Session) error {
+	var globalCommands []*discordgo.ApplicationCommand
+	for _, cmd := range slashCommands {
+		if practiceCommands[cmd.Name] {
+			cpy := *cmd
+			cpy.DMPermission = &dmPermission
+			globalCommands = append(globalCommands, &cpy)
+		}
+	}
+
+	_, err := dg.ApplicationCommandBulkOverwrite(DiscordApplicationID, "", globalCommands)
+	if err != nil {
+		return fmt.Errorf("registering global commands: %v", err)
+	}
+	return nil
+}
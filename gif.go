@@ -49,15 +49,15 @@ func renderGif(solvedGame *game, moves []move) (bytes.Buffer, error) {
 		endPos := g.robots[m.id].position
 
 		// now draw robot at start + several discrete steps + end
-		startRow := startPos / 16
-		startCol := startPos % 16
-		startX := int(startCol * 16)
-		startY := int(startRow * 16)
+		startRow := int(startPos) / g.size
+		startCol := int(startPos) % g.size
+		startX := startCol * 16
+		startY := startRow * 16
 
-		endRow := endPos / 16
-		endCol := endPos % 16
-		endX := int(endCol * 16)
-		endY := int(endRow * 16)
+		endRow := int(endPos) / g.size
+		endCol := int(endPos) % g.size
+		endX := endCol * 16
+		endY := endRow * 16
 
 		// start
 		boardWithOtherRobots := copyImg(boardImg)
@@ -96,7 +96,7 @@ func renderGif(solvedGame *game, moves []move) (bytes.Buffer, error) {
 
 	// convert to gif compatible images
 	for idx, img := range images {
-		dst := image.NewPaletted(image.Rect(0, 0, 16*16, 16*16), palette.WebSafe)
+		dst := image.NewPaletted(img.Bounds(), palette.WebSafe)
 		quantizer.Quantize(dst, dst.Bounds(), img, image.Point{})
 
 		moveGif.Image = append(moveGif.Image, dst)
@@ -115,7 +115,7 @@ func renderGif(solvedGame *game, moves []move) (bytes.Buffer, error) {
 }
 
 func copyImg(img draw.Image) draw.Image {
-	dst := image.NewNRGBA(image.Rect(0, 0, 16*16, 16*16))
+	dst := image.NewNRGBA(img.Bounds())
 	draw.Draw(dst, img.Bounds(), img, image.Point{}, draw.Over)
 	return dst
 }
@@ -126,11 +126,11 @@ func drawRobots(g *game, ignore byte, dst draw.Image) {
 			continue
 		}
 		robotImg := pickRobot(*r)
-		row := r.position / 16
-		col := r.position % 16
+		row := int(r.position) / g.size
+		col := int(r.position) % g.size
 
-		x := int(col * 16)
-		y := int(row * 16)
+		x := col * 16
+		y := row * 16
 		draw.Draw(dst, image.Rect(x, y, x+16, y+16), robotImg, image.Point{}, draw.Over)
 	}
 }
@@ -138,9 +138,9 @@ func drawRobots(g *game, ignore byte, dst draw.Image) {
 // renders board + goal but no robots
 func renderGifBoard(g *game) (draw.Image, error) {
 
-	dst := image.NewNRGBA(image.Rect(0, 0, 16*16, 16*16))
+	dst := image.NewNRGBA(image.Rect(0, 0, g.size*16, g.height()*16))
 	// one row at a time
-	for row := 0; row < g.size; row += 1 {
+	for row := 0; row < g.height(); row += 1 {
 		for col := 0; col < g.size; col += 1 {
 			sq := g.board[row*g.size+col]
 			tile := pickTile(sq)
@@ -153,11 +153,11 @@ func renderGifBoard(g *game) (draw.Image, error) {
 
 	// draw goal
 	goalImg := pickGoal(g.activeGoal)
-	row := g.activeGoal.position / 16
-	col := g.activeGoal.position % 16
+	row := int(g.activeGoal.position) / g.size
+	col := int(g.activeGoal.position) % g.size
 
-	x := int(col * 16)
-	y := int(row * 16)
+	x := col * 16
+	y := row * 16
 	draw.Draw(dst, image.Rect(x, y, x+16, y+16), goalImg, image.Point{}, draw.Over)
 
 	return dst, nil
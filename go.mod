@@ -3,14 +3,15 @@ module github.com/solipsis/ricochet-robotbot
 go 1.19
 
 require (
+	github.com/andybons/gogif v0.0.0-20140526152223-16d573594812
 	github.com/bwmarrin/discordgo v0.26.1
 	github.com/google/uuid v1.3.0
 	github.com/jackc/pgx/v4 v4.17.2
+	github.com/njones/base58 v0.0.0-20170928150306-6134fb8280ab
 	golang.org/x/image v0.1.0
 )
 
 require (
-	github.com/andybons/gogif v0.0.0-20140526152223-16d573594812 // indirect
 	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
 	github.com/jackc/pgconn v1.13.0 // indirect
@@ -20,7 +21,6 @@ require (
 	github.com/jackc/pgservicefile v0.0.0-20200714003250-2b9c44734f2b // indirect
 	github.com/jackc/pgtype v1.12.0 // indirect
 	github.com/jackc/puddle v1.3.0 // indirect
-	github.com/njones/base58 v0.0.0-20170928150306-6134fb8280ab // indirect
 	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa // indirect
 	golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f // indirect
 	golang.org/x/text v0.4.0 // indirect
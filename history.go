@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// submission is a single accepted solution recorded in a user's history
+type submission struct {
+	UserID     string    `json:"userId"`
+	GuildID    string    `json:"guildId"`
+	ChannelID  string    `json:"channelId,omitempty"` // game channel the puzzle was posted in
+	PuzzleID   string    `json:"puzzleId"`
+	Moves      string    `json:"moves"`
+	NumMoves   int       `json:"numMoves"`
+	Optimal    int       `json:"optimal"` // 0 if the optimal length is unknown
+	Difficulty string    `json:"difficulty"`
+	Archived   bool      `json:"archived"` // solved after the puzzle stopped being active
+	Reward     int       `json:"reward"`
+	Timestamp  time.Time `json:"timestamp"`
+	// time between the puzzle being posted and the solution, 0 if unknown i.e. archived solves
+	ElapsedMs int64 `json:"elapsedMs,omitempty"`
+}
+
+// excess is how many moves over optimal the submission was, or -1 if unknown
+func (sub submission) excess() int {
+	if sub.Optimal == 0 {
+		return -1
+	}
+	return sub.NumMoves - sub.Optimal
+}
+
+// history is the persisted log of every user's submissions keyed by discord user ID
+type history struct {
+	path string
+
+	lock        sync.RWMutex
+	submissions map[string][]submission
+}
+
+// loadHistory reads the history file at path. A missing file is treated as empty history
+func loadHistory(path string) (*history, error) {
+	h := &history{
+		path:        path,
+		submissions: make(map[string][]submission),
+	}
+
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %v", err)
+	}
+	if err := json.Unmarshal(buf, &h.submissions); err != nil {
+		return nil, fmt.Errorf("parsing history: %v", err)
+	}
+	return h, nil
+}
+
+// record appends a submission to the user's history and persists it
+func (h *history) record(sub submission) error {
+	if h == nil {
+		return nil
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.submissions[sub.UserID] = append(h.submissions[sub.UserID], sub)
+	return h.save()
+}
+
+// forUser returns a copy of all submissions made by the user, oldest first
+func (h *history) forUser(userID string) []submission {
+	if h == nil {
+		return nil
+	}
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	subs := make([]submission, len(h.submissions[userID]))
+	copy(subs, h.submissions[userID])
+	return subs
+}
+
+// hasSolved reports whether the user has any recorded submission for the puzzle
+func (h *history) hasSolved(userID, puzzleID string) bool {
+	for _, sub := range h.forUser(userID) {
+		if sub.PuzzleID == puzzleID {
+			return true
+		}
+	}
+	return false
+}
+
+// since returns every submission recorded at or after t
+func (h *history) since(t time.Time) []submission {
+	if h == nil {
+		return nil
+	}
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	var subs []submission
+	for _, userSubs := range h.submissions {
+		for _, sub := range userSubs {
+			if !sub.Timestamp.Before(t) {
+				subs = append(subs, sub)
+			}
+		}
+	}
+	return subs
+}
+
+// rewardsSince is the total reward recorded for the user's submissions since t
+func (h *history) rewardsSince(userID string, t time.Time) int {
+	total := 0
+	for _, sub := range h.forUser(userID) {
+		if !sub.Timestamp.Before(t) {
+			total += sub.Reward
+		}
+	}
+	return total
+}
+
+// save must be called with the lock held
+func (h *history) save() error {
+	if h.path == "" {
+		return nil
+	}
+	return writeJSONFile(h.path, h.submissions)
+}
+
+// writeJSONFile atomically replaces the file at path with the json encoding of v
+func writeJSONFile(path string, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding %s: %v", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating data dir: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replacing %s: %v", path, err)
+	}
+	return nil
+}
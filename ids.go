@@ -1,26 +1,116 @@
 package main
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 
 	"github.com/njones/base58"
 )
 
-// Q1 Q2 Q3 Q4 R1 R2 R3 R4 GL GC ROT Extra
+// Puzzle IDs are base58 encoded and start with a version byte describing the payload. The last
+// 2 bytes are a checksum of everything before them so a typo'd ID is rejected instead of
+// decoding into a different puzzle
+// VERSION PAYLOAD... CHECKSUM
+const (
+	ID_VERSION_STANDARD byte = 2 // board set layout, see encodeLayout
+	ID_VERSION_CUSTOM   byte = 3 // entire board for user created puzzles, see encodeCustom
+)
+
+const idChecksumLen = 2
+
+// legacyIDLen is the size of standard IDs issued before IDs were versioned. They have no
+// version byte or checksum
+const legacyIDLen = 12
+
+// wrapID adds the version byte and checksum to a payload
+func wrapID(version byte, payload []byte) string {
+	buf := append([]byte{version}, payload...)
+	buf = append(buf, idChecksum(buf)...)
+	return base58.StdEncoding.EncodeToString(buf)
+}
+
+func idChecksum(buf []byte) []byte {
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(buf))
+	return sum[:idChecksumLen]
+}
+
 func encode(g *game) (string, error) {
+	payload, err := encodeLayout(g)
+	if err != nil {
+		return "", err
+	}
+	payload = append(payload, encodeMetadata(g)...)
+	return wrapID(ID_VERSION_STANDARD, payload), nil
+}
+
+// puzzle metadata follows the board in versioned IDs. IDs issued before metadata was added
+// end after the board and decode with unknown difficulty
+// OPT DIFF
+const metadataLen = 2
+
+func encodeMetadata(g *game) []byte {
+	return []byte{byte(g.lenOptimalSolution), byte(g.difficulty)}
+}
+
+// decodeMetadata rejects metadata the bot wouldn't have issued. The optimal length itself is
+// only checked once the puzzle has been solved, see canonicalID
+func decodeMetadata(g *game, buf []byte) error {
+	opt, diff := int(buf[0]), difficulty(buf[1])
+	if diff < UNKNOWN || diff > EXTREME {
+		return fmt.Errorf("unknown difficulty %d", buf[1])
+	}
+	if opt > maxArchiveSolveDepth {
+		return fmt.Errorf("optimal length %d is longer than any puzzle the bot issues", opt)
+	}
+	if diff != difficultyFromMoves(opt) {
+		return fmt.Errorf("difficulty %s doesn't match the optimal length %d", diff, opt)
+	}
+	g.lenOptimalSolution = opt
+	g.difficulty = diff
+	return nil
+}
+
+// canonicalID is the ID the bot issues for the puzzle with the given optimal length. The same
+// puzzle can be spelled several ways, e.g. legacy IDs or IDs without metadata, so anything keyed
+// by a puzzle a user typed in must use this instead of the ID they typed
+func canonicalID(g *game, optimal int) (string, error) {
+	cpy := *g
+	cpy.lenOptimalSolution = optimal
+	cpy.difficulty = difficultyFromMoves(optimal)
+	if g.isCustom() {
+		return encodeCustom(&cpy)
+	}
+	return encode(&cpy)
+}
+
+// puzzleKey identifies the puzzle regardless of how its ID was spelled or what metadata it
+// carried
+func puzzleKey(g *game) string {
+	key, err := canonicalID(g, 0)
+	if err != nil {
+		return g.id
+	}
+	return key
+}
+
+// Q1 Q2 Q3 Q4 R1 R2 R3 R4 GL GC ROT SET
+// For board sets made of complete boards Q1 holds the board index and Q2-Q4 are unused
+func encodeLayout(g *game) ([]byte, error) {
 	if g == nil {
-		return "", fmt.Errorf("nil game")
+		return nil, fmt.Errorf("nil game")
 	}
 
 	if g.quadrants == nil || len(g.quadrants) != 4 {
-		return "", fmt.Errorf("Unexpected board quadrants for encoding")
+		return nil, fmt.Errorf("Unexpected board quadrants for encoding")
 	}
 
 	if len(g.robots) != 4 {
-		return "", fmt.Errorf("Unexpected num robots for encoding")
+		return nil, fmt.Errorf("Unexpected num robots for encoding")
 	}
 
-	encoded := make([]byte, 12)
+	encoded := make([]byte, legacyIDLen)
 	// map
 	encoded[0] = byte(g.quadrants[0])
 	encoded[1] = byte(g.quadrants[1])
@@ -36,45 +126,253 @@ func encode(g *game) (string, error) {
 	encoded[9] = byte(g.activeGoal.id)
 	// rotation
 	encoded[10] = 0
-	// extra
-	encoded[11] = 0
-
-	encoding := base58.StdEncoding.EncodeToString(encoded)
+	// board set
+	if g.set != nil {
+		encoded[11] = g.set.index
+	}
 
-	return encoding, nil
+	return encoded, nil
 }
 
+// decode rebuilds the puzzle described by id. Both versioned and legacy IDs are accepted
 func decode(id string) (*game, error) {
 	buf, err := base58.StdEncoding.DecodeString(id)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("puzzle id is not valid base58: %v", err)
 	}
 
-	q1, q2, q3, q4 := buf[0], buf[1], buf[2], buf[3]
-	boardStr, err := boardFromQuadrants(int(q1), int(q2), int(q3), int(q4))
+	switch {
+	case len(buf) == 0:
+		return nil, fmt.Errorf("empty puzzle id")
+	case len(buf) == legacyIDLen:
+		return decodeLayout(id, buf)
+	case len(buf) < 1+idChecksumLen:
+		return nil, fmt.Errorf("puzzle id is too short")
+	}
+
+	body, sum := buf[:len(buf)-idChecksumLen], buf[len(buf)-idChecksumLen:]
+	if string(idChecksum(body)) != string(sum) {
+		return nil, fmt.Errorf("puzzle id checksum does not match, check it for typos")
+	}
+
+	version, payload := body[0], body[1:]
+	switch version {
+	case ID_VERSION_STANDARD:
+		if len(payload) != legacyIDLen && len(payload) != legacyIDLen+metadataLen {
+			return nil, fmt.Errorf("puzzle id has %d bytes, expected %d", len(payload), legacyIDLen+metadataLen)
+		}
+		g, err := decodeLayout(id, payload[:legacyIDLen])
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) > legacyIDLen {
+			if err := decodeMetadata(g, payload[legacyIDLen:]); err != nil {
+				return nil, err
+			}
+		}
+		return g, nil
+	case ID_VERSION_CUSTOM:
+		return decodeCustom(id, payload)
+	default:
+		return nil, fmt.Errorf("unknown puzzle id version %d", version)
+	}
+}
+
+func decodeLayout(id string, buf []byte) (*game, error) {
+	// boards are never rotated, any other value would be another spelling of the same puzzle
+	if buf[10] != 0 {
+		return nil, fmt.Errorf("unsupported board rotation %d", buf[10])
+	}
+	set := boardSetByIndex(buf[11])
+	if set == nil {
+		return nil, fmt.Errorf("unknown board set: %d", buf[11])
+	}
+	layout := []int{int(buf[0]), int(buf[1]), int(buf[2]), int(buf[3])}
+	g, err := set.build(layout)
 	if err != nil {
-		return nil, fmt.Errorf("boardFromQuadrants: %v", err)
+		return nil, fmt.Errorf("building board: %v", err)
 	}
-	g := parseBoard(boardStr, []int{int(q1), int(q2), int(q3), int(q4)})
-	//g.quadrants = []int{int(q1), int(q2), int(q3), int(q4)}
 
 	// reset any robot positions from board string tile
 	for idx := range g.board {
 		g.board[idx] = g.board[idx] &^ square(ROBOT)
 	}
-	g.robots['R'].position = uint32(buf[4])
-	g.robots['G'].position = uint32(buf[5])
-	g.robots['B'].position = uint32(buf[6])
-	g.robots['Y'].position = uint32(buf[7])
-	g.activeGoal.position = uint32(buf[8])
-	g.activeGoal.id = buf[9]
+	if err := placePieces(&g, buf[4:8], buf[8], buf[9]); err != nil {
+		return nil, err
+	}
+
+	// generated puzzles target one of the board's goal squares with a robot of any color
+	found := false
+	for _, goal := range g.goals {
+		if goal.position == g.activeGoal.position {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no goal at square %d", g.activeGoal.position)
+	}
 	g.id = id
+	g.precomputedMoves = g.preCompute(g.activeGoal.position)
 
-	for _, r := range g.robots {
-		g.board[r.position] = g.board[r.position] | square(ROBOT)
+	return &g, nil
+}
+
+// placePieces validates and places the robots, in RGBY order, and the active goal
+func placePieces(g *game, robots []byte, goalPos, goalID byte) error {
+	for idx, robotID := range possibleRobots {
+		pos := int(robots[idx])
+		if pos >= len(g.board) {
+			return fmt.Errorf("%c robot is outside the board", robotID)
+		}
+		if g.board[pos]&square(ROBOT) != 0 {
+			return fmt.Errorf("%c robot is on the same square as another robot", robotID)
+		}
+		if g.board[pos]&square(BLOCKED) != 0 {
+			return fmt.Errorf("%c robot is on a blocked square", robotID)
+		}
+		g.board[pos] |= square(ROBOT)
+		g.robots[robotID] = &robot{id: robotID, position: uint32(pos)}
+	}
+
+	if int(goalPos) >= len(g.board) || g.board[goalPos]&square(BLOCKED) != 0 {
+		return fmt.Errorf("invalid goal position %d", goalPos)
+	}
+	if _, ok := g.robots[goalID]; !ok {
+		return fmt.Errorf("invalid goal color %q", goalID)
+	}
+	g.activeGoal = Goal{id: goalID, position: uint32(goalPos)}
+	g.activeRobot = g.robots[goalID]
+	return nil
+}
+
+// custom boards can have any walls so their ID carries the whole board
+// W-1 H-1 SQUARES... R1 R2 R3 R4 GL GC OPT DIFF NG G1L G1C ... GNL GNC
+// Each square is packed into a nibble holding its right wall, bottom wall and whether it is
+// blocked. Left and top walls are mirrored from the neighbouring square and the outer edge is
+// always walled
+const (
+	customRight   = 1 << 0
+	customDown    = 1 << 1
+	customBlocked = 1 << 2
+)
+
+func encodeCustom(g *game) (string, error) {
+	if g == nil {
+		return "", fmt.Errorf("nil game")
+	}
+	if len(g.board) == 0 || len(g.board) > maxBoardSquares || len(g.board)%g.size != 0 {
+		return "", fmt.Errorf("Unexpected board size for encoding")
+	}
+	if len(g.robots) != 4 {
+		return "", fmt.Errorf("Unexpected num robots for encoding")
 	}
 
-	//fmt.Println(printBoard(g.board, g.size, g.robots, g.activeGoal))
+	squares := make([]byte, (len(g.board)+1)/2)
+	for pos, sq := range g.board {
+		var nibble byte
+		if sq&square(RIGHT) != 0 {
+			nibble |= customRight
+		}
+		if sq&square(DOWN) != 0 {
+			nibble |= customDown
+		}
+		if sq&square(BLOCKED) != 0 {
+			nibble |= customBlocked
+		}
+		squares[pos/2] |= nibble << (4 * (pos % 2))
+	}
+
+	encoded := []byte{byte(g.size - 1), byte(g.height() - 1)}
+	encoded = append(encoded, squares...)
+	for _, id := range possibleRobots {
+		encoded = append(encoded, byte(g.robots[id].position))
+	}
+	encoded = append(encoded, byte(g.activeGoal.position), g.activeGoal.id)
+	encoded = append(encoded, encodeMetadata(g)...)
+	// every goal drawn on the board
+	if len(g.goals) > 255 {
+		return "", fmt.Errorf("Unexpected num goals for encoding")
+	}
+	encoded = append(encoded, byte(len(g.goals)))
+	for _, goal := range g.goals {
+		encoded = append(encoded, byte(goal.position), goal.id)
+	}
+
+	return wrapID(ID_VERSION_CUSTOM, encoded), nil
+}
+
+func decodeCustom(id string, buf []byte) (*game, error) {
+	if len(buf) < 2 {
+		return nil, fmt.Errorf("custom puzzle id is too short")
+	}
+	width, height := int(buf[0])+1, int(buf[1])+1
+	numSquares := width * height
+	if numSquares > maxBoardSquares {
+		return nil, fmt.Errorf("custom board is %dx%d, at most %d squares are supported", width, height, maxBoardSquares)
+	}
+	// IDs issued before metadata was added end after the active goal
+	boardLen := 2 + (numSquares+1)/2 + len(possibleRobots) + 2
+	if len(buf) != boardLen && len(buf) < boardLen+metadataLen+1 {
+		return nil, fmt.Errorf("custom puzzle id has %d bytes, expected at least %d", len(buf), boardLen+metadataLen+1)
+	}
+
+	g := game{
+		size:   width,
+		board:  make([]square, numSquares),
+		robots: make(map[byte]*robot),
+		cache:  make(map[uint32]int),
+	}
+	squares := buf[2 : 2+(numSquares+1)/2]
+	for pos := range g.board {
+		nibble := squares[pos/2] >> (4 * (pos % 2))
+		row, col := pos/width, pos%width
+		if nibble&customRight != 0 || col == width-1 {
+			g.board[pos] |= square(RIGHT)
+		}
+		if nibble&customDown != 0 || row == height-1 {
+			g.board[pos] |= square(DOWN)
+		}
+		if nibble&customBlocked != 0 {
+			g.board[pos] |= square(BLOCKED)
+		}
+		if row == 0 {
+			g.board[pos] |= square(UP)
+		}
+		if col == 0 {
+			g.board[pos] |= square(LEFT)
+		}
+	}
+	mirrorWalls(&g)
+
+	rest := buf[2+(numSquares+1)/2 : boardLen]
+	if err := placePieces(&g, rest[:4], rest[4], rest[5]); err != nil {
+		return nil, err
+	}
+	g.goals = []Goal{g.activeGoal}
+
+	if len(buf) > boardLen {
+		meta := buf[boardLen:]
+		if err := decodeMetadata(&g, meta[:metadataLen]); err != nil {
+			return nil, err
+		}
+		goals := meta[metadataLen+1:]
+		if numGoals := int(meta[metadataLen]); len(goals) != numGoals*2 {
+			return nil, fmt.Errorf("custom puzzle id has %d goals, expected %d", len(goals)/2, numGoals)
+		}
+		g.goals = make([]Goal, 0, len(goals)/2)
+		for idx := 0; idx < len(goals); idx += 2 {
+			goal := Goal{id: goals[idx+1], position: uint32(goals[idx])}
+			if int(goal.position) >= numSquares || g.board[goal.position]&square(BLOCKED) != 0 {
+				return nil, fmt.Errorf("invalid goal position %d", goal.position)
+			}
+			if _, ok := g.robots[goal.id]; !ok {
+				return nil, fmt.Errorf("invalid goal color %q", goal.id)
+			}
+			g.goals = append(g.goals, goal)
+		}
+	}
+	g.id = id
+	g.precomputedMoves = g.preCompute(g.activeGoal.position)
 
 	return &g, nil
 }
@@ -2,7 +2,12 @@ package main
 
 import (
 	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/njones/base58"
 )
 
 func TestEncodeDecode(t *testing.T) {
@@ -53,3 +58,147 @@ func TestWeirdDecode(t *testing.T) {
 	fmt.Printf("start: %s, g.id: %s - decoded: %s\n", start, g.id, id)
 
 }
+
+func TestDecodeErrors(t *testing.T) {
+	legacy, err := decode("3BxvKmWMqjKASyDq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := encode(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decode(id); err != nil {
+		t.Fatalf("re-encoded legacy id: %v", err)
+	}
+
+	// every single character typo is caught by the checksum
+	alphabet := "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	for idx := range id {
+		for _, c := range alphabet {
+			if byte(c) == id[idx] {
+				continue
+			}
+			typo := id[:idx] + string(c) + id[idx+1:]
+			if _, err := decode(typo); err == nil {
+				t.Fatalf("typo %s of %s decoded without error", typo, id)
+			}
+		}
+	}
+
+	layout, err := encodeLayout(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		mutate func(buf []byte)
+		err    string
+	}{
+		{"unknown set", func(buf []byte) { buf[11] = 200 }, "unknown board set"},
+		{"bad quadrant", func(buf []byte) { buf[2] = 50 }, "invalid tile"},
+		{"stacked robots", func(buf []byte) { buf[5] = buf[4] }, "same square"},
+		{"blocked robot", func(buf []byte) { buf[6] = 7*16 + 7 }, "blocked square"},
+		{"goal color", func(buf []byte) { buf[9] = 'Q' }, "invalid goal color"},
+		{"goal position", func(buf []byte) { buf[8] = buf[8] + 1 }, "goal at square"},
+	}
+	for _, tc := range tests {
+		buf := append([]byte{}, layout...)
+		tc.mutate(buf)
+		for _, id := range []string{wrapID(ID_VERSION_STANDARD, buf), base58.StdEncoding.EncodeToString(buf)} {
+			_, err := decode(id)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("%s: expected error containing %q, got %v", tc.name, tc.err, err)
+			}
+		}
+	}
+
+	for _, id := range []string{"", "0OIl", "2", wrapID(9, layout), wrapID(ID_VERSION_STANDARD, layout[:11])} {
+		if _, err := decode(id); err == nil {
+			t.Fatalf("expected error decoding %q", id)
+		}
+	}
+
+	// arbitrary input must never panic
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 10000; n++ {
+		buf := make([]byte, 1+rng.Intn(40))
+		rng.Read(buf)
+		if rng.Intn(2) == 0 {
+			buf[0] = ID_VERSION_CUSTOM
+			buf = append(buf, idChecksum(buf)...)
+		}
+		decode(base58.StdEncoding.EncodeToString(buf))
+	}
+}
+
+// samePuzzle compares every field that describes a puzzle, ignoring search state
+func samePuzzle(t *testing.T, name string, a, b *game) {
+	t.Helper()
+	ca, cb := a.clone(), b.clone()
+	if !reflect.DeepEqual(ca, cb) {
+		t.Fatalf("%s: puzzles differ\n%+v\n%+v", name, ca, cb)
+	}
+	if a.activeRobot == nil || b.activeRobot == nil || a.activeRobot.id != b.activeRobot.id || b.activeRobot.id != b.activeGoal.id {
+		t.Fatalf("%s: active robot does not match goal", name)
+	}
+}
+
+func TestPuzzleRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	rand.Seed(7)
+
+	custom, err := parseCustomBoard([]byte(customBoard), "board.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareCustomPuzzle(custom); err != nil {
+		t.Fatal(err)
+	}
+
+	// puzzles without a short solution would only compare no solution with no solution
+	puzzles := []*game{custom}
+	for attempts := 0; len(puzzles) < 40; attempts++ {
+		if attempts == 1000 {
+			t.Fatalf("only found %d puzzles with a short solution", len(puzzles))
+		}
+		g := randomGameFromSet(boardSets[rng.Intn(len(boardSets))])
+		g.precomputedMoves = g.preCompute(g.activeGoal.position)
+		solver := g.clone()
+		moves, err := parseMoves(solver.solve(8))
+		if err != nil || len(moves) == 0 {
+			continue
+		}
+		g.lenOptimalSolution = len(moves)
+		g.difficulty = difficultyFromMoves(len(moves))
+		g.id, err = encode(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		puzzles = append(puzzles, g)
+	}
+
+	for _, g := range puzzles {
+		name := g.id
+
+		cpy := g.clone()
+		samePuzzle(t, name+" clone", g, &cpy)
+
+		decoded, err := decode(g.id)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		samePuzzle(t, name+" decode", g, decoded)
+
+		// solving the decoded puzzle matches solving the original
+		original, roundTrip := g.clone(), decoded.clone()
+		want, _ := parseMoves(original.solve(8))
+		got, _ := parseMoves(roundTrip.solve(8))
+		if len(want) == 0 || len(want) != len(got) {
+			t.Fatalf("%s: original solved in %d moves, decoded in %d", name, len(want), len(got))
+		}
+		if !validate(g, g.board, got, g.activeGoal) {
+			t.Fatalf("%s: decoded solution %v does not solve the original", name, got)
+		}
+	}
+}
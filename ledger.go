@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RewardLedger is where arena tokens earned by solving puzzles are paid out. The arena deployment
+// writes to the arena postgres database, everything else can use an in-memory ledger or none
+type RewardLedger interface {
+	// LinkedAccount returns the ledger account of a discord user, or "" if they haven't linked one
+	LinkedAccount(discordUserID string) (string, error)
+	// AddReward credits the entry's tokens to its account. Returns false without writing
+	// anything if an entry with the same key was already recorded
+	AddReward(entry rewardEntry) (bool, error)
+	// Rewards returns the entries recorded since t, oldest first
+	Rewards(since time.Time) ([]rewardEntry, error)
+}
+
+// openRewardLedger opens the ledger named by kind: "postgres" (the default when databaseURL is
+// set), "memory" or "none". The memory ledger is saved to path. The returned func releases the
+// ledger
+func openRewardLedger(kind, databaseURL, path string) (RewardLedger, func(), error) {
+	if kind == "" {
+		kind = "none"
+		if databaseURL != "" {
+			kind = "postgres"
+		}
+	}
+
+	switch kind {
+	case "postgres":
+		if databaseURL == "" {
+			return nil, nil, fmt.Errorf("the postgres ledger requires DATABASE_URL")
+		}
+		conn, err := pgxpool.Connect(context.Background(), databaseURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("connecting to db: %v", err)
+		}
+		return postgresLedger{conn: conn}, conn.Close, nil
+	case "memory":
+		ledger, err := loadMemoryLedger(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ledger, func() {}, nil
+	case "none":
+		return noopLedger{}, func() {}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown ledger %q, expected postgres, memory or none", kind)
+}
+
+// rewardLedger returns the server's ledger, falling back to one that pays nothing
+func (s *server) rewardLedger() RewardLedger {
+	if s.ledger == nil {
+		return noopLedger{}
+	}
+	return s.ledger
+}
+
+// noopLedger is used when there is nowhere to pay rewards. No one has a linked account
+type noopLedger struct{}
+
+func (noopLedger) LinkedAccount(discordUserID string) (string, error) {
+	return "", nil
+}
+
+func (noopLedger) AddReward(entry rewardEntry) (bool, error) {
+	return false, nil
+}
+
+func (noopLedger) Rewards(since time.Time) ([]rewardEntry, error) {
+	return nil, nil
+}
+
+// memoryLedger is a ledger for development and tests. Every discord user has an account named
+// after their user ID. Entries are persisted to path if it is set
+type memoryLedger struct {
+	path string
+
+	lock    sync.Mutex
+	entries []rewardEntry
+	keys    map[string]bool
+}
+
+// loadMemoryLedger reads the ledger file at path. A missing file is treated as an empty ledger
+func loadMemoryLedger(path string) (*memoryLedger, error) {
+	ml := &memoryLedger{path: path, keys: make(map[string]bool)}
+	if path == "" {
+		return ml, nil
+	}
+
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ml, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading ledger: %v", err)
+	}
+	if err := json.Unmarshal(buf, &ml.entries); err != nil {
+		return nil, fmt.Errorf("parsing ledger: %v", err)
+	}
+	for _, entry := range ml.entries {
+		ml.keys[entry.Key] = true
+	}
+	return ml, nil
+}
+
+func (ml *memoryLedger) LinkedAccount(discordUserID string) (string, error) {
+	return discordUserID, nil
+}
+
+func (ml *memoryLedger) AddReward(entry rewardEntry) (bool, error) {
+	ml.lock.Lock()
+	defer ml.lock.Unlock()
+	if entry.Amount == 0 || ml.keys[entry.Key] {
+		return false, nil
+	}
+	ml.entries = append(ml.entries, entry)
+	ml.keys[entry.Key] = true
+	if ml.path == "" {
+		return true, nil
+	}
+	if err := writeJSONFile(ml.path, ml.entries); err != nil {
+		// keep memory in line with the file so a retry writes it again
+		ml.entries = ml.entries[:len(ml.entries)-1]
+		delete(ml.keys, entry.Key)
+		return false, err
+	}
+	return true, nil
+}
+
+func (ml *memoryLedger) Rewards(since time.Time) ([]rewardEntry, error) {
+	ml.lock.Lock()
+	defer ml.lock.Unlock()
+	var entries []rewardEntry
+	for _, entry := range ml.entries {
+		if !entry.Timestamp.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// balance is the total paid to the account
+func (ml *memoryLedger) balance(accountID string) int {
+	ml.lock.Lock()
+	defer ml.lock.Unlock()
+	total := 0
+	for _, entry := range ml.entries {
+		if entry.AccountID == accountID {
+			total += entry.Amount
+		}
+	}
+	return total
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemoryLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	ml, err := loadMemoryLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := ml.LinkedAccount("alice")
+	if err != nil || account == "" {
+		t.Fatalf("expected alice to have an account, got %q %v", account, err)
+	}
+	entries := []rewardEntry{
+		{Key: "a/alice/solve:first-solver", AccountID: account, Amount: 15},
+		{Key: "a/alice/solve:first-optimal", AccountID: account, Amount: 30},
+		{Key: "a/bob/solve:first-solver", AccountID: "bob", Amount: 10},
+		{Key: "b/alice/solve:first-solver", AccountID: account},
+	}
+	for idx, entry := range entries {
+		added, err := ml.AddReward(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != (entry.Amount > 0) {
+			t.Fatalf("entry %d added: %v", idx, added)
+		}
+	}
+
+	reloaded, err := loadMemoryLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := reloaded.balance(account); balance != 45 {
+		t.Fatalf("alice has %d tokens, expected 45", balance)
+	}
+
+	// retrying a payout after a restart doesn't pay twice
+	if added, err := reloaded.AddReward(entries[1]); added || err != nil {
+		t.Fatalf("duplicate reward was added: %v %v", added, err)
+	}
+	if balance := reloaded.balance(account); balance != 45 {
+		t.Fatalf("alice has %d tokens after a retry, expected 45", balance)
+	}
+}
+
+func TestOpenRewardLedger(t *testing.T) {
+	ledger, closeLedger, err := openRewardLedger("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeLedger()
+	if _, ok := ledger.(noopLedger); !ok {
+		t.Fatalf("expected no ledger without a database, got %T", ledger)
+	}
+
+	if _, _, err := openRewardLedger("postgres", "", ""); err == nil {
+		t.Fatalf("expected the postgres ledger to require a database url")
+	}
+	if _, _, err := openRewardLedger("sqlite", "", ""); err == nil {
+		t.Fatalf("expected an unknown ledger to be rejected")
+	}
+}
+
+func TestArenaSolveRewards(t *testing.T) {
+	puzzles := testPuzzles(t)
+	for _, g := range puzzles {
+		g.difficulty = MEDIUM
+		g.set = classicSet
+	}
+	s, fm := newTestServer(t, puzzles)
+	ml, _ := loadMemoryLedger("")
+	s.ledger = ml
+	s.setPrimaryChannel(ArenaServerID, "arena", defaultGuildConfig())
+	g := puzzles[0]
+
+	s.handleInteraction(fm, command(ArenaServerID, "arena", "alice", "puzzle"))
+	fm.waitFor(t, "arena", "#__"+g.id+"__")
+
+	// the first solve and the first optimal solve both pay out
+	s.handleInteraction(fm, command(ArenaServerID, "arena", "alice", "solve", "moves", formatMoves(g.moves)))
+	want := 2 * defaultRewardPolicy().amount(MEDIUM)
+	fm.waitFor(t, "arena", fmt.Sprintf("+%d <:arena:", want))
+	if balance := ml.balance("alice"); balance != want {
+		t.Fatalf("alice was paid %d tokens, expected %d", balance, want)
+	}
+	if len(ml.entries) != 2 || ml.entries[1].Rule != "first-optimal" || ml.entries[1].PuzzleID != g.id || ml.entries[1].UserID != "alice" {
+		t.Fatalf("unexpected ledger entries %+v", ml.entries)
+	}
+
+	// matching the optimal solution pays nothing
+	s.handleInteraction(fm, command(ArenaServerID, "arena", "bob", "solve", "moves", formatMoves(g.moves)))
+	msg := fm.waitFor(t, "arena", "<@bob> solved")
+	if strings.Contains(msg.content, "<:arena:") || ml.balance("bob") != 0 {
+		t.Fatalf("bob was rewarded for a second optimal solution: %s", msg.content)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Communities outside the arena link discord users to accounts on their own site with a one-time
+// code. The site shows the code to a signed in user, who redeems it with /link. The bot exchanges
+// the code with the site for the account, so a code is useless to anyone who didn't earn it.
+// Rewards are paid to the linked account and leaderboards use its display name
+
+// linkedProfile is the account a discord user has linked
+type linkedProfile struct {
+	AccountID   string    `json:"accountId"`
+	DisplayName string    `json:"displayName"`
+	LinkedAt    time.Time `json:"linkedAt"`
+}
+
+// identityStore keeps track of linked accounts by discord user ID
+type identityStore interface {
+	// Profile returns the user's linked profile, ok is false if they haven't linked one
+	Profile(discordUserID string) (profile linkedProfile, ok bool, err error)
+	Link(discordUserID string, profile linkedProfile) error
+	// Unlink removes the user's profile, returning false if they didn't have one
+	Unlink(discordUserID string) (bool, error)
+}
+
+// fileIdentities is an identity store persisted to a json file
+type fileIdentities struct {
+	path string
+
+	lock     sync.RWMutex
+	profiles map[string]linkedProfile
+}
+
+// loadIdentities reads the identity file at path. A missing file is treated as no linked accounts
+func loadIdentities(path string) (*fileIdentities, error) {
+	fi := &fileIdentities{
+		path:     path,
+		profiles: make(map[string]linkedProfile),
+	}
+
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fi, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading identities: %v", err)
+	}
+	if err := json.Unmarshal(buf, &fi.profiles); err != nil {
+		return nil, fmt.Errorf("parsing identities: %v", err)
+	}
+	return fi, nil
+}
+
+func (fi *fileIdentities) Profile(discordUserID string) (linkedProfile, bool, error) {
+	fi.lock.RLock()
+	defer fi.lock.RUnlock()
+	profile, ok := fi.profiles[discordUserID]
+	return profile, ok, nil
+}
+
+func (fi *fileIdentities) Link(discordUserID string, profile linkedProfile) error {
+	fi.lock.Lock()
+	defer fi.lock.Unlock()
+	fi.profiles[discordUserID] = profile
+	return fi.save()
+}
+
+func (fi *fileIdentities) Unlink(discordUserID string) (bool, error) {
+	fi.lock.Lock()
+	defer fi.lock.Unlock()
+	if _, ok := fi.profiles[discordUserID]; !ok {
+		return false, nil
+	}
+	delete(fi.profiles, discordUserID)
+	return true, fi.save()
+}
+
+// save must be called with the lock held
+func (fi *fileIdentities) save() error {
+	if fi.path == "" {
+		return nil
+	}
+	return writeJSONFile(fi.path, fi.profiles)
+}
+
+var errInvalidLinkCode = errors.New("invalid or expired link code")
+
+// linkExchanger redeems one-time codes issued by the site at baseURL
+type linkExchanger struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newLinkExchanger(baseURL string) *linkExchanger {
+	return &linkExchanger{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// codeURL is where users sign in to get a code
+func (le *linkExchanger) codeURL() string {
+	return le.baseURL + "/link"
+}
+
+// exchange redeems the code for the account it was issued to. The site is told which discord
+// user redeemed it. Returns errInvalidLinkCode if the site doesn't accept the code
+func (le *linkExchanger) exchange(code, discordUserID string) (linkedProfile, error) {
+	body, err := json.Marshal(map[string]string{"code": code, "discordUserId": discordUserID})
+	if err != nil {
+		return linkedProfile{}, fmt.Errorf("encoding link request: %v", err)
+	}
+	resp, err := le.client.Post(le.baseURL+"/api/link", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return linkedProfile{}, fmt.Errorf("exchanging link code: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusGone:
+		return linkedProfile{}, errInvalidLinkCode
+	default:
+		return linkedProfile{}, fmt.Errorf("exchanging link code: unexpected status %s", resp.Status)
+	}
+
+	var profile linkedProfile
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return linkedProfile{}, fmt.Errorf("parsing link response: %v", err)
+	}
+	if profile.AccountID == "" {
+		return linkedProfile{}, fmt.Errorf("link response is missing the account")
+	}
+	return profile, nil
+}
+
+// linkedProfile returns the user's linked profile, if they have one
+func (s *server) linkedProfile(discordUserID string) (linkedProfile, bool) {
+	if s.identities == nil {
+		return linkedProfile{}, false
+	}
+	profile, ok, err := s.identities.Profile(discordUserID)
+	if err != nil {
+		return linkedProfile{}, false
+	}
+	return profile, ok
+}
+
+// nameFunc is how a user is shown in leaderboards
+type nameFunc func(userID string) string
+
+func mention(userID string) string {
+	return fmt.Sprintf("<@%s>", userID)
+}
+
+// playerName is the display name of the user's linked profile, or a mention if they haven't
+// linked one
+func (s *server) playerName(userID string) string {
+	if profile, ok := s.linkedProfile(userID); ok && profile.DisplayName != "" {
+		return escapeName(profile.DisplayName)
+	}
+	return mention(userID)
+}
+
+// nameEscaper neutralizes markdown, mentions and line breaks in names set outside of discord so
+// they can't ping anyone or break the layout of the message they're shown in
+var nameEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"*", "\\*",
+	"_", "\\_",
+	"~", "\\~",
+	"`", "\\`",
+	"|", "\\|",
+	">", "\\>",
+	"#", "\\#",
+	"[", "\\[",
+	"]", "\\]",
+	"<", "\\<",
+	":", "\\:",
+	"@", "@\u200b",
+	"\r", " ",
+	"\n", " ",
+)
+
+func escapeName(name string) string {
+	return nameEscaper.Replace(name)
+}
+
+func (s *server) handleLink(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding link ack: %v", err)
+	}
+	respond := func(content string) error {
+		_, err := dg.InteractionResponseEdit(i.Interaction,
+			&discordgo.WebhookEdit{
+				Content: &content,
+			},
+		)
+		return err
+	}
+
+	if s.linker == nil || s.identities == nil {
+		return respond(":x: Account linking isn't set up for this bot")
+	}
+
+	userID := interactionUserID(i)
+	var code string
+	for _, opt := range i.Interaction.ApplicationCommandData().Options {
+		if opt.Name == "code" {
+			code = strings.TrimSpace(opt.StringValue())
+		}
+	}
+
+	// without a code explain where to get one
+	if code == "" {
+		var sb strings.Builder
+		if profile, ok := s.linkedProfile(userID); ok {
+			sb.WriteString(fmt.Sprintf("You are linked to **%s**\n", escapeName(profile.DisplayName)))
+		}
+		sb.WriteString(fmt.Sprintf("Sign in at %s to get a one-time code, then use **/link code:<code>**", s.linker.codeURL()))
+		return respond(sb.String())
+	}
+
+	profile, err := s.linker.exchange(code, userID)
+	if errors.Is(err, errInvalidLinkCode) {
+		return respond(":x: That code is invalid or has already been used")
+	}
+	if err != nil {
+		respond(":x: Unable to link your account, please try again later")
+		return fmt.Errorf("linking account: %v", err)
+	}
+	profile.LinkedAt = s.clk().Now()
+	if err := s.identities.Link(userID, profile); err != nil {
+		respond(":x: Unable to link your account, please try again later")
+		return fmt.Errorf("saving linked account: %v", err)
+	}
+	return respond(fmt.Sprintf(":white_check_mark: Linked to **%s**. Rewards will be paid to this account", escapeName(profile.DisplayName)))
+}
+
+func (s *server) handleUnlink(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding unlink ack: %v", err)
+	}
+	respond := func(content string) error {
+		_, err := dg.InteractionResponseEdit(i.Interaction,
+			&discordgo.WebhookEdit{
+				Content: &content,
+			},
+		)
+		return err
+	}
+
+	if s.identities == nil {
+		return respond(":x: Account linking isn't set up for this bot")
+	}
+	removed, err := s.identities.Unlink(interactionUserID(i))
+	if err != nil {
+		respond(":x: Unable to unlink your account, please try again later")
+		return fmt.Errorf("unlinking account: %v", err)
+	}
+	if !removed {
+		return respond("You don't have a linked account")
+	}
+	return respond(":white_check_mark: Your account has been unlinked")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubLinkSite stands in for a community site. Each code can be redeemed once
+func stubLinkSite(t *testing.T, codes map[string]linkedProfile) *httptest.Server {
+	var lock sync.Mutex
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/link" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Code          string `json:"code"`
+			DiscordUserID string `json:"discordUserId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DiscordUserID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		profile, ok := codes[req.Code]
+		if !ok {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		delete(codes, req.Code)
+		json.NewEncoder(w).Encode(profile)
+	}))
+	t.Cleanup(site.Close)
+	return site
+}
+
+func TestLinkCommand(t *testing.T) {
+	site := stubLinkSite(t, map[string]linkedProfile{
+		"ABC123": {AccountID: "acct-7", DisplayName: "Alice A."},
+	})
+	puzzles := testPuzzles(t)
+	s, fm := newTestServer(t, puzzles)
+	path := filepath.Join(t.TempDir(), "identities.json")
+	identities, err := loadIdentities(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.identities = identities
+	s.linker = newLinkExchanger(site.URL)
+
+	help := command(testGuild, testChannel, "alice", "link")
+	s.handleInteraction(fm, help)
+	if reply := fm.reply(help); !strings.Contains(reply, site.URL+"/link") {
+		t.Fatalf("link without a code didn't explain where to get one: %q", reply)
+	}
+
+	bad := command(testGuild, testChannel, "alice", "link", "code", "WRONG")
+	s.handleInteraction(fm, bad)
+	if reply := fm.reply(bad); !strings.Contains(reply, "invalid") {
+		t.Fatalf("bad code accepted: %q", reply)
+	}
+
+	link := command("", "alice-dm", "alice", "link", "code", "ABC123")
+	s.handleInteraction(fm, link)
+	if reply := fm.reply(link); !strings.Contains(reply, "Linked to **Alice A.**") {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+
+	// the code only works once
+	reuse := command(testGuild, testChannel, "bob", "link", "code", "ABC123")
+	s.handleInteraction(fm, reuse)
+	if reply := fm.reply(reuse); !strings.Contains(reply, "already been used") {
+		t.Fatalf("code was redeemed twice: %q", reply)
+	}
+	if _, ok := s.linkedProfile("bob"); ok {
+		t.Fatalf("bob was linked with alice's code")
+	}
+
+	// links survive a restart
+	reloaded, err := loadIdentities(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile, ok, _ := reloaded.Profile("alice"); !ok || profile.AccountID != "acct-7" {
+		t.Fatalf("link wasn't saved: %+v", profile)
+	}
+
+	// leaderboards show the linked name
+	if name := s.playerName("alice"); name != "Alice A." {
+		t.Fatalf("alice is shown as %q", name)
+	}
+	if name := s.playerName("bob"); name != "<@bob>" {
+		t.Fatalf("bob is shown as %q", name)
+	}
+	s.identities.Link("mallory", linkedProfile{AccountID: "acct-9", DisplayName: "@everyone **<@alice>**\n:first_place:"})
+	if name := s.playerName("mallory"); name != "@\u200beveryone \\*\\*\\<@\u200balice\\>\\*\\* \\:first\\_place\\:" {
+		t.Fatalf("mallory's name wasn't escaped: %q", name)
+	}
+
+	unlink := command(testGuild, testChannel, "alice", "unlink")
+	s.handleInteraction(fm, unlink)
+	if reply := fm.reply(unlink); !strings.Contains(reply, "unlinked") {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if _, ok := s.linkedProfile("alice"); ok {
+		t.Fatalf("alice is still linked")
+	}
+}
+
+func TestLinkedRewards(t *testing.T) {
+	puzzles := testPuzzles(t)
+	for _, g := range puzzles {
+		g.difficulty = MEDIUM
+		g.set = classicSet
+	}
+	s, fm := newTestServer(t, puzzles)
+	identities, _ := loadIdentities("")
+	identities.Link("alice", linkedProfile{AccountID: "acct-7", DisplayName: "Alice A."})
+	s.identities = identities
+	ml, _ := loadMemoryLedger("")
+	s.ledger = ml
+	s.rewards = defaultRewardPolicy()
+	s.rewards.Guilds = []string{testGuild}
+	g := puzzles[0]
+
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "puzzle"))
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "solve", "moves", formatMoves(g.moves)))
+	if balance := ml.balance("acct-7"); balance != 2*s.rewards.amount(MEDIUM) {
+		t.Fatalf("linked account was paid %d", balance)
+	}
+	if balance := ml.balance("alice"); balance != 0 {
+		t.Fatalf("reward went to the discord user instead of the linked account")
+	}
+
+	fc := s.clock.(*fakeClock)
+	fc.waitForTimers(t, 2)
+	fc.Advance(optimalGracePeriod)
+	recap := fm.waitFor(t, testChannel, "is closed")
+	if !strings.Contains(recap.content, ":first_place:| Alice A. **") {
+		t.Fatalf("recap doesn't use the linked name:\n%s", recap.content)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 type direction uint8
@@ -26,8 +27,16 @@ type game struct {
 	id               string
 
 	difficulty         difficulty
+	set                *boardSet
 	quadrants          []int
 	lenOptimalSolution int
+
+	// discord display name of whoever designed a custom board
+	author string
+
+	// searches give up once the deadline passes, zero means no deadline
+	deadline time.Time
+	timedOut bool
 }
 
 const (
@@ -36,6 +45,7 @@ const (
 	LEFT
 	RIGHT
 	ROBOT
+	BLOCKED
 )
 
 type robot struct {
@@ -85,6 +95,11 @@ func reverse(d direction) direction {
 
 type square uint32
 
+// height is the number of rows on the board. g.size is the number of columns
+func (g *game) height() int {
+	return len(g.board) / g.size
+}
+
 func (g *game) offset(d direction) int {
 	switch d {
 	case UP:
@@ -131,9 +146,9 @@ func (g *game) move(r *robot, dir direction) bool {
 		}
 	}
 
-	// if next square has robot, abort
+	// if next square has robot or can't be entered, abort
 	next := uint32(int(r.position) + g.offset(dir))
-	if g.board[next]&square(ROBOT) != 0 {
+	if g.board[next]&square(ROBOT|BLOCKED) != 0 {
 		return false
 	}
 
@@ -143,9 +158,9 @@ func (g *game) move(r *robot, dir direction) bool {
 		if g.hasWall(end, dir) {
 			break
 		}
-		// if next square has robot, abort
+		// if next square has robot or can't be entered, abort
 		next := uint32(int(end) + g.offset(dir))
-		if g.board[next]&square(ROBOT) != 0 {
+		if g.board[next]&square(ROBOT|BLOCKED) != 0 {
 			break
 		}
 		end = next
@@ -205,6 +220,13 @@ func (g *game) search(depth int, maxDepth int) bool {
 	}
 
 	g.visits += 1
+	// only check the time occasionally since this is the hot path
+	if !g.deadline.IsZero() && g.visits%4096 == 0 && time.Now().After(g.deadline) {
+		g.timedOut = true
+	}
+	if g.timedOut {
+		return false
+	}
 
 	var breakpoint bool
 	/*
@@ -271,6 +293,10 @@ func (g *game) solve(maxDepth int) string {
 	defer cleanup()
 
 	for currentMaxDepth := 1; currentMaxDepth < maxDepth; currentMaxDepth++ {
+		if !g.deadline.IsZero() && time.Now().After(g.deadline) {
+			g.timedOut = true
+			break
+		}
 		success := g.search(0, currentMaxDepth)
 		//fmt.Println("cache-size:", len(g.cache))
 		if success {
@@ -390,79 +416,23 @@ func (g *game) setRobot(id byte, pos uint32) {
 	}
 }
 
-func parseBoard(input string, quadrants []int) game {
-	/*(
-		input := `•---•---•---•
-	| R     | B |
-	•   •   •   •
-	|     r     |
-	•   •   •   •
-	|         G |
-	•---•---•---•`
-
-		input = fullBoard
-	*/
-
-	// I can do smarter parsing without the edge cases by always working in 3 part rows
-	// just only advance the row pointer by 2
-	// read everything into buffer initially
-
-	// TODO: trim trailing newlines when input comes from files
-	input = strings.TrimSpace(input)
-	lines := strings.Split(input, "\n")
-	size := len(strings.Split(lines[0], "---")) - 1
-
-	board := make([]square, size*size)
-	robots := make(map[byte]*robot)
-	goals := make([]Goal, 0)
-
-	for row := 0; row < size; row++ {
-		for col := 0; col < size; col++ {
-			// top
-			tLine := lines[row*2]
-			if tLine[(col*6)+5] == '-' { // weird math cause 3byte utf8 char
-				board[(row*size)+col] = board[(row*size)+col] | square(UP)
-			}
-			// bottom
-			bLine := lines[(row*2)+2]
-			if bLine[(col*6)+5] == '-' {
-				board[(row*size)+col] = board[(row*size)+col] | square(DOWN)
-			}
-			// left
-			mLine := lines[(row*2)+1]
-			if mLine[col*4] == '|' {
-				board[(row*size)+col] = board[(row*size)+col] | square(LEFT)
-			}
-			// right
-			if mLine[(col+1)*4] == '|' {
-				board[(row*size)+col] = board[(row*size)+col] | square(RIGHT)
-			}
-			//center
-			if mLine[(col*4)+2] != ' ' {
-				c := mLine[(col*4)+2]
-				if c >= 'A' && c <= 'Z' {
-					board[(row*size)+col] = board[(row*size)+col] | square(ROBOT)
-					robots[c] = &robot{id: c, position: uint32((row * size) + col)}
-				}
-				if c >= 'a' && c <= 'z' {
-					goals = append(goals, Goal{id: c - 32, position: uint32((row * size) + col)})
-				}
-			}
+// ensureRobots adds any robots missing from the parsed board on the first free squares.
+// Boards without robots drawn on them rely on the robots being placed later
+func (g *game) ensureRobots() {
+	next := 0
+	for _, id := range possibleRobots {
+		if _, ok := g.robots[id]; ok {
+			continue
+		}
+		for g.board[next]&square(ROBOT) != 0 {
+			next++
 		}
+		g.board[next] |= square(ROBOT)
+		g.robots[id] = &robot{id: id, position: uint32(next)}
 	}
-
-	g := game{
-		size:        size,
-		board:       board,
-		robots:      robots,
-		goals:       goals,
-		activeRobot: robots['R'],
-		activeGoal:  goals[0],
-		cache:       make(map[uint32]int),
-		quadrants:   quadrants,
+	if g.activeRobot == nil {
+		g.activeRobot = g.robots[g.activeGoal.id]
 	}
-
-	return g
 }
 
 func (g *game) clone() game {
@@ -477,17 +447,38 @@ func (g *game) clone() game {
 	goals := make([]Goal, len(g.goals))
 	copy(goals, g.goals)
 
+	var quadrants []int
+	if g.quadrants != nil {
+		quadrants = make([]int, len(g.quadrants))
+		copy(quadrants, g.quadrants)
+	}
+
+	var precomputedMoves []uint32
+	if g.precomputedMoves != nil {
+		precomputedMoves = make([]uint32, len(g.precomputedMoves))
+		copy(precomputedMoves, g.precomputedMoves)
+	}
+
+	// moves, visits and cache are search state so the copy starts fresh
 	ng := game{
-		size:        g.size,
-		board:       board,
-		robots:      robots,
-		moves:       make([]move, 0),
-		cache:       make(map[uint32]int),
-		visits:      0,
-		activeRobot: robots[g.activeRobot.id],
-		goals:       goals,
-		activeGoal:  g.activeGoal,
-		id:          g.id,
+		size:               g.size,
+		board:              board,
+		robots:             robots,
+		moves:              make([]move, 0),
+		cache:              make(map[uint32]int),
+		visits:             0,
+		goals:              goals,
+		activeGoal:         g.activeGoal,
+		precomputedMoves:   precomputedMoves,
+		id:                 g.id,
+		difficulty:         g.difficulty,
+		set:                g.set,
+		quadrants:          quadrants,
+		lenOptimalSolution: g.lenOptimalSolution,
+		author:             g.author,
+	}
+	if g.activeRobot != nil {
+		ng.activeRobot = robots[g.activeRobot.id]
 	}
 	return ng
 }
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// messenger is the part of the discord API used by the command handlers. *discordgo.Session
+// talks to discord, tests use an in-memory fake so commands can be driven without a token
+type messenger interface {
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit) (*discordgo.Message, error)
+	ChannelMessageSend(channelID string, content string) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error)
+}
+
+var _ messenger = (*discordgo.Session)(nil)
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// fakeMessenger records everything the handlers send instead of talking to discord
+type fakeMessenger struct {
+	lock    sync.Mutex
+	nextID  int
+	replies map[*discordgo.Interaction]string
+	// names of files attached to each interaction's response
+	replyFiles map[*discordgo.Interaction][]string
+	messages   []fakeMessage
+}
+
+type fakeMessage struct {
+	channelID string
+	content   string
+	files     []string
+}
+
+func newFakeMessenger() *fakeMessenger {
+	return &fakeMessenger{
+		replies:    make(map[*discordgo.Interaction]string),
+		replyFiles: make(map[*discordgo.Interaction][]string),
+	}
+}
+
+func (fm *fakeMessenger) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+	if resp.Data != nil {
+		fm.replies[interaction] = resp.Data.Content
+	}
+	return nil
+}
+
+func (fm *fakeMessenger) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+	if newresp.Content != nil {
+		fm.replies[interaction] = *newresp.Content
+	}
+	for _, f := range newresp.Files {
+		fm.replyFiles[interaction] = append(fm.replyFiles[interaction], f.Name)
+	}
+	return fm.message(interaction.ChannelID, fm.replies[interaction]), nil
+}
+
+func (fm *fakeMessenger) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	return fm.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+func (fm *fakeMessenger) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+	msg := fakeMessage{channelID: channelID, content: data.Content}
+	for _, f := range data.Files {
+		msg.files = append(msg.files, f.Name)
+	}
+	fm.messages = append(fm.messages, msg)
+	return fm.message(channelID, data.Content), nil
+}
+
+// message must be called with the lock held
+func (fm *fakeMessenger) message(channelID, content string) *discordgo.Message {
+	fm.nextID++
+	return &discordgo.Message{ID: fmt.Sprintf("%d", fm.nextID), ChannelID: channelID, Content: content}
+}
+
+// reply returns the latest response to the interaction
+func (fm *fakeMessenger) reply(i *discordgo.InteractionCreate) string {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+	return fm.replies[i.Interaction]
+}
+
+// find returns the first message posted in the channel containing substr
+func (fm *fakeMessenger) find(channelID, substr string) (fakeMessage, bool) {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+	for _, msg := range fm.messages {
+		if msg.channelID == channelID && strings.Contains(msg.content, substr) {
+			return msg, true
+		}
+	}
+	return fakeMessage{}, false
+}
+
+// waitFor waits for a message containing substr to be posted in the channel
+func (fm *fakeMessenger) waitFor(t *testing.T, channelID, substr string) fakeMessage {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 10)
+	for time.Now().Before(deadline) {
+		if msg, ok := fm.find(channelID, substr); ok {
+			return msg
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	t.Fatalf("no message containing %q was posted in %s", substr, channelID)
+	return fakeMessage{}
+}
+
+// command builds a slash command used by userID in the channel. Options alternate between
+// name and value
+func command(guildID, channelID, userID, name string, options ...interface{}) *discordgo.InteractionCreate {
+	data := discordgo.ApplicationCommandInteractionData{Name: name}
+	for idx := 0; idx+1 < len(options); idx += 2 {
+		opt := &discordgo.ApplicationCommandInteractionDataOption{Name: options[idx].(string)}
+		switch v := options[idx+1].(type) {
+		case string:
+			opt.Type = discordgo.ApplicationCommandOptionString
+			opt.Value = v
+		case int:
+			opt.Type = discordgo.ApplicationCommandOptionInteger
+			opt.Value = float64(v)
+		case bool:
+			opt.Type = discordgo.ApplicationCommandOptionBoolean
+			opt.Value = v
+		}
+		data.Options = append(data.Options, opt)
+	}
+
+	user := &discordgo.User{ID: userID, Username: userID}
+	interaction := &discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   guildID,
+		ChannelID: channelID,
+		Data:      data,
+	}
+	if guildID == "" {
+		interaction.User = user
+	} else {
+		interaction.Member = &discordgo.Member{User: user}
+	}
+	return &discordgo.InteractionCreate{Interaction: interaction}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxBoardSquares is the largest board that fits in a puzzle ID, robot positions are stored as a byte
+const maxBoardSquares = 256
+
+// boardParseError reports where in the ascii input a board could not be parsed. Line and column
+// are 1 indexed and count characters, not bytes
+type boardParseError struct {
+	line   int
+	column int
+	msg    string
+}
+
+func (e *boardParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.line, e.column, e.msg)
+}
+
+// parseBoard reads a board drawn in ascii. Every square is 4 characters wide and 2 lines tall:
+//
+//	•---•---•
+//	| R   b |
+//	•---•---•
+//
+// Corners may be drawn with • or +. Walls are drawn with --- and | and the outer edge must be
+// walled. Robots are uppercase letters, goals are lowercase letters and X marks a square robots
+// can't enter. Boards don't need to be square but can have at most maxBoardSquares squares.
+func parseBoard(input string, quadrants []int) (game, error) {
+	return parseSquares(input, quadrants, true)
+}
+
+// parseTile reads a quadrant tile in the same format as parseBoard. The edges that join the
+// other tiles don't need to be walled
+func parseTile(input string) (game, error) {
+	return parseSquares(input, nil, false)
+}
+
+func parseSquares(input string, quadrants []int, walled bool) (game, error) {
+	input = strings.ReplaceAll(input, "\r\n", "\n")
+	rawLines := strings.Split(input, "\n")
+
+	// skip blank lines around the board, keeping track of where the board started for errors
+	first, last := 0, len(rawLines)-1
+	for first <= last && strings.TrimSpace(rawLines[first]) == "" {
+		first++
+	}
+	for last >= first && strings.TrimSpace(rawLines[last]) == "" {
+		last--
+	}
+	if first > last {
+		return game{}, &boardParseError{line: 1, column: 1, msg: "empty board"}
+	}
+
+	lines := make([][]rune, 0, last-first+1)
+	for _, l := range rawLines[first : last+1] {
+		lines = append(lines, []rune(strings.TrimRightFunc(l, unicode.IsSpace)))
+	}
+	errorAt := func(lineIdx, col int, format string, args ...interface{}) error {
+		return &boardParseError{line: first + lineIdx + 1, column: col + 1, msg: fmt.Sprintf(format, args...)}
+	}
+
+	lineWidth := len(lines[0])
+	if lineWidth < 5 || (lineWidth-1)%4 != 0 {
+		return game{}, errorAt(0, lineWidth, "top line must be made of 4 character squares i.e. •---•")
+	}
+	if len(lines)%2 == 0 || len(lines) < 3 {
+		return game{}, errorAt(len(lines)-1, 0, "board must alternate corner lines and square lines, found %d lines", len(lines))
+	}
+	width := (lineWidth - 1) / 4
+	height := (len(lines) - 1) / 2
+	if width*height > maxBoardSquares {
+		return game{}, errorAt(0, 0, "board is %dx%d, at most %d squares are supported", width, height, maxBoardSquares)
+	}
+
+	// right pad lines whose trailing spaces were trimmed
+	for idx, l := range lines {
+		if len(l) > lineWidth {
+			return game{}, errorAt(idx, lineWidth, "line is longer than the top line")
+		}
+		for len(l) < lineWidth {
+			l = append(l, ' ')
+		}
+		lines[idx] = l
+	}
+
+	board := make([]square, width*height)
+	robots := make(map[byte]*robot)
+	goals := make([]Goal, 0)
+
+	// corner lines hold the walls above and below each row
+	for lineIdx := 0; lineIdx < len(lines); lineIdx += 2 {
+		l := lines[lineIdx]
+		for col := 0; col <= width; col++ {
+			if c := l[col*4]; c != '•' && c != '+' {
+				return game{}, errorAt(lineIdx, col*4, "expected corner • or + but found %q", c)
+			}
+		}
+		for col := 0; col < width; col++ {
+			segment := string(l[col*4+1 : col*4+4])
+			switch segment {
+			case "---":
+				if row := lineIdx / 2; row < height {
+					board[row*width+col] |= square(UP)
+				}
+				if row := lineIdx/2 - 1; row >= 0 {
+					board[row*width+col] |= square(DOWN)
+				}
+			case "   ":
+				// robots would move off the board
+				if walled && (lineIdx == 0 || lineIdx == len(lines)-1) {
+					return game{}, errorAt(lineIdx, col*4+1, "edge of the board must be walled")
+				}
+			default:
+				return game{}, errorAt(lineIdx, col*4+1, "expected wall --- or empty space but found %q", segment)
+			}
+		}
+	}
+
+	// square lines hold the walls left and right of each square and what's inside it
+	for lineIdx := 1; lineIdx < len(lines); lineIdx += 2 {
+		l := lines[lineIdx]
+		row := lineIdx / 2
+		for col := 0; col <= width; col++ {
+			switch c := l[col*4]; c {
+			case '|':
+				if col < width {
+					board[row*width+col] |= square(LEFT)
+				}
+				if col > 0 {
+					board[row*width+col-1] |= square(RIGHT)
+				}
+			case ' ':
+				if walled && (col == 0 || col == width) {
+					return game{}, errorAt(lineIdx, col*4, "edge of the board must be walled")
+				}
+			default:
+				return game{}, errorAt(lineIdx, col*4, "expected wall | or empty space but found %q", c)
+			}
+		}
+
+		for col := 0; col < width; col++ {
+			if l[col*4+1] != ' ' || l[col*4+3] != ' ' {
+				return game{}, errorAt(lineIdx, col*4+1, "square contents must be a single character surrounded by spaces")
+			}
+
+			pos := row*width + col
+			switch c := l[col*4+2]; {
+			case c == ' ':
+			case c == 'X':
+				// blocked squares i.e. the center of the board
+				board[pos] |= square(BLOCKED)
+			case c == 'R' || c == 'G' || c == 'B' || c == 'Y':
+				if _, ok := robots[byte(c)]; ok {
+					return game{}, errorAt(lineIdx, col*4+2, "duplicate %c robot", c)
+				}
+				board[pos] |= square(ROBOT)
+				robots[byte(c)] = &robot{id: byte(c), position: uint32(pos)}
+			case c == 'r' || c == 'g' || c == 'b' || c == 'y':
+				goals = append(goals, Goal{id: byte(c) - 32, position: uint32(pos)})
+			default:
+				return game{}, errorAt(lineIdx, col*4+2, "unknown square contents %q, expected a robot (RGBY), goal (rgby) or X", c)
+			}
+		}
+	}
+
+	g := game{
+		size:      width,
+		board:     board,
+		robots:    robots,
+		goals:     goals,
+		cache:     make(map[uint32]int),
+		quadrants: quadrants,
+	}
+	if len(goals) > 0 {
+		g.activeGoal = goals[0]
+	}
+	g.activeRobot = robots[g.activeGoal.id]
+
+	return g, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBoardErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"empty", "\n  \n", "line 1, column 1: empty board"},
+		{"bad top line", "•---•--\n|   |\n•---•", "line 1, column 8"},
+		{"even lines", "•---•\n|   |", "alternate corner lines"},
+		{"bad corner", "•---•\n|   |\n•---*", "line 3, column 5: expected corner"},
+		{"bad wall", "•---•\n|   |\n•-=-•", "line 3, column 2: expected wall ---"},
+		{"bad side wall", "•---•\n|   /\n•---•", "line 2, column 5: expected wall |"},
+		{"unknown contents", "•---•\n| Q |\n•---•", "line 2, column 3: unknown square contents"},
+		{"crowded square", "•---•\n|RR |\n•---•", "line 2, column 2"},
+		{"duplicate robot", "•---•---•\n| R   R |\n•---•---•", "line 2, column 7: duplicate R robot"},
+		{"long line", "•---•\n|   |   |\n•---•", "line 2, column 6: line is longer"},
+		{"too big", "•" + strings.Repeat("---•", 17) + "\n|" + strings.Repeat("    ", 17) + "\n" +
+			strings.Repeat("•"+strings.Repeat("---•", 17)+"\n|"+strings.Repeat("    ", 17)+"\n", 15) +
+			"•" + strings.Repeat("---•", 17), "at most 256 squares"},
+		{"open top edge", "•---•   •\n|       |\n•---•---•", "line 1, column 6: edge of the board must be walled"},
+		{"open right edge", "•---•---•\n|        \n•---•---•", "line 2, column 9: edge of the board must be walled"},
+		// errors are reported relative to the original input including leading blank lines
+		{"offset", "\n\n•---•\n| ? |\n•---•", "line 4, column 3"},
+	}
+
+	for _, tc := range tests {
+		_, err := parseBoard(tc.input, nil)
+		if err == nil {
+			t.Fatalf("%s: expected error", tc.name)
+		}
+		if !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected error containing %q, got %q", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestParseNonSquareBoard(t *testing.T) {
+	// ascii corners and no R robot
+	input := `
++---+---+---+
+| G       b |
++   +   +   +
+|     Y   B |
++---+---+---+
+`
+	g, err := parseBoard(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.size != 3 || g.height() != 2 {
+		t.Fatalf("expected 3x2 board, got %dx%d", g.size, g.height())
+	}
+	if g.activeGoal.id != 'B' || g.activeRobot == nil || g.activeRobot.id != 'B' {
+		t.Fatalf("active robot should match the goal color")
+	}
+	if err := validateBoard(&g); err != nil {
+		t.Fatal(err)
+	}
+
+	// trimming the right wall would let robots leave the board
+	_, err = parseBoard(strings.Replace(input, "b |", "b", 1), nil)
+	if err == nil || !strings.Contains(err.Error(), "line 3, column 13: edge of the board must be walled") {
+		t.Fatalf("expected open right edge to be rejected, got %v", err)
+	}
+
+	g.ensureRobots()
+	g.precomputedMoves = g.preCompute(g.activeGoal.position)
+	moves, err := parseMoves(g.solve(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || !validate(&g, g.board, moves, g.activeGoal) {
+		t.Fatalf("unexpected solution: %v", moves)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Direct messages are a private practice space. Each DM channel gets its own instance so users
+// can play at their own pace without spoiling the puzzle for anyone else. Practice solves are
+// recorded with an empty guild ID
+
+// commands that can be used in a DM. Everything else only makes sense in a guild
+var practiceCommands = map[string]bool{
+	"puzzle":      true,
+	"solve":       true,
+	"share":       true,
+	"help":        true,
+	"how-to-play": true,
+	"stats":       true,
+	"link":        true,
+	"unlink":      true,
+}
+
+// interactionUserID returns who used a command. Member is only set in guilds and User only in DMs
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// practiceInstance returns the user's practice space, creating it the first time they DM the bot
+func (s *server) practiceInstance(i *discordgo.InteractionCreate) *discordInstance {
+	s.instancesLock.Lock()
+	defer s.instancesLock.Unlock()
+	if instance, ok := s.instances[i.ChannelID]; ok {
+		return instance
+	}
+
+	// no one else can see the channel so there is nothing to announce or lock out
+	config := defaultGuildConfig()
+	config.LockoutMinutes = 0
+	config.AnnounceSolves = false
+	config.Spoilers = false
+
+	instance := &discordInstance{
+		channelID: i.ChannelID,
+		practice:  true,
+		config:    config,
+		clock:     s.clock,
+	}
+	s.instances[i.ChannelID] = instance
+	return instance
+}
+
+type practiceStats struct {
+	solved  int
+	optimal int
+	// optimal solutions in a row, most recent first
+	streak int
+}
+
+// practiceStatsFor summarizes a user's practice solves. Each puzzle only counts once, using the
+// best solution submitted for it
+func practiceStatsFor(subs []submission) practiceStats {
+	best := make(map[string]submission)
+	var order []string
+	for _, sub := range subs {
+		if sub.GuildID != "" || sub.Archived {
+			continue
+		}
+		prev, ok := best[sub.PuzzleID]
+		if !ok {
+			order = append(order, sub.PuzzleID)
+		}
+		if !ok || sub.NumMoves < prev.NumMoves {
+			best[sub.PuzzleID] = sub
+		}
+	}
+
+	var stats practiceStats
+	streakBroken := false
+	for idx := len(order) - 1; idx >= 0; idx-- {
+		sub := best[order[idx]]
+		stats.solved++
+		if sub.excess() == 0 {
+			stats.optimal++
+			if !streakBroken {
+				stats.streak++
+			}
+		} else {
+			streakBroken = true
+		}
+	}
+	return stats
+}
+
+func practicePuzzleContent(g *game) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Practice Puzzle:** #__%s__ -- %s\n", g.id, g.difficulty))
+	sb.WriteString(fmt.Sprintf("Get the %s robot to the goal. Use **/puzzle** whenever you want a new one", goalColorName(g.activeGoal.id)))
+	return sb.String()
+}
+
+func practiceSolveContent(moves []move, optimal int, stats practiceStats) string {
+	var sb strings.Builder
+	if len(moves) == optimal {
+		sb.WriteString(fmt.Sprintf(":tada:**optimal**:tada: %d move solution!\n", len(moves)))
+	} else {
+		sb.WriteString(fmt.Sprintf("%d move solution, see if you can find a shorter one\n", len(moves)))
+	}
+	sb.WriteString(fmt.Sprintf("Practice record: **%d** solved, **%d** optimal", stats.solved, stats.optimal))
+	if stats.streak > 1 {
+		sb.WriteString(fmt.Sprintf(", **%d** optimal in a row", stats.streak))
+	}
+	return sb.String()
+}
+
+func goalColorName(id byte) string {
+	switch id {
+	case 'R':
+		return "Red"
+	case 'B':
+		return "Blue"
+	case 'Y':
+		return "Yellow"
+	case 'G':
+		return "Green"
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestPracticeInstance(t *testing.T) {
+	s := &server{
+		instances:       make(map[string]*discordInstance),
+		primaryChannels: make(map[string]string),
+	}
+	s.setPrimaryChannel("guild", "ricochet", defaultGuildConfig())
+
+	dm := func(channelID, userID string) *discordgo.InteractionCreate {
+		return &discordgo.InteractionCreate{
+			Interaction: &discordgo.Interaction{ChannelID: channelID, User: &discordgo.User{ID: userID}},
+		}
+	}
+
+	alice := s.instanceFor(dm("dm-alice", "alice"))
+	bob := s.instanceFor(dm("dm-bob", "bob"))
+	if !alice.practice || alice == bob {
+		t.Fatalf("expected a separate practice instance per DM")
+	}
+	if s.instanceFor(dm("dm-alice", "alice")) != alice {
+		t.Fatalf("practice instance was not reused")
+	}
+	if alice.config.AnnounceSolves || alice.newPuzzleBlocked() != "" {
+		t.Fatalf("practice instances should be quiet and never locked out")
+	}
+	if interactionUserID(dm("dm-alice", "alice")) != "alice" {
+		t.Fatalf("user ID not read from a DM interaction")
+	}
+}
+
+func TestPracticeStats(t *testing.T) {
+	subs := []submission{
+		{PuzzleID: "a", NumMoves: 9, Optimal: 7},
+		{PuzzleID: "a", NumMoves: 7, Optimal: 7},
+		{PuzzleID: "guild", GuildID: "guild", NumMoves: 7, Optimal: 7},
+		{PuzzleID: "archived", NumMoves: 7, Optimal: 7, Archived: true},
+		{PuzzleID: "b", NumMoves: 10, Optimal: 8},
+		{PuzzleID: "c", NumMoves: 8, Optimal: 8},
+		{PuzzleID: "d", NumMoves: 6, Optimal: 6},
+	}
+	stats := practiceStatsFor(subs)
+	if stats.solved != 4 || stats.optimal != 3 || stats.streak != 2 {
+		t.Fatalf("unexpected practice stats %+v", stats)
+	}
+}
+
+func TestPracticePuzzleCommand(t *testing.T) {
+	puzzles := testPuzzles(t)
+	s, fm := newTestServer(t, puzzles)
+	g := puzzles[0]
+
+	// DM interactions carry a user instead of a member
+	i := command("", "dm-alice", "alice", "puzzle")
+	s.handleInteraction(fm, i)
+	if reply := fm.reply(i); reply != "Puzzle created successfully" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	fm.waitFor(t, "dm-alice", "**Practice Puzzle:** #__"+g.id+"__")
+
+	instance := s.instanceFor(i)
+	instance.lock.Lock()
+	starting := instance.starting
+	instance.lock.Unlock()
+	if starting || instance.game() != g {
+		t.Fatalf("practice puzzle wasn't made active")
+	}
+
+	solve := command("", "dm-alice", "alice", "solve", "moves", formatMoves(g.moves))
+	s.handleInteraction(fm, solve)
+	if reply := fm.reply(solve); !strings.Contains(reply, "Puzzle Solved") {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if _, ok := fm.find(testChannel, g.id); ok {
+		t.Fatalf("practice puzzle leaked into the guild channel")
+	}
+}
@@ -14,7 +14,7 @@ func printBoard(board []square, size int, robots map[byte]*robot, goal Goal) str
 	robotPositions[goal.position] = goal.id + 32
 
 	// one row at a time
-	for row := 0; row < size; row += 1 {
+	for row := 0; row < len(board)/size; row += 1 {
 		// top
 		b.WriteRune('•')
 		for col := 0; col < size; col += 1 {
@@ -57,3 +57,72 @@ func printBoard(board []square, size int, robots map[byte]*robot, goal Goal) str
 	//fmt.Println(b.String())
 	return b.String()
 }
+
+// formatBoard writes the board in the same ascii format parseBoard reads. Unlike printBoard every
+// wall is drawn so it also works for partial boards like quadrant tiles. Blocked squares are
+// drawn as X, robots uppercase and goals lowercase
+func formatBoard(g *game) string {
+	var b strings.Builder
+
+	content := make(map[int]byte)
+	for _, goal := range g.goals {
+		content[int(goal.position)] = goal.id + 32
+	}
+	for _, r := range g.robots {
+		content[int(r.position)] = r.id
+	}
+
+	rows := g.height()
+	for row := 0; row < rows; row++ {
+		// top
+		b.WriteRune('•')
+		for col := 0; col < g.size; col++ {
+			if g.board[(row*g.size)+col]&square(UP) != 0 {
+				b.WriteString("---")
+			} else {
+				b.WriteString("   ")
+			}
+			b.WriteRune('•')
+		}
+		b.WriteString("\n")
+
+		// mid
+		if g.board[row*g.size]&square(LEFT) != 0 {
+			b.WriteString("|")
+		} else {
+			b.WriteString(" ")
+		}
+		for col := 0; col < g.size; col++ {
+			sq := g.board[(row*g.size)+col]
+			b.WriteString(" ")
+			if sq&square(BLOCKED) != 0 {
+				b.WriteString("X")
+			} else if c, ok := content[(row*g.size)+col]; ok {
+				b.WriteByte(c)
+			} else {
+				b.WriteString(" ")
+			}
+			b.WriteString(" ")
+
+			if sq&square(RIGHT) != 0 {
+				b.WriteString("|")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	// bottom
+	b.WriteRune('•')
+	for col := 0; col < g.size; col++ {
+		if g.board[((rows-1)*g.size)+col]&square(DOWN) != 0 {
+			b.WriteString("---")
+		} else {
+			b.WriteString("   ")
+		}
+		b.WriteRune('•')
+	}
+
+	return b.String()
+}
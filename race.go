@@ -0,0 +1,428 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var raceTemplate = `%s used **/race**
+
+Every goal on the board will be played in order, **%d** in total. Robots stay where the winning
+solution left them so each goal starts from the end of the last one.
+The shortest solution wins the goal, ties go to whoever submitted first. You have **%d** minutes
+per goal, or until someone finds the optimal solution.
+
+Race begins: **<t:%d:R>**`
+
+var raceStartTime = time.Second * 30
+
+var defaultRaceDuration = 3
+
+// raceSolveDepth limits the search for each goal. Goals that can't be solved from where the
+// robots ended up are skipped
+const raceSolveDepth = 15
+
+// raceSolveTimeout limits how long each goal is searched, goals that take longer are skipped
+var raceSolveTimeout = time.Second * 30
+
+// race walks through every goal on a single board. Unlike other puzzles the robots are not
+// reset between goals
+type race struct {
+	lock sync.Mutex
+
+	// robots are left where the last winning solution put them
+	board *game
+	deck  []Goal
+	next  int // index in deck of the next goal to play
+
+	current  *game
+	best     []move
+	bestUser string
+	optimal  chan struct{} // closed when the current goal is solved optimally
+
+	points map[string]int
+
+	// solves of the next goal started while the current one is played, keyed by raceSolveKey.
+	// Each new best solution could leave the robots somewhere else so there may be several
+	solves map[string]*raceSolve
+}
+
+// raceSolve is a background solve of a goal from one arrangement of the robots
+type raceSolve struct {
+	done  chan struct{} // closed once moves is set
+	moves []move
+}
+
+func newRace(board *game) *race {
+	goals := make([]Goal, len(board.goals))
+	copy(goals, board.goals)
+	return &race{
+		board:  board,
+		deck:   goals,
+		points: make(map[string]int),
+		solves: make(map[string]*raceSolve),
+	}
+}
+
+// raceSolveKey identifies a goal in the deck played from where the robots are on board
+func raceSolveKey(board *game, goalIdx int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d", goalIdx))
+	for _, id := range possibleRobots {
+		if rb, ok := board.robots[id]; ok {
+			sb.WriteString(fmt.Sprintf(" %c%d", id, rb.position))
+		}
+	}
+	return sb.String()
+}
+
+// solveLocked starts solving the goal at goalIdx from where the robots are on board, reusing a
+// solve that is already running. Must be called with the lock held
+func (r *race) solveLocked(board *game, goalIdx int) *raceSolve {
+	if goalIdx >= len(r.deck) {
+		return nil
+	}
+	key := raceSolveKey(board, goalIdx)
+	if rs, ok := r.solves[key]; ok {
+		return rs
+	}
+	rs := &raceSolve{done: make(chan struct{})}
+	r.solves[key] = rs
+
+	goal := r.deck[goalIdx]
+	g := board.clone()
+	g.activeGoal = goal
+	g.activeRobot = g.robots[goal.id]
+	g.deadline = time.Now().Add(raceSolveTimeout)
+	go func() {
+		defer close(rs.done)
+		if g.activeRobot.position == goal.position {
+			return
+		}
+		g.precomputedMoves = g.preCompute(goal.position)
+		moves, err := parseMoves(g.solve(raceSolveDepth))
+		if g.timedOut {
+			log.Printf("race goal %c at %d took too long to solve", goal.id, goal.position)
+			return
+		}
+		if err == nil {
+			rs.moves = moves
+		}
+	}()
+	return rs
+}
+
+// nextRound sets up the next playable goal in the deck. Returns false once the deck is exhausted.
+// The goal is usually solved already since solving starts while the previous goal is played
+func (r *race) nextRound() (*game, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for r.next < len(r.deck) {
+		goal := r.deck[r.next]
+		rs := r.solveLocked(r.board, r.next)
+		r.next++
+
+		g := r.board.clone()
+		g.activeGoal = goal
+		g.activeRobot = g.robots[goal.id]
+		if g.activeRobot.position == goal.position {
+			continue
+		}
+		g.precomputedMoves = g.preCompute(goal.position)
+
+		// players checking on the race shouldn't wait on the solve. Nothing else changes the
+		// board or deck between rounds
+		r.lock.Unlock()
+		<-rs.done
+		r.lock.Lock()
+		if len(rs.moves) == 0 {
+			log.Printf("skipping race goal %c at %d: no solution", goal.id, goal.position)
+			continue
+		}
+		g.moves = rs.moves
+		g.lenOptimalSolution = len(rs.moves)
+		g.difficulty = difficultyFromMoves(len(rs.moves))
+		if id, err := encode(&g); err == nil {
+			g.id = id
+		}
+
+		r.current = &g
+		r.best = nil
+		r.bestUser = ""
+		r.optimal = make(chan struct{})
+
+		// start on the next goal in case no one solves this one. Solves for other arrangements
+		// are dropped, anything still running finishes in the background
+		r.solves = make(map[string]*raceSolve)
+		r.solveLocked(r.board, r.next)
+		return r.current, true
+	}
+	r.current = nil
+	return nil, false
+}
+
+// playMoves moves the robots on g through a valid solution
+func playMoves(g *game, moves []move) {
+	for _, m := range moves {
+		g.move(g.robots[m.id], m.dir)
+	}
+}
+
+// round is the 1 indexed position of the current goal in the deck
+func (r *race) round() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.next
+}
+
+// submit records a valid solution to g. Only the shortest, earliest solution is kept
+func (r *race) submit(g *game, userID string, moves []move) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if g != r.current {
+		return
+	}
+	if r.bestUser != "" && len(moves) >= len(r.best) {
+		return
+	}
+	r.best = moves
+	r.bestUser = userID
+	if len(moves) == r.current.lenOptimalSolution {
+		close(r.optimal)
+	}
+
+	// the next goal starts from wherever this solution leaves the robots
+	after := r.current.clone()
+	playMoves(&after, moves)
+	r.solveLocked(&after, r.next)
+}
+
+// optimalFound is closed once the current goal has been solved optimally
+func (r *race) optimalFound() <-chan struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.optimal
+}
+
+// finishRound awards the current goal and moves the robots to where the winning solution left
+// them. Returns "" if no one solved the goal
+func (r *race) finishRound() (string, []move) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	winner, moves := r.bestUser, r.best
+	if winner != "" {
+		cpy := r.current.clone()
+		playMoves(&cpy, moves)
+		for id, rb := range cpy.robots {
+			r.board.board[r.board.robots[id].position] &^= square(ROBOT)
+			r.board.robots[id].position = rb.position
+		}
+		for _, rb := range r.board.robots {
+			r.board.board[rb.position] |= square(ROBOT)
+		}
+		r.points[winner]++
+	}
+	r.current = nil
+	return winner, moves
+}
+
+type raceStanding struct {
+	userID string
+	points int
+}
+
+// standings orders players by goals won
+func (r *race) standings() []raceStanding {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var standings []raceStanding
+	for userID, points := range r.points {
+		standings = append(standings, raceStanding{userID: userID, points: points})
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].points != standings[j].points {
+			return standings[i].points > standings[j].points
+		}
+		return standings[i].userID < standings[j].userID
+	})
+	return standings
+}
+
+func (s *server) handleRace(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding race ack: %v", err)
+	}
+
+	respond := func(content string) error {
+		_, err := dg.InteractionResponseEdit(i.Interaction,
+			&discordgo.WebhookEdit{
+				Content: &content,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("editing race response: %v", err)
+		}
+		return nil
+	}
+
+	// look up instance
+	instance := s.instanceFor(i)
+	if reason := instance.modeDisabled("race"); reason != "" {
+		return respond(reason)
+	}
+
+	durationMinutes := defaultRaceDuration
+	for _, opt := range i.Interaction.ApplicationCommandData().Options {
+		if opt.Name == "duration" {
+			durationMinutes = int(opt.IntValue())
+		}
+	}
+	if durationMinutes < 1 {
+		durationMinutes = 1
+	}
+	if durationMinutes > 10 {
+		durationMinutes = 10
+	}
+	duration := time.Minute * time.Duration(durationMinutes)
+
+	sets := instance.enabledSets()
+	r := newRace(randomGameFromSet(sets[rand.Intn(len(sets))]))
+	if reason := instance.claim(func() { instance.activeRace = r }); reason != "" {
+		return respond(reason)
+	}
+	s.closeActiveRound(dg, instance)
+
+	raceText := fmt.Sprintf(raceTemplate, displayName(i.Interaction.Member), len(r.deck), durationMinutes, instance.clk().Now().Add(raceStartTime).Unix())
+	if _, err := dg.ChannelMessageSend(instance.channelID, raceText); err != nil {
+		instance.update(func() { instance.activeRace = nil })
+		respond(":x: Unable to create race, please try again later")
+		return fmt.Errorf("creating race: %v", err)
+	}
+	respond("Race Created")
+
+	// sleep until its time for the first goal
+	instance.clk().Sleep(raceStartTime)
+
+	for {
+		g, ok := r.nextRound()
+		if !ok {
+			break
+		}
+		instance.showPuzzle(g)
+
+		content := racePuzzleContent(g, r.round(), len(r.deck), instance.clk().Now().Add(duration))
+		if err := postPuzzle(dg, instance.channelID, content, g); err != nil {
+			cancelRace(dg, instance)
+			return err
+		}
+
+		select {
+		case <-instance.clk().After(duration):
+		case <-r.optimalFound():
+		}
+
+		winner, moves := r.finishRound()
+		if _, err := dg.ChannelMessageSend(instance.channelID, raceRoundContent(g, r.round(), winner, moves)); err != nil {
+			log.Printf("announcing race round: %v", err)
+		}
+	}
+
+	return endRace(dg, instance, r, s.playerName)
+}
+
+func endRace(dg messenger, instance *discordInstance, r *race, name nameFunc) error {
+	instance.update(func() {
+		instance.activeRace = nil
+		instance.activeGame = nil
+	})
+
+	standings := r.standings()
+	if len(standings) == 0 {
+		_, err := dg.ChannelMessageSend(instance.channelID, "no one won a single goal :cry:")
+		if err != nil {
+			return fmt.Errorf("ending empty race: %v", err)
+		}
+		return nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("**Race Results:**\n")
+	position := 0
+	bestScore := -1
+	for _, standing := range standings {
+		if standing.points != bestScore {
+			position += 1
+			bestScore = standing.points
+		}
+		var prefix string
+		switch position {
+		case 1:
+			prefix = ":first_place:"
+		case 2:
+			prefix = ":second_place:"
+		case 3:
+			prefix = ":third_place:"
+		default:
+			prefix = fmt.Sprintf("%d ", position)
+		}
+		sb.WriteString(fmt.Sprintf("%s| %s **%d goals**\n", prefix, name(standing.userID), standing.points))
+	}
+
+	_, err := dg.ChannelMessageSend(instance.channelID, sb.String())
+	if err != nil {
+		return fmt.Errorf("printing race results: %v", err)
+	}
+	return nil
+}
+
+func cancelRace(dg messenger, instance *discordInstance) error {
+	instance.update(func() {
+		instance.activeRace = nil
+		instance.activeGame = nil
+	})
+
+	content := ":x: race cancelled due to error, please try again later"
+	_, err := dg.ChannelMessageSend(instance.channelID, content)
+	if err != nil {
+		return fmt.Errorf("cancelling race: %v", err)
+	}
+	return nil
+}
+
+func racePuzzleContent(g *game, round, numGoals int, endTime time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("`-------------------------------------------------`\n"))
+	sb.WriteString(fmt.Sprintf("**Race Goal %d/%d: #%s** -- %s\n", round, numGoals, g.id, g.difficulty))
+	sb.WriteString(fmt.Sprintf("Time Remaining: **<t:%d:R>**", endTime.Unix()))
+	return sb.String()
+}
+
+func raceRoundContent(g *game, round int, winner string, moves []move) string {
+	if winner == "" {
+		return fmt.Sprintf("No one reached goal %d, the robots stay where they are", round)
+	}
+	content := fmt.Sprintf("<@%s> won goal %d with a %d move solution: %s", winner, round, len(moves), formatMoves(moves))
+	if len(moves) == g.lenOptimalSolution {
+		content += " :tada:**optimal**:tada:"
+	} else {
+		content += fmt.Sprintf(" (optimal was %d)", g.lenOptimalSolution)
+	}
+	return content
+}
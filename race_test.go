@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+const raceBoard = `
+•---•---•---•---•---•
+| R           g     |
+•   •   •---•   •   •
+|       | X |     y |
+•   •   •---•   •   •
+|     b   Y |       |
+•---•   •   •   •   •
+| B       G         |
+•---•---•---•---•---•
+`
+
+func TestRace(t *testing.T) {
+	board, err := parseBoard(raceBoard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRace(&board)
+	if len(r.deck) != 3 {
+		t.Fatalf("expected 3 goals in the deck, got %d", len(r.deck))
+	}
+
+	// round 1: green goal, the shortest and then earliest solution wins
+	g, ok := r.nextRound()
+	if !ok || g.activeGoal.id != 'G' {
+		t.Fatalf("expected first round to target the green goal")
+	}
+	solver := g.clone()
+	long, _ := parseMoves(solver.solve(10))
+	if len(long) == 0 {
+		t.Fatalf("expected a solution to the green goal")
+	}
+	r.submit(g, "slow", append(append([]move{}, long...), move{id: 'R', dir: DOWN}))
+	r.submit(g, "first", long)
+	r.submit(g, "second", long)
+	select {
+	case <-r.optimalFound():
+	default:
+		t.Fatalf("optimal solution should end the round")
+	}
+	winner, moves := r.finishRound()
+	if winner != "first" || len(moves) != len(long) {
+		t.Fatalf("expected first to win with %d moves, got %s with %d", len(long), winner, len(moves))
+	}
+
+	// robots carry over to the next round from where the winning solution left them
+	green := r.board.robots['G'].position
+	if green != g.activeGoal.position || r.board.board[green]&square(ROBOT) == 0 {
+		t.Fatalf("green robot should be on its goal after the round")
+	}
+	// the next goal was being solved from there while the round was played
+	if _, ok := r.solves[raceSolveKey(r.board, r.next)]; !ok {
+		t.Fatalf("next goal wasn't solved ahead of time from the winning position")
+	}
+
+	// round 2: no one solves it so the robots stay put
+	g, ok = r.nextRound()
+	if !ok || g.robots['G'].position != green {
+		t.Fatalf("second round should start from the previous round's positions")
+	}
+	if winner, _ := r.finishRound(); winner != "" {
+		t.Fatalf("expected no winner")
+	}
+	if r.board.robots['G'].position != green {
+		t.Fatalf("robots should not move without a winner")
+	}
+
+	// stale submissions for earlier rounds are ignored
+	g, ok = r.nextRound()
+	if !ok {
+		t.Fatalf("expected a third round")
+	}
+	r.submit(&board, "stale", long)
+	solver = g.clone()
+	solution, _ := parseMoves(solver.solve(10))
+	r.submit(g, "second", solution)
+	if winner, _ := r.finishRound(); winner != "second" {
+		t.Fatalf("expected second to win the last round, got %q", winner)
+	}
+
+	if _, ok := r.nextRound(); ok {
+		t.Fatalf("deck should be exhausted")
+	}
+	standings := r.standings()
+	if len(standings) != 2 || standings[0].points != 1 || standings[1].points != 1 || standings[0].userID != "first" {
+		t.Fatalf("unexpected standings: %+v", standings)
+	}
+}
+
+func TestRaceSolveDoesNotBlock(t *testing.T) {
+	board, err := parseBoard(raceBoard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRace(&board)
+
+	// a slow solve of the first goal is already running
+	slow := &raceSolve{done: make(chan struct{})}
+	r.solves[raceSolveKey(r.board, 0)] = slow
+	started := make(chan *game)
+	go func() {
+		g, _ := r.nextRound()
+		started <- g
+	}()
+
+	// submissions and status checks go through while the round waits on the solve
+	checked := make(chan struct{})
+	go func() {
+		r.submit(&board, "early", nil)
+		r.round()
+		r.optimalFound()
+		close(checked)
+	}()
+	select {
+	case <-checked:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("race was locked while waiting on the solve")
+	}
+
+	solver := r.board.clone()
+	solver.activeGoal = r.deck[0]
+	solver.activeRobot = solver.robots[solver.activeGoal.id]
+	solver.precomputedMoves = solver.preCompute(solver.activeGoal.position)
+	slow.moves, _ = parseMoves(solver.solve(raceSolveDepth))
+	close(slow.done)
+	if g := <-started; g == nil || g.lenOptimalSolution != len(slow.moves) {
+		t.Fatalf("round didn't start with the finished solve")
+	}
+}
+
+func TestRaceSolveTimeout(t *testing.T) {
+	board, err := parseBoard(raceBoard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(timeout time.Duration) { raceSolveTimeout = timeout }(raceSolveTimeout)
+	raceSolveTimeout = -time.Second
+
+	// goals that can't be solved in time are skipped rather than stalling the race
+	r := newRace(&board)
+	if _, ok := r.nextRound(); ok {
+		t.Fatalf("expected every goal to time out")
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// Players are rated with Glicko so a few good results count for more than many mediocre ones.
+// Each closed puzzle round and each tournament is a rating period. In a round solvers are ranked
+// against each other by solution length and against the puzzle itself, which is rated by its
+// difficulty. Tournaments rate every pair of players by their final place. The deviation grows
+// while a player is inactive so their next results move their rating further
+
+const (
+	initialRating    = 1500.0
+	initialDeviation = 350.0
+	minDeviation     = 30.0
+	// deviation regained per day of inactivity, an established player is unrated again after
+	// about a year away
+	deviationDecay = 18.1
+	// players with a higher deviation are still provisional
+	provisionalDeviation = 150.0
+	// puzzles are confident opponents, their rating only depends on difficulty
+	puzzleDeviation = 50.0
+)
+
+// puzzleRatings is the rating of the puzzle as an opponent for each difficulty
+var puzzleRatings = map[difficulty]float64{
+	EASY:    1200,
+	MEDIUM:  1500,
+	HARD:    1800,
+	EXTREME: 2100,
+}
+
+type rating struct {
+	Rating    float64   `json:"rating"`
+	Deviation float64   `json:"deviation"`
+	Games     int       `json:"games"` // rating periods the player took part in
+	Updated   time.Time `json:"updated"`
+}
+
+func newRating() rating {
+	return rating{Rating: initialRating, Deviation: initialDeviation}
+}
+
+// decayed returns the rating with its deviation grown for the time since it was last updated
+func (r rating) decayed(now time.Time) rating {
+	if r.Games == 0 || !now.After(r.Updated) {
+		return r
+	}
+	days := now.Sub(r.Updated).Hours() / 24
+	r.Deviation = math.Min(math.Sqrt(r.Deviation*r.Deviation+deviationDecay*deviationDecay*days), initialDeviation)
+	return r
+}
+
+func (r rating) provisional() bool {
+	return r.Games == 0 || r.Deviation > provisionalDeviation
+}
+
+// difficulty is the puzzle difficulty closest to the player's rating. Extreme puzzles are left
+// for players to ask for
+func (r rating) difficulty() difficulty {
+	switch {
+	case r.Rating < (puzzleRatings[EASY]+puzzleRatings[MEDIUM])/2:
+		return EASY
+	case r.Rating < (puzzleRatings[MEDIUM]+puzzleRatings[HARD])/2:
+		return MEDIUM
+	default:
+		return HARD
+	}
+}
+
+// outcome is a single result in a rating period. Score is 1 for a win, 0.5 for a draw and 0 for
+// a loss
+type outcome struct {
+	opponent rating
+	score    float64
+}
+
+var glickoQ = math.Ln10 / 400
+
+func glickoG(deviation float64) float64 {
+	return 1 / math.Sqrt(1+3*glickoQ*glickoQ*deviation*deviation/(math.Pi*math.Pi))
+}
+
+// glicko rates a player over one rating period
+func glicko(r rating, outcomes []outcome) rating {
+	if len(outcomes) == 0 {
+		return r
+	}
+	var dInv, delta float64
+	for _, o := range outcomes {
+		g := glickoG(o.opponent.Deviation)
+		e := 1 / (1 + math.Pow(10, -g*(r.Rating-o.opponent.Rating)/400))
+		dInv += glickoQ * glickoQ * g * g * e * (1 - e)
+		delta += g * (o.score - e)
+	}
+	precision := 1/(r.Deviation*r.Deviation) + dInv
+	r.Rating += glickoQ / precision * delta
+	r.Deviation = math.Max(math.Sqrt(1/precision), minDeviation)
+	return r
+}
+
+// ratingMatch is a game between two players, score is a's
+type ratingMatch struct {
+	a, b  string
+	score float64
+}
+
+// pairwise turns a ranking into a match between every pair of players. Lower ranks win and
+// equal ranks draw
+func pairwise(ranks map[string]int) []ratingMatch {
+	var matches []ratingMatch
+	for a, rankA := range ranks {
+		for b, rankB := range ranks {
+			if a >= b {
+				continue
+			}
+			score := 0.5
+			if rankA < rankB {
+				score = 1
+			} else if rankA > rankB {
+				score = 0
+			}
+			matches = append(matches, ratingMatch{a: a, b: b, score: score})
+		}
+	}
+	return matches
+}
+
+// ratingStore is the persisted rating of every player keyed by discord user ID
+type ratingStore struct {
+	path string
+
+	lock    sync.RWMutex
+	ratings map[string]rating
+}
+
+// loadRatings reads the rating file at path. A missing file is treated as no rated players
+func loadRatings(path string) (*ratingStore, error) {
+	rs := &ratingStore{
+		path:    path,
+		ratings: make(map[string]rating),
+	}
+
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return rs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading ratings: %v", err)
+	}
+	if err := json.Unmarshal(buf, &rs.ratings); err != nil {
+		return nil, fmt.Errorf("parsing ratings: %v", err)
+	}
+	return rs, nil
+}
+
+// get returns the user's rating as of now
+func (rs *ratingStore) get(userID string, now time.Time) rating {
+	if rs == nil {
+		return newRating()
+	}
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return rs.getLocked(userID, now)
+}
+
+// getLocked must be called with the lock held
+func (rs *ratingStore) getLocked(userID string, now time.Time) rating {
+	r, ok := rs.ratings[userID]
+	if !ok {
+		return newRating()
+	}
+	return r.decayed(now)
+}
+
+// update rates everyone in the period at once so the order of the matches doesn't matter.
+// Players can also have results against fixed opponents such as puzzles
+func (rs *ratingStore) update(now time.Time, matches []ratingMatch, fixed map[string][]outcome) error {
+	if rs == nil {
+		return nil
+	}
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	outcomes := make(map[string][]outcome)
+	for userID, results := range fixed {
+		outcomes[userID] = append(outcomes[userID], results...)
+	}
+	for _, m := range matches {
+		a, b := rs.getLocked(m.a, now), rs.getLocked(m.b, now)
+		outcomes[m.a] = append(outcomes[m.a], outcome{opponent: b, score: m.score})
+		outcomes[m.b] = append(outcomes[m.b], outcome{opponent: a, score: 1 - m.score})
+	}
+
+	updated := make(map[string]rating, len(outcomes))
+	for userID, results := range outcomes {
+		r := glicko(rs.getLocked(userID, now), results)
+		r.Games++
+		r.Updated = now
+		updated[userID] = r
+	}
+	for userID, r := range updated {
+		rs.ratings[userID] = r
+	}
+	if rs.path == "" {
+		return nil
+	}
+	return writeJSONFile(rs.path, rs.ratings)
+}
+
+// puzzleScore is how a solution did against the puzzle. Optimal solutions win, one move over
+// draws and anything longer loses
+func puzzleScore(moves, optimal int) float64 {
+	switch moves - optimal {
+	case 0:
+		return 1
+	case 1:
+		return 0.5
+	default:
+		return 0
+	}
+}
+
+// rateRound updates the ratings of everyone who solved a closed round. Custom puzzles and
+// puzzles without a known optimal length aren't rated
+func (s *server) rateRound(g *game, ranked []rankedSolution, optimal int) {
+	puzzle, ok := puzzleRatings[g.difficulty]
+	if g.isCustom() || !ok || optimal == 0 || len(ranked) == 0 {
+		return
+	}
+	ranks := make(map[string]int)
+	fixed := make(map[string][]outcome)
+	for _, rs := range ranked {
+		ranks[rs.userID] = len(rs.moves)
+		fixed[rs.userID] = []outcome{{
+			opponent: rating{Rating: puzzle, Deviation: puzzleDeviation},
+			score:    puzzleScore(len(rs.moves), optimal),
+		}}
+	}
+	if err := s.ratings.update(s.clk().Now(), pairwise(ranks), fixed); err != nil {
+		log.Printf("rating round: %v", err)
+	}
+}
+
+// rateTournament updates the ratings of everyone in a finished tournament by their place
+func (s *server) rateTournament(results map[string]tournamentResult) {
+	if len(results) < 2 {
+		return
+	}
+	ranks := make(map[string]int)
+	for userID, result := range results {
+		ranks[userID] = result.Place
+	}
+	if err := s.ratings.update(s.clk().Now(), pairwise(ranks), nil); err != nil {
+		log.Printf("rating tournament: %v", err)
+	}
+}
+
+func ratingContent(r rating) string {
+	if r.Games == 0 {
+		return "Rating: unrated"
+	}
+	content := fmt.Sprintf("Rating: **%.0f** ±%.0f", r.Rating, 2*r.Deviation)
+	if r.provisional() {
+		content += " (provisional)"
+	}
+	return content
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlicko(t *testing.T) {
+	// the worked example from Glickman's description of the system
+	r := glicko(rating{Rating: 1500, Deviation: 200}, []outcome{
+		{opponent: rating{Rating: 1400, Deviation: 30}, score: 1},
+		{opponent: rating{Rating: 1550, Deviation: 100}, score: 0},
+		{opponent: rating{Rating: 1700, Deviation: 300}, score: 0},
+	})
+	if math.Abs(r.Rating-1464.1) > 0.5 || math.Abs(r.Deviation-151.4) > 0.5 {
+		t.Fatalf("got %.1f ±%.1f, expected 1464.1 ±151.4", r.Rating, r.Deviation)
+	}
+}
+
+func TestRatingDecay(t *testing.T) {
+	now := time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)
+	r := rating{Rating: 1700, Deviation: 50, Games: 20, Updated: now}
+	if d := r.decayed(now).Deviation; d != 50 {
+		t.Fatalf("deviation changed without time passing: %v", d)
+	}
+	month := r.decayed(now.AddDate(0, 1, 0))
+	if month.Deviation <= 50 || month.Deviation >= provisionalDeviation || month.Rating != 1700 {
+		t.Fatalf("unexpected rating after a month away %+v", month)
+	}
+	if d := r.decayed(now.AddDate(2, 0, 0)).Deviation; d != initialDeviation {
+		t.Fatalf("deviation after two years away is %v", d)
+	}
+}
+
+func TestRatingDifficulty(t *testing.T) {
+	for _, tt := range []struct {
+		rating float64
+		want   difficulty
+	}{
+		{1100, EASY},
+		{1500, MEDIUM},
+		{1700, HARD},
+		{2400, HARD},
+	} {
+		if got := (rating{Rating: tt.rating}).difficulty(); got != tt.want {
+			t.Errorf("rating %v picked %s, expected %s", tt.rating, got, tt.want)
+		}
+	}
+}
+
+func TestRatingStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratings.json")
+	rs, err := loadRatings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	// alice beats bob, who draws with carol
+	err = rs.update(now, pairwise(map[string]int{"alice": 1, "bob": 2, "carol": 2}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, bob, carol := rs.get("alice", now), rs.get("bob", now), rs.get("carol", now)
+	if alice.Rating <= bob.Rating || math.Abs(bob.Rating-carol.Rating) > 0.001 || bob.Rating >= initialRating {
+		t.Fatalf("unexpected ratings alice %+v bob %+v carol %+v", alice, bob, carol)
+	}
+	if alice.Games != 1 || alice.Deviation >= initialDeviation {
+		t.Fatalf("alice's rating wasn't updated %+v", alice)
+	}
+
+	reloaded, err := loadRatings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := reloaded.get("alice", now); r != alice {
+		t.Fatalf("rating wasn't saved: %+v", r)
+	}
+	if r := reloaded.get("dave", now); r != newRating() {
+		t.Fatalf("unknown player is rated %+v", r)
+	}
+}
+
+func TestRoundRatings(t *testing.T) {
+	puzzles := testPuzzles(t)
+	for _, g := range puzzles {
+		g.difficulty = MEDIUM
+		g.set = classicSet
+	}
+	s, fm := newTestServer(t, puzzles)
+	s.ratings, _ = loadRatings("")
+	h, _ := loadHistory("")
+	s.history = h
+	g := puzzles[0]
+
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "puzzle"))
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "solve", "moves", formatMoves(g.moves)))
+
+	fc := s.clock.(*fakeClock)
+	fc.waitForTimers(t, 2)
+	fc.Advance(optimalGracePeriod)
+	fm.waitFor(t, testChannel, "is closed")
+
+	alice := s.ratings.get("alice", fc.Now())
+	if alice.Games != 1 || alice.Rating <= initialRating {
+		t.Fatalf("alice wasn't rated up for an optimal solve %+v", alice)
+	}
+
+	// carol is optimal, dave is two moves over
+	moves, _ := parseMoves("RU-BL-GD-YR-RL")
+	s.rateRound(g, []rankedSolution{{userID: "carol", moves: moves}, {userID: "dave", moves: append(moves, moves[:2]...)}}, len(moves))
+	carol, dave := s.ratings.get("carol", fc.Now()), s.ratings.get("dave", fc.Now())
+	if carol.Rating <= initialRating || dave.Rating >= initialRating {
+		t.Fatalf("unexpected ratings carol %+v dave %+v", carol, dave)
+	}
+
+	// custom puzzles aren't rated
+	g.set = nil
+	s.rateRound(g, []rankedSolution{{userID: "erin", moves: moves}}, len(moves))
+	if erin := s.ratings.get("erin", fc.Now()); erin.Games != 0 {
+		t.Fatalf("custom puzzle was rated %+v", erin)
+	}
+
+	stats := command(testGuild, testChannel, "alice", "stats")
+	s.handleInteraction(fm, stats)
+	if reply := fm.reply(stats); !strings.Contains(reply, "Rating: **") || !strings.Contains(reply, "(provisional)") {
+		t.Fatalf("stats don't show the rating:\n%s", reply)
+	}
+}
@@ -12,9 +12,9 @@ import (
 
 func render(g *game) (image.Image, error) {
 
-	dst := image.NewNRGBA(image.Rect(0, 0, 16*16, 16*16))
+	dst := image.NewNRGBA(image.Rect(0, 0, g.size*16, g.height()*16))
 	// one row at a time
-	for row := 0; row < g.size; row += 1 {
+	for row := 0; row < g.height(); row += 1 {
 		for col := 0; col < g.size; col += 1 {
 			/*
 				f, err := os.Open(filepath.Join("ricochet-images", "vanilla3.png"))
@@ -42,25 +42,25 @@ func render(g *game) (image.Image, error) {
 	// draw robots
 	for _, r := range g.robots {
 		robotImg := pickRobot(*r)
-		row := r.position / 16
-		col := r.position % 16
+		row := int(r.position) / g.size
+		col := int(r.position) % g.size
 
-		x := int(col * 16)
-		y := int(row * 16)
+		x := col * 16
+		y := row * 16
 		draw.BiLinear.Scale(dst, image.Rect(x, y, x+16, y+16), robotImg, robotImg.Bounds(), draw.Over, nil)
 	}
 
 	// draw goal
 	goalImg := pickGoal(g.activeGoal)
-	row := g.activeGoal.position / 16
-	col := g.activeGoal.position % 16
+	row := int(g.activeGoal.position) / g.size
+	col := int(g.activeGoal.position) % g.size
 
-	x := int(col * 16)
-	y := int(row * 16)
+	x := col * 16
+	y := row * 16
 	draw.BiLinear.Scale(dst, image.Rect(x, y, x+16, y+16), goalImg, goalImg.Bounds(), draw.Over, nil)
 	//return dst, nil
 
-	upscaled := image.NewNRGBA(image.Rect(0, 0, 32*32, 32*32))
+	upscaled := image.NewNRGBA(image.Rect(0, 0, g.size*64, g.height()*64))
 	draw.NearestNeighbor.Scale(upscaled, upscaled.Bounds(), dst, dst.Bounds(), draw.Over, nil)
 
 	return upscaled, nil
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Arena tokens are paid out according to a reward policy loaded from rewards.json in the data
+// dir. The solve rules are multiples of the amount for the puzzle's difficulty so harder puzzles
+// always pay more
+
+// rewardPolicy is the declarative set of reward rules. Any field missing from the config file
+// keeps its default
+type rewardPolicy struct {
+	// guilds where solving puzzles earns tokens
+	Guilds []string `json:"guilds"`
+	// base tokens for each difficulty, keyed by difficulty name
+	Amounts map[string]int `json:"amounts"`
+
+	// multiples of the difficulty amount
+	Solve        int `json:"solve"`        // any accepted solve
+	FirstSolver  int `json:"firstSolver"`  // first user to solve the puzzle
+	FirstOptimal int `json:"firstOptimal"` // first user to find an optimal solution
+	Archive      int `json:"archive"`      // archived solves allowed by RICOCHET_ARCHIVE_REWARDS
+
+	// tokens for first place, second place... in a tournament
+	Tournament []int      `json:"tournament"`
+	Streak     streakRule `json:"streak"`
+	// most tokens a user can earn in a UTC day, 0 for no limit
+	DailyCap int `json:"dailyCap"`
+}
+
+// streakRule pays a bonus every time a user finds Length optimal solutions in a row
+type streakRule struct {
+	Length int `json:"length"` // 0 disables streak bonuses
+	Bonus  int `json:"bonus"`
+}
+
+func defaultRewardPolicy() *rewardPolicy {
+	return &rewardPolicy{
+		Guilds: []string{ArenaServerID},
+		Amounts: map[string]int{
+			EASY.String():    10,
+			MEDIUM.String():  15,
+			HARD.String():    20,
+			EXTREME.String(): 30,
+		},
+		FirstSolver:  1,
+		FirstOptimal: 1,
+		Archive:      1,
+		Tournament:   []int{50, 30, 20},
+	}
+}
+
+// loadRewardPolicy reads the policy at path over the defaults. A missing file uses the defaults
+func loadRewardPolicy(path string) (*rewardPolicy, error) {
+	rp := defaultRewardPolicy()
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return rp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading reward policy: %v", err)
+	}
+	if err := json.Unmarshal(buf, rp); err != nil {
+		return nil, fmt.Errorf("parsing reward policy: %v", err)
+	}
+	if err := rp.validate(); err != nil {
+		return nil, fmt.Errorf("invalid reward policy: %v", err)
+	}
+	return rp, nil
+}
+
+func (rp *rewardPolicy) validate() error {
+	for name, amount := range rp.Amounts {
+		if parseDifficulty(name) == UNKNOWN {
+			return fmt.Errorf("unknown difficulty %q", name)
+		}
+		if amount < 0 {
+			return fmt.Errorf("negative amount for %s", name)
+		}
+	}
+	if rp.Solve < 0 || rp.FirstSolver < 0 || rp.FirstOptimal < 0 || rp.Archive < 0 {
+		return fmt.Errorf("solve multiples can't be negative")
+	}
+	for _, amount := range rp.Tournament {
+		if amount < 0 {
+			return fmt.Errorf("negative tournament reward")
+		}
+	}
+	if rp.Streak.Length < 0 || rp.Streak.Bonus < 0 || rp.DailyCap < 0 {
+		return fmt.Errorf("streak and daily cap can't be negative")
+	}
+	return nil
+}
+
+// rewardPolicy returns the server's policy, falling back to the defaults
+func (s *server) rewardPolicy() *rewardPolicy {
+	if s.rewards == nil {
+		return defaultRewardPolicy()
+	}
+	return s.rewards
+}
+
+// paysIn reports whether solves in the guild earn tokens
+func (rp *rewardPolicy) paysIn(guildID string) bool {
+	return guildID != "" && containsString(rp.Guilds, guildID)
+}
+
+func (rp *rewardPolicy) amount(d difficulty) int {
+	return rp.Amounts[d.String()]
+}
+
+// award is a single rule that paid out
+type award struct {
+	rule   string
+	amount int
+	// tokens held back by the daily cap
+	capped int
+}
+
+// rewardReason collects the awards earned for one puzzle
+type rewardReason struct {
+	rewardType string // solve, archive or tournament
+	guildID    string
+	puzzleID   string
+	awards     []award
+}
+
+func newRewardReason(rewardType, guildID, puzzleID string) rewardReason {
+	return rewardReason{rewardType: rewardType, guildID: guildID, puzzleID: puzzleID}
+}
+
+func (r *rewardReason) add(rule string, amount int) {
+	if amount > 0 {
+		r.awards = append(r.awards, award{rule: rule, amount: amount})
+	}
+}
+
+func (r rewardReason) total() int {
+	total := 0
+	for _, a := range r.awards {
+		total += a.amount
+	}
+	return total
+}
+
+// capped is the tokens held back by the daily cap
+func (r rewardReason) capped() int {
+	total := 0
+	for _, a := range r.awards {
+		total += a.capped
+	}
+	return total
+}
+
+// solveFacts is what the policy needs to know about a solve of an active puzzle
+type solveFacts struct {
+	difficulty   difficulty
+	firstSolver  bool
+	firstOptimal bool
+	// optimal solutions in a row including this one, 0 if this one wasn't optimal
+	streak int
+}
+
+// solveAwards adds the awards earned by a solve to the reason
+func (rp *rewardPolicy) solveAwards(reason *rewardReason, f solveFacts) {
+	base := rp.amount(f.difficulty)
+	reason.add("solve", base*rp.Solve)
+	if f.firstSolver {
+		reason.add("first-solver", base*rp.FirstSolver)
+	}
+	if f.firstOptimal {
+		reason.add("first-optimal", base*rp.FirstOptimal)
+	}
+	if rp.Streak.Length > 0 && f.streak > 0 && f.streak%rp.Streak.Length == 0 {
+		reason.add(fmt.Sprintf("streak-%d", f.streak), rp.Streak.Bonus)
+	}
+}
+
+// applyCap trims the awards so the user doesn't earn more than the daily cap
+func (rp *rewardPolicy) applyCap(reason *rewardReason, paidToday int) {
+	if rp.DailyCap == 0 {
+		return
+	}
+	remaining := rp.DailyCap - paidToday
+	if remaining < 0 {
+		remaining = 0
+	}
+	for idx := range reason.awards {
+		a := &reason.awards[idx]
+		if a.amount > remaining {
+			a.capped = a.amount - remaining
+			a.amount = remaining
+		}
+		remaining -= a.amount
+	}
+}
+
+// startOfDay is midnight UTC on the day of t, when daily caps reset
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// capReward applies the daily cap using what the user has already been paid today
+func (s *server) capReward(userID string, reason *rewardReason) {
+	paid := s.history.rewardsSince(userID, startOfDay(s.clk().Now()))
+	s.rewardPolicy().applyCap(reason, paid)
+}
+
+// rewardEntry is a single award written to the ledger, with the reason it was paid
+type rewardEntry struct {
+	// Key identifies the award so paying it again does nothing, see rewardKey
+	Key       string    `json:"key"`
+	Source    string    `json:"source"`
+	Type      string    `json:"type"`
+	Rule      string    `json:"rule"`
+	UserID    string    `json:"userId"` // discord user
+	AccountID string    `json:"accountId"`
+	GuildID   string    `json:"guildId,omitempty"`
+	PuzzleID  string    `json:"puzzleId,omitempty"`
+	Amount    int       `json:"amount"`
+	Capped    int       `json:"capped,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// rewardKey is the idempotency key of an award. Each user can only earn each kind of reward once
+// per puzzle
+func rewardKey(puzzleID, userID, rewardType string) string {
+	return fmt.Sprintf("%s/%s/%s", puzzleID, userID, rewardType)
+}
+
+// payReward writes each of the reason's awards to the user's linked account. Awards that were
+// already paid are skipped. Returns the tokens paid, 0 if the user has no linked account
+func (s *server) payReward(userID string, reason rewardReason) (int, error) {
+	if reason.total() == 0 {
+		return 0, nil
+	}
+	// accounts linked with /link take precedence over the ledger's own
+	ledger := s.rewardLedger()
+	var accountID string
+	var err error
+	if profile, ok := s.linkedProfile(userID); ok {
+		accountID = profile.AccountID
+	} else {
+		accountID, err = ledger.LinkedAccount(userID)
+	}
+	if err != nil || accountID == "" {
+		log.Printf("Solver does not have a linked arena account")
+		return 0, nil
+	}
+
+	paid := 0
+	for _, a := range reason.awards {
+		if a.amount == 0 {
+			continue
+		}
+		rewardType := reason.rewardType + ":" + a.rule
+		added, err := ledger.AddReward(rewardEntry{
+			Key:       rewardKey(reason.puzzleID, userID, rewardType),
+			Source:    "ricochet-robotbot",
+			Type:      reason.rewardType,
+			Rule:      a.rule,
+			UserID:    userID,
+			AccountID: accountID,
+			GuildID:   reason.guildID,
+			PuzzleID:  reason.puzzleID,
+			Amount:    a.amount,
+			Capped:    a.capped,
+			Timestamp: s.clk().Now(),
+		})
+		if err != nil {
+			return paid, fmt.Errorf("adding reward to ledger: %v", err)
+		}
+		if added {
+			paid += a.amount
+		}
+	}
+	return paid, nil
+}
+
+// optimalStreak counts the user's optimal solves of active puzzles in the guild in a row, most
+// recent first
+func optimalStreak(subs []submission, guildID string) int {
+	streak := 0
+	for idx := len(subs) - 1; idx >= 0; idx-- {
+		sub := subs[idx]
+		if sub.GuildID != guildID || sub.Archived {
+			continue
+		}
+		if sub.excess() != 0 {
+			break
+		}
+		streak++
+	}
+	return streak
+}
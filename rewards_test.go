@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadRewardPolicy(t *testing.T) {
+	dir := t.TempDir()
+	rp, err := loadRewardPolicy(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rp, defaultRewardPolicy()) {
+		t.Fatalf("missing policy didn't use the defaults: %+v", rp)
+	}
+
+	path := filepath.Join(dir, "rewards.json")
+	os.WriteFile(path, []byte(`{"guilds": ["dev"], "amounts": {"hard": 50}, "streak": {"length": 3, "bonus": 25}, "dailyCap": 100}`), 0644)
+	rp, err = loadRewardPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rp.paysIn("dev") || rp.paysIn(ArenaServerID) {
+		t.Fatalf("guilds weren't replaced: %v", rp.Guilds)
+	}
+	if rp.amount(HARD) != 50 || rp.amount(EASY) != 10 {
+		t.Fatalf("amounts weren't merged with the defaults: %v", rp.Amounts)
+	}
+	if rp.Streak.Length != 3 || rp.DailyCap != 100 || rp.FirstSolver != 1 {
+		t.Fatalf("unexpected policy %+v", rp)
+	}
+
+	for _, invalid := range []string{`{"amounts": {"impossible": 5}}`, `{"firstOptimal": -1}`, `{"tournament": [10, -5]}`} {
+		os.WriteFile(path, []byte(invalid), 0644)
+		if _, err := loadRewardPolicy(path); err == nil {
+			t.Fatalf("accepted invalid policy %s", invalid)
+		}
+	}
+}
+
+func TestSolveAwards(t *testing.T) {
+	rp := defaultRewardPolicy()
+	rp.Streak = streakRule{Length: 3, Bonus: 25}
+
+	tests := []struct {
+		facts solveFacts
+		want  []award
+	}{
+		{solveFacts{difficulty: MEDIUM}, nil},
+		{solveFacts{difficulty: MEDIUM, firstSolver: true}, []award{{rule: "first-solver", amount: 15}}},
+		{solveFacts{difficulty: HARD, firstSolver: true, firstOptimal: true, streak: 1}, []award{{rule: "first-solver", amount: 20}, {rule: "first-optimal", amount: 20}}},
+		{solveFacts{difficulty: EASY, streak: 3}, []award{{rule: "streak-3", amount: 25}}},
+		{solveFacts{difficulty: EASY, streak: 4}, nil},
+	}
+	for _, tt := range tests {
+		reason := newRewardReason("solve", "guild", "puzzle")
+		rp.solveAwards(&reason, tt.facts)
+		if !reflect.DeepEqual(reason.awards, tt.want) {
+			t.Fatalf("%+v earned %v, expected %v", tt.facts, reason.awards, tt.want)
+		}
+	}
+}
+
+func TestApplyCap(t *testing.T) {
+	rp := defaultRewardPolicy()
+	rp.DailyCap = 50
+
+	reason := newRewardReason("solve", "guild", "puzzle")
+	reason.add("first-solver", 30)
+	reason.add("first-optimal", 30)
+	rp.applyCap(&reason, 10)
+	if reason.total() != 40 || reason.capped() != 20 {
+		t.Fatalf("expected 40 paid and 20 capped, got %d and %d", reason.total(), reason.capped())
+	}
+
+	reason = newRewardReason("solve", "guild", "puzzle")
+	reason.add("first-solver", 30)
+	rp.applyCap(&reason, 60)
+	if reason.total() != 0 || reason.capped() != 30 {
+		t.Fatalf("paid %v over the cap", reason.awards)
+	}
+}
+
+func TestOptimalStreak(t *testing.T) {
+	subs := []submission{
+		{GuildID: "guild", NumMoves: 9, Optimal: 8},
+		{GuildID: "guild", NumMoves: 8, Optimal: 8},
+		{GuildID: "other", NumMoves: 12, Optimal: 8},
+		{GuildID: "guild", NumMoves: 12, Optimal: 8, Archived: true},
+		{GuildID: "guild", NumMoves: 10, Optimal: 10},
+	}
+	if streak := optimalStreak(subs, "guild"); streak != 2 {
+		t.Fatalf("expected a streak of 2, got %d", streak)
+	}
+	if streak := optimalStreak(subs, "other"); streak != 0 {
+		t.Fatalf("expected no streak, got %d", streak)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// puzzleCloseTimeout is how long a puzzle stays open if no one finds the optimal solution,
+// guilds with a longer lockout keep puzzles open longer, see guildConfig.closeTimeout
+var puzzleCloseTimeout = time.Minute * 10
+
+// optimalGracePeriod gives other players a chance to submit after the optimal solution is found
+var optimalGracePeriod = time.Second * 30
+
+// puzzleRound closes a puzzle posted with /puzzle or /create and posts a recap
+type puzzleRound struct {
+	g *game
+
+	optimal     chan struct{}
+	optimalOnce sync.Once
+	closeOnce   sync.Once
+}
+
+func newPuzzleRound(g *game) *puzzleRound {
+	return &puzzleRound{
+		g:       g,
+		optimal: make(chan struct{}),
+	}
+}
+
+// optimalFound starts the grace period before the round closes
+func (pr *puzzleRound) optimalFound() {
+	pr.optimalOnce.Do(func() {
+		close(pr.optimal)
+	})
+}
+
+// startRound makes g the active puzzle and closes it once time runs out or shortly after the
+// optimal solution is found. Any round still open for the previous puzzle is closed first
+func (s *server) startRound(dg messenger, instance *discordInstance, g *game) {
+	s.closeActiveRound(dg, instance)
+	pr := newPuzzleRound(g)
+	instance.update(func() { instance.activeRound = pr })
+	instance.showPuzzle(g)
+
+	go func() {
+		select {
+		case <-instance.clk().After(instance.settings().closeTimeout()):
+		case <-pr.optimal:
+			instance.clk().Sleep(optimalGracePeriod)
+		}
+
+		// let the bidders finish demonstrating before closing
+		for {
+			b := instance.currentBidding()
+			if b == nil || b.g != g || b.finished() {
+				break
+			}
+			instance.clk().Sleep(time.Second * 5)
+		}
+		s.closeRound(dg, instance, pr)
+	}()
+}
+
+// closeActiveRound closes the open /puzzle or /create round, if any, before another mode takes
+// over the channel
+func (s *server) closeActiveRound(dg messenger, instance *discordInstance) {
+	if pr := instance.currentRound(); pr != nil {
+		s.closeRound(dg, instance, pr)
+	}
+}
+
+// solvedActiveRound starts the grace period if moves is an optimal solution to the open round
+func (instance *discordInstance) solvedActiveRound(g *game, moves []move) {
+	if pr := instance.currentRound(); pr != nil && pr.g == g && len(moves) == g.lenOptimalSolution {
+		pr.optimalFound()
+	}
+}
+
+// closeRound ends the puzzle and posts the recap. Safe to call more than once
+func (s *server) closeRound(dg messenger, instance *discordInstance, pr *puzzleRound) {
+	pr.closeOnce.Do(func() {
+		instance.update(func() {
+			if instance.activeRound == pr {
+				instance.activeRound = nil
+			}
+		})
+		instance.clearGame(pr.g)
+
+		optimal := optimalMoves(pr.g)
+		ranked := instance.getSolutions(pr.g.id).ranked()
+		s.rateRound(pr.g, ranked, len(optimal))
+		msg := &discordgo.MessageSend{
+			Content: recapContent(pr.g, ranked, optimal, s.playerName),
+		}
+		if len(optimal) > 0 {
+			gif, err := renderGif(pr.g, optimal)
+			if err != nil {
+				log.Printf("rendering recap gif: %v", err)
+			} else {
+				msg.Files = []*discordgo.File{{
+					Name:        "solution.gif",
+					ContentType: "image/gif",
+					Reader:      &gif,
+				}}
+			}
+		}
+		if _, err := dg.ChannelMessageSendComplex(instance.channelID, msg); err != nil {
+			log.Printf("posting recap: %v", err)
+		}
+	})
+}
+
+// optimalMoves returns the solution found when the puzzle was generated, solving it again if
+// it wasn't kept
+func optimalMoves(g *game) []move {
+	if len(g.moves) > 0 && len(g.moves) == g.lenOptimalSolution {
+		return g.moves
+	}
+	cpy := g.clone()
+	cpy.activeRobot = cpy.robots[cpy.activeGoal.id]
+	cpy.precomputedMoves = cpy.preCompute(cpy.activeGoal.position)
+	moves, err := parseMoves(cpy.solve(maxArchiveSolveDepth))
+	if err != nil {
+		return nil
+	}
+	return moves
+}
+
+func recapContent(g *game, ranked []rankedSolution, optimal []move, name nameFunc) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Puzzle #%s is closed**\n", g.id))
+	if len(optimal) > 0 {
+		sb.WriteString(fmt.Sprintf("Optimal solution: **%s** (%d moves)\n", formatMoves(optimal), len(optimal)))
+	}
+	if len(ranked) == 0 {
+		sb.WriteString("No one solved it :cry:")
+		return sb.String()
+	}
+
+	position := 0
+	bestScore := -1
+	for _, rs := range ranked {
+		if len(rs.moves) != bestScore {
+			position += 1
+			bestScore = len(rs.moves)
+		}
+		var prefix string
+		switch position {
+		case 1:
+			prefix = ":first_place:"
+		case 2:
+			prefix = ":second_place:"
+		case 3:
+			prefix = ":third_place:"
+		default:
+			prefix = fmt.Sprintf("%d ", position)
+		}
+		sb.WriteString(fmt.Sprintf("%s| %s **%d moves**", prefix, name(rs.userID), len(rs.moves)))
+		if len(rs.moves) == len(optimal) {
+			sb.WriteString(" :tada:")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
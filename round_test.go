@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecap(t *testing.T) {
+	g, err := parseBoard(raceBoard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.id = "test"
+	optimal := optimalMoves(&g)
+	if len(optimal) == 0 || !validate(&g, g.board, optimal, g.activeGoal) {
+		t.Fatalf("expected optimal moves to solve the puzzle")
+	}
+	g.lenOptimalSolution = len(optimal)
+	g.moves = optimal
+
+	st := &solutionTracker{}
+	st.set("late", optimal)
+	st.submittedAt["late"] = time.Now().Add(time.Minute)
+	st.set("early", optimal)
+	st.submittedAt["early"] = time.Now()
+	st.set("long", append(append([]move{}, optimal...), optimal[0]))
+
+	ranked := st.ranked()
+	if len(ranked) != 3 || ranked[0].userID != "early" || ranked[1].userID != "late" || ranked[2].userID != "long" {
+		t.Fatalf("unexpected ranking: %+v", ranked)
+	}
+
+	recap := recapContent(&g, ranked, optimalMoves(&g), mention)
+	for _, want := range []string{
+		"Puzzle #test is closed",
+		formatMoves(optimal),
+		":first_place:| <@early>",
+		":first_place:| <@late>",
+		":second_place:| <@long>",
+	} {
+		if !strings.Contains(recap, want) {
+			t.Fatalf("recap missing %q:\n%s", want, recap)
+		}
+	}
+
+	if recap := recapContent(&g, nil, optimal, mention); !strings.Contains(recap, "No one solved it") {
+		t.Fatalf("unexpected empty recap:\n%s", recap)
+	}
+}
+
+func TestLockoutLongerThanRound(t *testing.T) {
+	puzzles := testPuzzles(t)
+	s, fm := newTestServer(t, puzzles)
+	fc := s.clock.(*fakeClock)
+	config := defaultGuildConfig()
+	config.LockoutMinutes = 30
+	s.setPrimaryChannel("slow-guild", "slow", config)
+
+	s.handleInteraction(fm, command("slow-guild", "slow", "alice", "puzzle"))
+	fm.waitFor(t, "slow", "#__"+puzzles[0].id+"__")
+	fc.waitForTimers(t, 1)
+
+	// the round outlasts the default close timeout so the lockout still applies
+	fc.Advance(puzzleCloseTimeout)
+	fc.lock.Lock()
+	waiting := len(fc.timers)
+	fc.lock.Unlock()
+	if waiting != 1 {
+		t.Fatalf("round closed before the lockout ended")
+	}
+	blocked := command("slow-guild", "slow", "bob", "puzzle")
+	s.handleInteraction(fm, blocked)
+	if reply := fm.reply(blocked); !strings.Contains(reply, "30 minutes have passed") {
+		t.Fatalf("expected lockout, got %q", reply)
+	}
+
+	fc.Advance(config.lockout() - puzzleCloseTimeout)
+	fm.waitFor(t, "slow", "Puzzle #"+puzzles[0].id+" is closed")
+	again := command("slow-guild", "slow", "bob", "puzzle")
+	s.handleInteraction(fm, again)
+	if reply := fm.reply(again); reply != "Puzzle created successfully" {
+		t.Fatalf("unexpected reply after the lockout %q", reply)
+	}
+}
@@ -1,15 +1,16 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 const DiscordApplicationID = "1044049636106706974" // PROD
@@ -17,26 +18,80 @@ const DiscordApplicationID = "1044049636106706974" // PROD
 const gameBuffer = 5
 
 type server struct {
-	categorizer *categorizer
-	isSearching bool
-	instances   map[string]*discordInstance
-	db          *pgxpool.Pool
+	categorizers    map[string]*categorizer
+	categorizerLock sync.Mutex
+	// started by the first wakeGenerator call, see categorizer.go
+	generatorOnce sync.Once
+	generatorWake chan struct{}
+
+	instancesLock sync.RWMutex
+	instances     map[string]*discordInstance // keyed by channel ID
+	// guild ID -> channel used for commands outside of a game channel
+	primaryChannels map[string]string
+	ledger          RewardLedger
+	rewards         *rewardPolicy
+	identities      identityStore
+	tournamentLog   *tournamentLog
+	attempts        *attemptLog
+	achievements    []achievementRule
+	unlocks         *unlockStore
+	ratings         *ratingStore
+	linker          *linkExchanger // nil if account linking isn't configured
+
+	clock          clock // nil means the real clock, see clock.go
+	history        *history
+	guildConfigs   *guildConfigs
+	optimal        optimalCache
+	archiveRewards archiveRewardRule
+	// slots for scoring old puzzles, see acquireArchiveSolve
+	archiveSolves     chan struct{}
+	archiveSolvesOnce sync.Once
+	// slots for solving custom boards, see acquireCustomSolve
+	customSolves     chan struct{}
+	customSolvesOnce sync.Once
 }
 
 type discordInstance struct {
-	serverID         string
+	// guards the fields describing what is being played, see state.go
+	lock sync.Mutex
+
+	serverID         string // empty for DM practice instances
 	channelID        string
 	puzzleIdx        int
 	activeGame       *game
 	activeTournament *tournament
+	activeRace       *race
+	bidding          *bidding
+	activeSpeed      *speedRound
+	activeRound      *puzzleRound
+	practice         bool // private DM channel, see practice.go
+	starting         bool // a command is generating the next puzzle
+	clock            clock
 
 	puzzleTimestamp time.Time
 
+	// settings changed with /config, persisted per guild
+	config guildConfig
+
 	// TODO: this grows unbounded. Need to remove entries at some point
 	solutions    map[string]*solutionTracker
 	solutionLock sync.RWMutex
 }
 
+// enabledSets returns the board sets this guild plays with
+func (di *discordInstance) enabledSets() []*boardSet {
+	var sets []*boardSet
+	for _, name := range di.settings().BoardSets {
+		if bs := boardSetByName(name); bs != nil {
+			sets = append(sets, bs)
+		}
+	}
+	if len(sets) == 0 {
+		sets = append(sets, classicSet)
+	}
+	return sets
+}
+
 func (di *discordInstance) getSolutions(id string) *solutionTracker {
 	di.solutionLock.Lock()
 	defer di.solutionLock.Unlock()
@@ -46,7 +101,7 @@ func (di *discordInstance) getSolutions(id string) *solutionTracker {
 
 	tracker := di.solutions[id]
 	if tracker == nil {
-		di.solutions[id] = &solutionTracker{}
+		di.solutions[id] = &solutionTracker{clock: di.clock}
 	}
 
 	return di.solutions[id]
@@ -55,6 +110,8 @@ func (di *discordInstance) getSolutions(id string) *solutionTracker {
 type solutionTracker struct {
 	lock               sync.Mutex
 	submittedSolutions map[string][]move
+	submittedAt        map[string]time.Time
+	clock              clock
 }
 
 func (st *solutionTracker) set(key string, moves []move) {
@@ -62,8 +119,34 @@ func (st *solutionTracker) set(key string, moves []move) {
 	defer st.lock.Unlock()
 	if st.submittedSolutions == nil {
 		st.submittedSolutions = make(map[string][]move)
+		st.submittedAt = make(map[string]time.Time)
 	}
 	st.submittedSolutions[key] = moves
+	st.submittedAt[key] = clockOrReal(st.clock).Now()
+}
+
+type rankedSolution struct {
+	userID string
+	moves  []move
+	at     time.Time
+}
+
+// ranked returns every user's best solution, shortest first. Ties go to whoever submitted first
+func (st *solutionTracker) ranked() []rankedSolution {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	var ranked []rankedSolution
+	for userID, moves := range st.submittedSolutions {
+		ranked = append(ranked, rankedSolution{userID: userID, moves: moves, at: st.submittedAt[userID]})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if len(ranked[i].moves) != len(ranked[j].moves) {
+			return len(ranked[i].moves) < len(ranked[j].moves)
+		}
+		return ranked[i].at.Before(ranked[j].at)
+	})
+	return ranked
 }
 
 func (st *solutionTracker) get(key string) []move {
@@ -108,6 +191,7 @@ func (st *solutionTracker) numSubmitted() int {
 
 func (s *server) run() {
 	s.instances = make(map[string]*discordInstance)
+	s.primaryChannels = make(map[string]string)
 
 	discordToken := os.Getenv("RICOCHET_DISCORD_TOKEN") // PROD
 	//discordToken := os.Getenv("RICOCHET_DEV_DISCORD_TOKEN") //dev
@@ -120,25 +204,106 @@ func (s *server) run() {
 		log.Fatalf("failed to authenticate with discord: %v", err)
 	}
 
-	// connect to db
-	dbURL := os.Getenv("DATABASE_URL")
-	conn, err := pgxpool.Connect(context.Background(), dbURL)
+	boardsDir := os.Getenv("RICOCHET_BOARDS_DIR")
+	if boardsDir == "" {
+		boardsDir = "boards"
+	}
+	if err := loadBoards(boardsDir); err != nil {
+		log.Fatalf("loading board sets: %v", err)
+	}
+
+	// load persisted player data
+	dataDir := os.Getenv("RICOCHET_DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
+	// rewards only need the arena db when it is configured
+	ledger, closeLedger, err := openRewardLedger(os.Getenv("RICOCHET_LEDGER"), os.Getenv("DATABASE_URL"), filepath.Join(dataDir, "ledger.json"))
+	if err != nil {
+		log.Fatalf("opening reward ledger: %v", err)
+	}
+	defer closeLedger()
+	s.ledger = ledger
+	s.history, err = loadHistory(filepath.Join(dataDir, "history.json"))
+	if err != nil {
+		log.Fatalf("loading history: %v", err)
+	}
+	s.guildConfigs, err = loadGuildConfigs(filepath.Join(dataDir, "guilds.json"))
+	if err != nil {
+		log.Fatalf("loading guild configs: %v", err)
+	}
+
+	s.tournamentLog, err = loadTournamentLog(filepath.Join(dataDir, "tournaments.json"))
+	if err != nil {
+		log.Fatalf("loading tournament results: %v", err)
+	}
+	s.attempts, err = loadAttempts(filepath.Join(dataDir, "attempts.json"))
+	if err != nil {
+		log.Fatalf("loading attempts: %v", err)
+	}
+	s.unlocks, err = loadUnlocks(filepath.Join(dataDir, "unlocks.json"))
+	if err != nil {
+		log.Fatalf("loading achievements: %v", err)
+	}
+	s.ratings, err = loadRatings(filepath.Join(dataDir, "ratings.json"))
+	if err != nil {
+		log.Fatalf("loading ratings: %v", err)
+	}
+	s.identities, err = loadIdentities(filepath.Join(dataDir, "identities.json"))
+	if err != nil {
+		log.Fatalf("loading identities: %v", err)
+	}
+	if linkURL := os.Getenv("RICOCHET_LINK_URL"); linkURL != "" {
+		s.linker = newLinkExchanger(linkURL)
+	}
+
+	rewardsPath := os.Getenv("RICOCHET_REWARDS")
+	if rewardsPath == "" {
+		rewardsPath = filepath.Join(dataDir, "rewards.json")
+	}
+	s.rewards, err = loadRewardPolicy(rewardsPath)
+	if err != nil {
+		log.Fatalf("loading reward policy: %v", err)
+	}
+
+	achievementsPath := os.Getenv("RICOCHET_ACHIEVEMENTS")
+	if achievementsPath == "" {
+		achievementsPath = filepath.Join(dataDir, "achievements.json")
+	}
+	s.achievements, err = loadAchievementRules(achievementsPath)
+	if err != nil {
+		log.Fatalf("loading achievement rules: %v", err)
+	}
+
+	s.archiveRewards, err = parseArchiveRewardRule(os.Getenv("RICOCHET_ARCHIVE_REWARDS"))
 	if err != nil {
-		log.Fatalf("unable to connect to db: %v", err)
+		log.Fatalf("invalid RICOCHET_ARCHIVE_REWARDS: %v", err)
 	}
-	defer conn.Close()
-	s.db = conn
 
 	// Handler that will register all known slash commands whenever the bot is invited
 	// to a new guild or restarted.
 	dg.AddHandler(func(dg *discordgo.Session, gc *discordgo.GuildCreate) {
 		log.Println("Invited to guild:", gc.Name)
 
-		// look for a ricochet channel
-		channel, err := findChannel(dg, gc.ID)
-		if err != nil {
-			log.Printf("unable to find channels: %v", err)
-			return
+		config := s.guildConfigs.get(gc.ID)
+
+		// use the configured channel if it still exists, otherwise look for a ricochet channel
+		var channel *discordgo.Channel
+		var err error
+		if config.ChannelID != "" {
+			channel, err = dg.Channel(config.ChannelID)
+			if err != nil {
+				log.Printf("configured channel %s unavailable: %v", config.ChannelID, err)
+				channel = nil
+			}
+		}
+		if channel == nil {
+			channel, err = findChannel(dg, gc.ID)
+			if err != nil {
+				log.Printf("unable to find channels: %v", err)
+				return
+			}
 		}
 
 		// TODO: Create if not exists
@@ -175,9 +340,13 @@ func (s *server) run() {
 			return
 		}
 
-		s.instances[gc.Guild.ID] = &discordInstance{
-			serverID:  gc.Guild.ID,
-			channelID: channel.ID,
+		s.setPrimaryChannel(gc.Guild.ID, channel.ID, config)
+		for _, channelID := range config.Channels {
+			if _, err := dg.Channel(channelID); err != nil {
+				log.Printf("game channel %s unavailable: %v", channelID, err)
+				continue
+			}
+			s.addGameChannel(gc.Guild.ID, channelID, config)
 		}
 
 		//		var sb strings.Builder
@@ -191,64 +360,19 @@ func (s *server) run() {
 
 	// top level handler for slash commands, user commands, and continued interactions
 	dg.AddHandler(func(dg *discordgo.Session, i *discordgo.InteractionCreate) {
-		switch i.Type {
-		case discordgo.InteractionMessageComponent:
-			fmt.Println("Interaction Continue")
-			//handleInteractionContinue(dg, i)
-		case discordgo.InteractionApplicationCommand:
-			switch i.ApplicationCommandData().Name {
-			case "puzzle":
-				err := s.handlePuzzle(dg, i)
-				if err != nil {
-					log.Printf("puzzle handler: %v", err)
-				}
-			case "solve":
-				err := s.handleSolve(dg, i)
-				if err != nil {
-					log.Printf("solve handler: %v", err)
-				}
-			case "help":
-				err := s.handleHelp(dg, i)
-				if err != nil {
-					log.Printf("help handler: %v", err)
-				}
-			case "share":
-				err := s.handleShare(dg, i)
-				if err != nil {
-					log.Printf("share handler: %v", err)
-				}
-			case "how-to-play":
-				err := s.handleHowToPlay(dg, i)
-				if err != nil {
-					log.Printf("how-to-play handler: %v", err)
-				}
-			case "tournament":
-				err := s.handleTournament(dg, i)
-				if err != nil {
-					log.Printf("tournament handler: %v", err)
-				}
-			default:
-				log.Println("Unknown Command:", i.ApplicationCommandData().Name)
-			}
-		case discordgo.InteractionModalSubmit:
-			fmt.Println("modal")
-		}
-
+		s.handleInteraction(dg, i)
 	})
 
 	if err := dg.Open(); err != nil {
 		log.Fatalf("opening discord connection: %v\n", err)
 	}
-
-	cat := categorizer{
-		easy:    make(chan (*game), gameBuffer),
-		medium:  make(chan (*game), gameBuffer),
-		hard:    make(chan (*game), gameBuffer),
-		extreme: make(chan (*game), gameBuffer),
+	if err := registerGlobalCommands(dg); err != nil {
+		log.Printf("Unable to update global commands: %v\n", err)
 	}
-	s.categorizer = &cat
 
-	lookForSolutions(s)
+	s.ensureCategorizer(classicSet)
+	s.wakeGenerator()
+	go s.reconcileLoop()
 
 	fmt.Println("infinite loop")
 	for {
@@ -259,17 +383,155 @@ func (s *server) run() {
 	// listen for discord events
 }
 
-func (s *server) servePuzzle(difficulty string) *game {
-	var g *game
-	switch difficulty {
-	case "easy":
-		g = <-s.categorizer.easy
-	case "medium":
-		g = <-s.categorizer.medium
-	case "hard":
-		g = <-s.categorizer.hard
-	case "extreme":
-		g = <-s.categorizer.extreme
+// handleInteraction routes slash commands, user commands, and continued interactions to
+// their handlers
+func (s *server) handleInteraction(dg messenger, i *discordgo.InteractionCreate) {
+	switch i.Type {
+	case discordgo.InteractionMessageComponent:
+		fmt.Println("Interaction Continue")
+		//handleInteractionContinue(dg, i)
+	case discordgo.InteractionApplicationCommand:
+		if i.GuildID == "" && !practiceCommands[i.ApplicationCommandData().Name] {
+			log.Println("Command unavailable in DMs:", i.ApplicationCommandData().Name)
+			return
+		}
+		switch i.ApplicationCommandData().Name {
+		case "puzzle":
+			err := s.handlePuzzle(dg, i)
+			if err != nil {
+				log.Printf("puzzle handler: %v", err)
+			}
+		case "solve":
+			err := s.handleSolve(dg, i)
+			if err != nil {
+				log.Printf("solve handler: %v", err)
+			}
+		case "help":
+			err := s.handleHelp(dg, i)
+			if err != nil {
+				log.Printf("help handler: %v", err)
+			}
+		case "share":
+			err := s.handleShare(dg, i)
+			if err != nil {
+				log.Printf("share handler: %v", err)
+			}
+		case "how-to-play":
+			err := s.handleHowToPlay(dg, i)
+			if err != nil {
+				log.Printf("how-to-play handler: %v", err)
+			}
+		case "tournament":
+			err := s.handleTournament(dg, i)
+			if err != nil {
+				log.Printf("tournament handler: %v", err)
+			}
+		case "boards":
+			err := s.handleBoards(dg, i)
+			if err != nil {
+				log.Printf("boards handler: %v", err)
+			}
+		case "race":
+			err := s.handleRace(dg, i)
+			if err != nil {
+				log.Printf("race handler: %v", err)
+			}
+		case "bid":
+			err := s.handleBid(dg, i)
+			if err != nil {
+				log.Printf("bid handler: %v", err)
+			}
+		case "speed":
+			err := s.handleSpeed(dg, i)
+			if err != nil {
+				log.Printf("speed handler: %v", err)
+			}
+		case "create":
+			err := s.handleCreate(dg, i)
+			if err != nil {
+				log.Printf("create handler: %v", err)
+			}
+		case "config":
+			err := s.handleConfig(dg, i)
+			if err != nil {
+				log.Printf("config handler: %v", err)
+			}
+		case "rewards":
+			err := s.handleRewards(dg, i)
+			if err != nil {
+				log.Printf("rewards handler: %v", err)
+			}
+		case "stats":
+			err := s.handleStats(dg, i)
+			if err != nil {
+				log.Printf("stats handler: %v", err)
+			}
+		case "link":
+			err := s.handleLink(dg, i)
+			if err != nil {
+				log.Printf("link handler: %v", err)
+			}
+		case "unlink":
+			err := s.handleUnlink(dg, i)
+			if err != nil {
+				log.Printf("unlink handler: %v", err)
+			}
+		default:
+			log.Println("Unknown Command:", i.ApplicationCommandData().Name)
+		}
+	case discordgo.InteractionModalSubmit:
+		fmt.Println("modal")
+	}
+}
+
+// servePuzzle pulls a pre-solved puzzle of the requested difficulty from one of the board sets
+// enabled for the instance, falling back to the classic board if none of them support it
+func (s *server) servePuzzle(instance *discordInstance, diff difficulty) *game {
+	var candidates []*boardSet
+	for _, bs := range instance.enabledSets() {
+		if bs.supports(diff) {
+			candidates = append(candidates, bs)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, classicSet)
 	}
+	set := candidates[rand.Intn(len(candidates))]
+
+	c := s.ensureCategorizer(set)
+	s.wakeGenerator()
+
+	g := <-c.bank(diff)
+	g.difficulty = diff
 	return g
 }
+
+// ensureCategorizer returns the puzzle buffers for a board set, creating them if this is the
+// first time the set has been enabled
+func (s *server) ensureCategorizer(set *boardSet) *categorizer {
+	s.categorizerLock.Lock()
+	defer s.categorizerLock.Unlock()
+	if s.categorizers == nil {
+		s.categorizers = make(map[string]*categorizer)
+	}
+	c, ok := s.categorizers[set.name]
+	if !ok {
+		c = newCategorizer(set)
+		s.categorizers[set.name] = c
+	}
+	return c
+}
+
+// activeCategorizers returns the puzzle buffers of every enabled board set, classic first
+func (s *server) activeCategorizers() []*categorizer {
+	s.categorizerLock.Lock()
+	defer s.categorizerLock.Unlock()
+
+	var cats []*categorizer
+	for _, bs := range boardSets {
+		if c, ok := s.categorizers[bs.name]; ok {
+			cats = append(cats, c)
+		}
+	}
+	return cats
+}
@@ -1,22 +1,321 @@
 package main
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
 
-func TestServer(t *testing.T) {
+const (
+	testGuild   = "guild"
+	testChannel = "ricochet"
+)
 
-	s := &server{}
-	s.run()
+// testPuzzles returns a solved puzzle for each goal on the race board
+func testPuzzles(t *testing.T) []*game {
+	t.Helper()
+	board, err := parseBoard(raceBoard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var puzzles []*game
+	for idx, goal := range board.goals {
+		g := board.clone()
+		g.activeGoal = goal
+		g.activeRobot = g.robots[goal.id]
+		g.precomputedMoves = g.preCompute(goal.position)
+		g.moves = optimalMoves(&g)
+		if len(g.moves) == 0 {
+			t.Fatalf("race board goal %c has no solution", goal.id)
+		}
+		g.lenOptimalSolution = len(g.moves)
+		g.id = fmt.Sprintf("test%d", idx)
+		puzzles = append(puzzles, &g)
+	}
+	return puzzles
+}
+
+// newTestServer returns a server with one guild whose medium puzzle buffer holds puzzles. The
+// server runs on a fake clock
+func newTestServer(t *testing.T, puzzles []*game) (*server, *fakeMessenger) {
+	s := &server{
+		instances:       make(map[string]*discordInstance),
+		primaryChannels: make(map[string]string),
+		clock:           newFakeClock(),
+	}
+	// keep the generator from starting, the buffer is stocked by hand
+	s.generatorOnce.Do(func() { s.generatorWake = make(chan struct{}, 1) })
+	c := s.ensureCategorizer(classicSet)
+	for _, g := range puzzles {
+		c.bank(MEDIUM) <- g
+	}
+	s.setPrimaryChannel(testGuild, testChannel, defaultGuildConfig())
+	return s, newFakeMessenger()
+}
+
+func TestPuzzleCommand(t *testing.T) {
+	puzzles := testPuzzles(t)
+	s, fm := newTestServer(t, puzzles)
+
+	i := command(testGuild, testChannel, "alice", "puzzle")
+	s.handleInteraction(fm, i)
+	if reply := fm.reply(i); reply != "Puzzle created successfully" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	msg := fm.waitFor(t, testChannel, "#__"+puzzles[0].id+"__")
+	if len(msg.files) != 1 || msg.files[0] != "board.png" {
+		t.Fatalf("puzzle posted without the board: %+v", msg)
+	}
+
+	// the puzzle hasn't been solved so it can't be replaced yet
+	again := command(testGuild, testChannel, "bob", "puzzle")
+	s.handleInteraction(fm, again)
+	if reply := fm.reply(again); !strings.Contains(reply, "must be solved optimally") {
+		t.Fatalf("expected lockout, got %q", reply)
+	}
+}
+
+func TestSolveCommand(t *testing.T) {
+	puzzles := testPuzzles(t)
+	s, fm := newTestServer(t, puzzles)
+	fc := s.clock.(*fakeClock)
+	g := puzzles[0]
+
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "puzzle"))
+
+	wrong := command(testGuild, testChannel, "bob", "solve", "moves", "RU")
+	s.handleInteraction(fm, wrong)
+	if reply := fm.reply(wrong); !strings.HasPrefix(reply, ":x:") {
+		t.Fatalf("invalid solution accepted: %q", reply)
+	}
+
+	solve := command(testGuild, testChannel, "alice", "solve", "moves", formatMoves(g.moves))
+	s.handleInteraction(fm, solve)
+	if reply := fm.reply(solve); !strings.HasPrefix(reply, ":white_check_mark:") {
+		t.Fatalf("optimal solution rejected: %q", reply)
+	}
+	fm.waitFor(t, testChannel, "<@alice> solved with an :tada:**optimal**")
+
+	// finding the optimal solution closes the puzzle after the grace period. The round waits on
+	// both the close timeout and the grace period
+	fc.waitForTimers(t, 2)
+	if _, ok := fm.find(testChannel, "is closed"); ok {
+		t.Fatalf("puzzle closed before the grace period ended")
+	}
+	fc.Advance(optimalGracePeriod)
+	recap := fm.waitFor(t, testChannel, "Puzzle #"+g.id+" is closed")
+	if !strings.Contains(recap.content, ":first_place:| <@alice>") {
+		t.Fatalf("recap missing the winner: %s", recap.content)
+	}
+}
+
+func TestQuietSolves(t *testing.T) {
+	puzzles := testPuzzles(t)
+	for _, g := range puzzles {
+		g.set = classicSet
+	}
+	s, fm := newTestServer(t, puzzles)
+	ml, _ := loadMemoryLedger("")
+	s.ledger = ml
+	s.history, _ = loadHistory("")
+	s.unlocks, _ = loadUnlocks("")
+	s.archiveRewards = ARCHIVE_REWARD_ALL
+	config := defaultGuildConfig()
+	config.AnnounceSolves = false
+	s.setPrimaryChannel(ArenaServerID, "arena", config)
+
+	// arena solves still pay out but only the solver hears about it
+	s.handleInteraction(fm, command(ArenaServerID, "arena", "alice", "puzzle"))
+	solve := command(ArenaServerID, "arena", "alice", "solve", "moves", formatMoves(puzzles[0].moves))
+	s.handleInteraction(fm, solve)
+	if reply := fm.reply(solve); !strings.Contains(reply, "You unlocked") {
+		t.Fatalf("unlocks weren't shown to the solver: %q", reply)
+	}
+	if ml.balance("alice") == 0 {
+		t.Fatalf("quiet solve wasn't paid")
+	}
+
+	old, err := decode("3BxvKmWMqjKASyDq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := old.clone()
+	solver.activeRobot = solver.robots[solver.activeGoal.id]
+	moves, err := parseMoves(solver.solve(maxArchiveSolveDepth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived := command(ArenaServerID, "arena", "bob", "solve", "moves", formatMoves(moves), "puzzle_id", "3BxvKmWMqjKASyDq")
+	s.handleInteraction(fm, archived)
+	deadline := time.Now().Add(time.Second * 10)
+	for !strings.Contains(fm.reply(archived), "non-active puzzle") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 5)
+	}
+	if reply := fm.reply(archived); !strings.Contains(reply, "Puzzle Solved") || !strings.Contains(reply, "non-active puzzle") {
+		t.Fatalf("archived solve wasn't reported to the solver: %q", reply)
+	}
+
+	for _, substr := range []string{"solved with", "solved non-active", "unlocked"} {
+		if msg, ok := fm.find("arena", substr); ok {
+			t.Fatalf("solve was announced: %q", msg.content)
+		}
+	}
+}
+
+func TestShareCommand(t *testing.T) {
+	puzzles := testPuzzles(t)
+	s, fm := newTestServer(t, puzzles)
+	g := puzzles[0]
+
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "puzzle"))
+
+	early := command(testGuild, testChannel, "alice", "share")
+	s.handleInteraction(fm, early)
+	if reply := fm.reply(early); reply != "You have not solved this puzzle" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "solve", "moves", formatMoves(g.moves)))
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "share"))
+	msg := fm.waitFor(t, testChannel, "<@alice> used **/share**")
+	if !strings.Contains(msg.content, "||") || len(msg.files) != 1 || msg.files[0] != "SPOILER_solution.gif" {
+		t.Fatalf("shared solution isn't hidden: %+v", msg)
+	}
+}
+
+// playTournament runs a 10 minute per round tournament on the fake clock. solve is called once
+// each puzzle is posted. Returns the results message
+func playTournament(t *testing.T, s *server, fm *fakeMessenger, guildID, channelID string, puzzles []*game, solve func(g *game)) fakeMessage {
+	t.Helper()
+	fc := s.clock.(*fakeClock)
+
+	done := make(chan struct{})
+	go func() {
+		s.handleInteraction(fm, command(guildID, channelID, "host", "tournament", "duration", "10"))
+		close(done)
+	}()
+
+	fm.waitFor(t, channelID, "<t:"+fmt.Sprint(fc.Now().Add(tournamentStartTime).Unix())+":R>")
+	fc.waitForTimers(t, 1)
+	fc.Advance(tournamentStartTime)
+
+	for idx, g := range puzzles {
+		fm.waitFor(t, channelID, fmt.Sprintf("**Tournament Puzzle %d: #%s**", idx+1, g.id))
+		solve(g)
+		fc.waitForTimers(t, 1)
+		fc.Advance(time.Minute * 10)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 10):
+		t.Fatalf("tournament did not finish")
+	}
+	return fm.waitFor(t, channelID, "**Tournament Results:**")
+}
+
+func TestTournamentCommand(t *testing.T) {
+	puzzles := testPuzzles(t)
+	s, fm := newTestServer(t, puzzles)
+	s.tournamentLog, _ = loadTournamentLog("")
+	s.unlocks, _ = loadUnlocks("")
+	s.ratings, _ = loadRatings("")
+
+	// alice solves every puzzle optimally, bob only the first
+	results := playTournament(t, s, fm, testGuild, testChannel, puzzles, func(g *game) {
+		s.handleInteraction(fm, command(testGuild, testChannel, "alice", "solve", "moves", formatMoves(g.moves)))
+		if g == puzzles[0] {
+			s.handleInteraction(fm, command(testGuild, testChannel, "bob", "solve", "moves", formatMoves(g.moves)))
+		}
+	})
+
+	total := 0
+	for _, g := range puzzles {
+		total += len(g.moves)
+	}
+	bob := len(puzzles[0].moves) + 60
+	for _, want := range []string{
+		fmt.Sprintf(":first_place:| <@alice> **%d moves**", total),
+		fmt.Sprintf(":second_place:| <@bob> **%d moves**", bob),
+	} {
+		if !strings.Contains(results.content, want) {
+			t.Fatalf("results missing %q:\n%s", want, results.content)
+		}
+	}
+
+	// placings are kept for /stats
+	if r := s.tournamentLog.forUser("bob"); len(r) != 1 || r[0].Place != 2 || r[0].Players != 2 || r[0].Moves != bob {
+		t.Fatalf("unexpected tournament results for bob %+v", r)
+	}
+	if alice, bob := s.ratings.get("alice", s.clk().Now()), s.ratings.get("bob", s.clk().Now()); alice.Rating <= bob.Rating || alice.Games != 1 {
+		t.Fatalf("tournament wasn't rated: alice %+v bob %+v", alice, bob)
+	}
+	fm.waitFor(t, testChannel, ":trophy: <@alice> unlocked **Champion**")
+	if _, ok := fm.find(testChannel, "<@bob> unlocked"); ok {
+		t.Fatalf("bob unlocked an achievement for second place")
+	}
+
+	// the channel is free again
+	if reason := s.instanceFor(command(testGuild, testChannel, "alice", "puzzle")).newPuzzleBlocked(); reason != "" {
+		t.Fatalf("channel still blocked after the tournament: %s", reason)
+	}
+}
+
+func TestTournamentRewards(t *testing.T) {
+	puzzles := testPuzzles(t)
+	for _, g := range puzzles {
+		g.difficulty = MEDIUM
+		g.set = classicSet
+	}
+	s, fm := newTestServer(t, puzzles)
+	ml, _ := loadMemoryLedger("")
+	s.ledger = ml
+	s.unlocks, _ = loadUnlocks("")
+	s.setPrimaryChannel(ArenaServerID, "arena", defaultGuildConfig())
+
+	// alice and carol tie for first, bob is second
+	results := playTournament(t, s, fm, ArenaServerID, "arena", puzzles, func(g *game) {
+		for _, userID := range []string{"alice", "carol"} {
+			s.handleInteraction(fm, command(ArenaServerID, "arena", userID, "solve", "moves", formatMoves(g.moves)))
+		}
+		if g == puzzles[0] {
+			s.handleInteraction(fm, command(ArenaServerID, "arena", "bob", "solve", "moves", formatMoves(g.moves)))
+		}
+	})
+
+	places := defaultRewardPolicy().Tournament
+	want := map[string]int{"alice": places[0], "carol": places[0], "bob": places[1]}
+	for userID, amount := range want {
+		if balance := ml.balance(userID); balance != amount {
+			t.Fatalf("%s was paid %d, expected %d", userID, balance, amount)
+		}
+		if !strings.Contains(results.content, fmt.Sprintf("<@%s> **", userID)) || !strings.Contains(results.content, fmt.Sprintf("+%d <:arena:", amount)) {
+			t.Fatalf("results don't show %s's reward:\n%s", userID, results.content)
+		}
+	}
+
+	// solves during the tournament don't pay out on their own
+	entries, _ := ml.Rewards(time.Time{})
+	for _, entry := range entries {
+		if entry.Type != "tournament" || !strings.HasPrefix(entry.PuzzleID, "tournament-arena-") {
+			t.Fatalf("unexpected ledger entry during a tournament: %+v", entry)
+		}
+	}
+	if _, ok := fm.find("arena", "solved with"); ok {
+		t.Fatalf("solves were announced during the tournament")
+	}
+	if _, ok := fm.find("arena", "unlocked **Optimizer**"); ok {
+		t.Fatalf("achievements gave away a solve during the tournament")
+	}
+	if unlocks := s.unlocks.forUser("bob"); len(unlocks) == 0 || unlocks[0].ID != "first-optimal" {
+		t.Fatalf("bob's optimal solve didn't unlock anything: %+v", unlocks)
+	}
 }
 
 func TestLookForSolutions(t *testing.T) {
 	s := &server{}
-	cat := categorizer{
-		easy:    make(chan (*game), gameBuffer),
-		medium:  make(chan (*game), gameBuffer),
-		hard:    make(chan (*game), gameBuffer),
-		extreme: make(chan (*game), gameBuffer),
-	}
-	s.categorizer = &cat
+	s.ensureCategorizer(classicSet)
 
 	lookForSolutions(s)
 }
@@ -8,7 +8,7 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
-func (s *server) handleShare(dg *discordgo.Session, i *discordgo.InteractionCreate) error {
+func (s *server) handleShare(dg messenger, i *discordgo.InteractionCreate) error {
 	// ack
 	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
@@ -22,8 +22,8 @@ func (s *server) handleShare(dg *discordgo.Session, i *discordgo.InteractionCrea
 	}
 
 	// look up instance
-	instance := s.instances[i.GuildID]
-	game := instance.activeGame
+	instance := s.instanceFor(i)
+	game := instance.game()
 
 	if len(i.Interaction.ApplicationCommandData().Options) == 1 {
 		puzzleID := i.Interaction.ApplicationCommandData().Options[0].Value.(string)
@@ -31,10 +31,10 @@ func (s *server) handleShare(dg *discordgo.Session, i *discordgo.InteractionCrea
 
 		// sanity check, if they provided the ID of the currently active puzzle. Take normal codepath
 		// otherwise handler specifically for old puzzles
-		if instance.activeGame == nil || instance.activeGame.id != puzzleID {
+		if game == nil || game.id != puzzleID {
 			decodedGame, err := decode(strings.TrimPrefix(puzzleID, "#"))
 			if err != nil {
-				content := fmt.Sprintf("Invalid puzzle_id. If you are solving the active puzzle, leave this option blank")
+				content := fmt.Sprintf("Invalid puzzle_id: %v. If you are solving the active puzzle, leave this option blank", err)
 				_, err = dg.InteractionResponseEdit(i.Interaction,
 					&discordgo.WebhookEdit{
 						Content: &content,
@@ -60,7 +60,7 @@ func (s *server) handleShare(dg *discordgo.Session, i *discordgo.InteractionCrea
 		return nil
 	}
 
-	currentMoves := instance.getSolutions(game.id).get(i.Member.User.ID)
+	currentMoves := instance.getSolutions(game.id).get(interactionUserID(i))
 	if len(currentMoves) == 0 {
 		content := "You have not solved this puzzle"
 		_, err = dg.InteractionResponseEdit(i.Interaction,
@@ -76,7 +76,14 @@ func (s *server) handleShare(dg *discordgo.Session, i *discordgo.InteractionCrea
 
 	// build answer string
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("<@%s> used **/share** for puzzle: #%s\n||", i.Member.User.ID, game.id))
+	// solutions are hidden behind spoiler tags unless the guild turned them off
+	spoiler := ""
+	gifName := "solution.gif"
+	if instance.settings().Spoilers {
+		spoiler = "||"
+		gifName = "SPOILER_solution.gif" // spoiler prefix required
+	}
+	sb.WriteString(fmt.Sprintf("<@%s> used **/share** for puzzle: #%s\n%s", interactionUserID(i), game.id, spoiler))
 	for idx, m := range currentMoves {
 		switch m.id {
 		case 'R':
@@ -103,7 +110,7 @@ func (s *server) handleShare(dg *discordgo.Session, i *discordgo.InteractionCrea
 			sb.WriteString(" - ")
 		}
 	}
-	sb.WriteString("||")
+	sb.WriteString(spoiler)
 
 	// render solution to gif form
 	gif, err := renderGif(game, currentMoves)
@@ -111,7 +118,7 @@ func (s *server) handleShare(dg *discordgo.Session, i *discordgo.InteractionCrea
 		return fmt.Errorf("rendering solution gif: %v", err)
 	}
 	file := &discordgo.File{
-		Name:        "SPOILER_solution.gif", // spoiler prefix required
+		Name:        gifName,
 		ContentType: "image/gif",
 		Reader:      &gif,
 	}
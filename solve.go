@@ -4,11 +4,12 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-func (s *server) handleSolve(dg *discordgo.Session, i *discordgo.InteractionCreate) error {
+func (s *server) handleSolve(dg messenger, i *discordgo.InteractionCreate) error {
 	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -39,7 +40,8 @@ func (s *server) handleSolve(dg *discordgo.Session, i *discordgo.InteractionCrea
 	}
 
 	// look up instance
-	instance := s.instances[i.GuildID]
+	instance := s.instanceFor(i)
+	activeGame := instance.game()
 
 	// TODO: think if this wouldn't be better as an entirely separate command
 	// if there is an included ID, try to hydrate the provided puzzle
@@ -48,14 +50,14 @@ func (s *server) handleSolve(dg *discordgo.Session, i *discordgo.InteractionCrea
 		puzzleID = strings.TrimSpace(puzzleID)
 		// sanity check, if they provided the ID of the currently active puzzle. Take normal codepath
 		// otherwise handler specifically for old puzzles
-		if instance.activeGame == nil || instance.activeGame.id != puzzleID {
-			return solveEncodedPuzzle(dg, i, puzzleID, moves, moveStr.(string), instance)
+		if activeGame == nil || (activeGame.id != puzzleID && !idMatchesPuzzle(puzzleID, activeGame)) {
+			return s.solveEncodedPuzzle(dg, i, puzzleID, moves, moveStr.(string), instance)
 		}
 
 	}
 
 	// no active puzzle to solve
-	if instance.activeGame == nil {
+	if activeGame == nil {
 		content := fmt.Sprintf("There is no active puzzle. Please use **/puzzle** to create one")
 		_, err = dg.InteractionResponseEdit(i.Interaction,
 			&discordgo.WebhookEdit{
@@ -65,8 +67,13 @@ func (s *server) handleSolve(dg *discordgo.Session, i *discordgo.InteractionCrea
 		return nil
 	}
 
+	// the lowest bidder must demonstrate while the puzzle is being bid on
+	if b := instance.currentBidding(); b != nil && b.g == activeGame && !b.finished() && i.Interaction.Member != nil {
+		return s.solveBid(dg, i, instance, b, moves)
+	}
+
 	// validate solution
-	success := validate(instance.activeGame, instance.activeGame.board, moves, instance.activeGame.activeGoal)
+	success := validate(activeGame, activeGame.board, moves, activeGame.activeGoal)
 	var content string
 	if success {
 
@@ -77,40 +84,62 @@ func (s *server) handleSolve(dg *discordgo.Session, i *discordgo.InteractionCrea
 			},
 		)
 
-		if i.Interaction.Member == nil {
-			return fmt.Errorf("User invoked solve in a DM? how did this happen?")
-		}
+		userID := interactionUserID(i)
 
 		// extra stuff if on arena server
-		if i.Interaction.GuildID == ArenaServerID {
-			if err := arenaSolution(dg, i.Interaction, instance, s.db, moves); err != nil {
+		elapsed := instance.clk().Since(instance.postedAt())
+		if r := instance.currentRace(); r != nil {
+			r.submit(activeGame, userID, moves)
+		}
+		instance.solvedActiveRound(activeGame, moves)
+		speed := instance.currentSpeed()
+		if speed != nil && speed.g == activeGame {
+			res := speed.submit(userID, moves, instance.clk().Now())
+			content = announceSpeedSolve(dg, i.Interaction, instance, content, res)
+		} else {
+			speed = nil
+		}
+		// tournaments pay out for the final standings instead of each solve
+		if s.rewardPolicy().paysIn(i.Interaction.GuildID) && !activeGame.isCustom() && instance.currentTournament() == nil {
+			reward, err := s.arenaSolution(dg, i.Interaction, instance, activeGame, moves)
+			unlocked := s.recordSolve(instance, userID, activeGame, moves, reward, elapsed)
+			s.announceUnlocks(dg, i.Interaction, instance, userID, content, unlocked)
+			if err != nil {
 				log.Printf("processing arena solution: %v", err)
 				return fmt.Errorf("processing arena solution: %v", err)
 			}
 		} else {
+			unlocked := s.recordSolve(instance, userID, activeGame, moves, 0, elapsed)
 
-			solutions := instance.getSolutions(instance.activeGame.id)
-			bestForUser := len(solutions.get(i.Interaction.Member.User.ID))
+			solutions := instance.getSolutions(activeGame.id)
+			bestForUser := len(solutions.get(userID))
 			if bestForUser == 0 {
 				bestForUser = 999
 			}
 			if len(moves) < bestForUser {
-				solutions.set(i.Interaction.Member.User.ID, moves)
+				solutions.set(userID, moves)
 			}
 
-			// only print solution info if there is not an active tournament
-			if instance.activeTournament == nil {
+			// only print solution info if there is not an active tournament. Speed rounds
+			// already announced the solution with its points
+			if instance.currentTournament() == nil && speed == nil && instance.settings().AnnounceSolves {
 				var content string
-				if len(moves) == instance.activeGame.lenOptimalSolution {
-					content = fmt.Sprintf("<@%s> solved with an :tada:**optimal**:tada: %d move solution", i.Interaction.Member.User.ID, len(moves))
+				if len(moves) == activeGame.lenOptimalSolution {
+					content = fmt.Sprintf("<@%s> solved with an :tada:**optimal**:tada: %d move solution", userID, len(moves))
 				} else {
-					content = fmt.Sprintf("<@%s> solved with a %d move solution", i.Interaction.Member.User.ID, len(moves))
+					content = fmt.Sprintf("<@%s> solved with a %d move solution", userID, len(moves))
 				}
 				dg.ChannelMessageSend(i.Interaction.ChannelID, content)
 			}
+			if instance.practice {
+				stats := practiceStatsFor(s.history.forUser(userID))
+				dg.ChannelMessageSend(instance.channelID, practiceSolveContent(moves, activeGame.lenOptimalSolution, stats))
+			}
+			s.announceUnlocks(dg, i.Interaction, instance, userID, content, unlocked)
 		}
 
 	} else {
+		s.recordFailedSolve(interactionUserID(i), activeGame)
 		content = fmt.Sprintf(":x: %s is not a valid solution to this puzzle", moveStr)
 		_, err = dg.InteractionResponseEdit(i.Interaction,
 			&discordgo.WebhookEdit{
@@ -128,11 +157,11 @@ func (s *server) handleSolve(dg *discordgo.Session, i *discordgo.InteractionCrea
 }
 
 // TODO: clean up params
-func solveEncodedPuzzle(dg *discordgo.Session, i *discordgo.InteractionCreate, puzzleID string, moves []move, moveStr string, instance *discordInstance) error {
+func (s *server) solveEncodedPuzzle(dg messenger, i *discordgo.InteractionCreate, puzzleID string, moves []move, moveStr string, instance *discordInstance) error {
 
 	decodedGame, err := decode(strings.TrimPrefix(puzzleID, "#"))
 	if err != nil {
-		content := fmt.Sprintf("Invalid puzzle_id. If you are solving the active puzzle, leave this option blank")
+		content := fmt.Sprintf("Invalid puzzle_id: %v. If you are solving the active puzzle, leave this option blank", err)
 		_, err = dg.InteractionResponseEdit(i.Interaction,
 			&discordgo.WebhookEdit{
 				Content: &content,
@@ -144,6 +173,15 @@ func solveEncodedPuzzle(dg *discordgo.Session, i *discordgo.InteractionCreate, p
 	success := validate(decodedGame, decodedGame.board, moves, decodedGame.activeGoal)
 	var content string
 	if success {
+		if !s.acquireArchiveSolve() {
+			content = ":x: Too many old puzzles are being scored right now, please try again in a minute"
+			_, err = dg.InteractionResponseEdit(i.Interaction,
+				&discordgo.WebhookEdit{
+					Content: &content,
+				},
+			)
+			return err
+		}
 
 		content = fmt.Sprintf(":white_check_mark: Puzzle Solved: %s", moveStr)
 		_, err = dg.InteractionResponseEdit(i.Interaction,
@@ -152,24 +190,35 @@ func solveEncodedPuzzle(dg *discordgo.Session, i *discordgo.InteractionCreate, p
 			},
 		)
 
-		if i.Interaction.Member == nil {
-			return fmt.Errorf("User invoked solve in a DM? how did this happen?")
-		}
-
-		solutions := instance.getSolutions(puzzleID)
-		bestForUser := len(solutions.get(i.Interaction.Member.User.ID))
+		userID := interactionUserID(i)
+		solutions := instance.getSolutions(decodedGame.id)
+		bestForUser := len(solutions.get(userID))
 		if bestForUser == 0 {
 			bestForUser = 999
 		}
 		if len(moves) < bestForUser {
-			solutions.set(i.Interaction.Member.User.ID, moves)
+			solutions.set(userID, moves)
 		}
 
-		var content string
-		content = fmt.Sprintf("<@%s> solved non-active puzzle #**%s** with a %d move solution. (optimal moves not calculated for old puzzles)", i.Interaction.Member.User.ID, puzzleID, len(moves))
-		dg.ChannelMessageSend(i.Interaction.ChannelID, content)
+		// solving an old puzzle can take a while so report back once the optimal length is known.
+		// Guilds that don't announce solves only see the result in the user's reply
+		reply := content
+		post := func(msg string) error {
+			if instance.settings().AnnounceSolves {
+				_, err := dg.ChannelMessageSend(i.Interaction.ChannelID, msg)
+				return err
+			}
+			reply += "\n" + msg
+			_, err := dg.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &reply})
+			return err
+		}
+		go func() {
+			defer s.releaseArchiveSolve()
+			s.scoreArchivedSolve(post, i.Interaction.GuildID, userID, decodedGame, moves)
+		}()
 
 	} else {
+		s.recordFailedSolve(interactionUserID(i), decodedGame)
 		content = fmt.Sprintf(":x: %s is not a valid solution to puzzle %s", moveStr, puzzleID)
 		_, err = dg.InteractionResponseEdit(i.Interaction,
 			&discordgo.WebhookEdit{
@@ -185,3 +234,35 @@ func solveEncodedPuzzle(dg *discordgo.Session, i *discordgo.InteractionCreate, p
 	return nil
 
 }
+
+// idMatchesPuzzle reports whether id is any spelling of g's puzzle
+func idMatchesPuzzle(id string, g *game) bool {
+	decoded, err := decode(strings.TrimPrefix(id, "#"))
+	return err == nil && puzzleKey(decoded) == puzzleKey(g)
+}
+
+// recordSolve adds a solution to the active puzzle to the user's history and returns the
+// achievements it unlocked
+func (s *server) recordSolve(instance *discordInstance, userID string, g *game, moves []move, reward int, elapsed time.Duration) []achievementRule {
+	sub := submission{
+		UserID:     userID,
+		GuildID:    instance.serverID,
+		ChannelID:  instance.channelID,
+		PuzzleID:   g.id,
+		Moves:      formatMoves(moves),
+		NumMoves:   len(moves),
+		Optimal:    g.lenOptimalSolution,
+		Difficulty: g.difficulty.String(),
+		Reward:     reward,
+		Timestamp:  instance.clk().Now(),
+		ElapsedMs:  elapsed.Milliseconds(),
+	}
+	if err := s.history.record(sub); err != nil {
+		log.Printf("recording solve: %v", err)
+	}
+	// like rewards, the author of a custom puzzle already knows the answer
+	if g.isCustom() {
+		return nil
+	}
+	return s.solveAchievements(sub)
+}
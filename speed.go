@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxSpeedPoints is awarded for an optimal solution submitted the moment the puzzle is posted
+const maxSpeedPoints = 1000
+
+// speedHalfLife is how long it takes for the points a solution is worth to halve
+var speedHalfLife = time.Second * 90
+
+var defaultSpeedDuration = 3
+
+// speedPoints scores a solution by how quickly it was found and how close it is to optimal.
+// Points halve every speedHalfLife and are multiplied by optimal / moves
+func speedPoints(numMoves, optimal int, elapsed time.Duration) int {
+	if numMoves == 0 {
+		return 0
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	closeness := 1.0
+	if optimal > 0 && numMoves > optimal {
+		closeness = float64(optimal) / float64(numMoves)
+	}
+	decay := math.Pow(0.5, elapsed.Seconds()/speedHalfLife.Seconds())
+	return int(math.Round(maxSpeedPoints * decay * closeness))
+}
+
+// speedRound tracks each player's best scoring solution to a timed puzzle
+type speedRound struct {
+	lock    sync.Mutex
+	g       *game
+	posted  time.Time
+	results map[string]speedResult
+}
+
+type speedResult struct {
+	userID  string
+	moves   int
+	elapsed time.Duration
+	points  int
+}
+
+func newSpeedRound(g *game, posted time.Time) *speedRound {
+	return &speedRound{
+		g:       g,
+		posted:  posted,
+		results: make(map[string]speedResult),
+	}
+}
+
+// submit scores a valid solution submitted at the given time. Only the user's highest scoring
+// submission is kept
+func (sr *speedRound) submit(userID string, moves []move, at time.Time) speedResult {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+
+	elapsed := at.Sub(sr.posted)
+	res := speedResult{
+		userID:  userID,
+		moves:   len(moves),
+		elapsed: elapsed,
+		points:  speedPoints(len(moves), sr.g.lenOptimalSolution, elapsed),
+	}
+	if prev, ok := sr.results[userID]; !ok || res.points > prev.points {
+		sr.results[userID] = res
+	}
+	return res
+}
+
+// summary returns every player's best result, highest points first
+func (sr *speedRound) summary() []speedResult {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+
+	var results []speedResult
+	for _, res := range sr.results {
+		results = append(results, res)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].points != results[j].points {
+			return results[i].points > results[j].points
+		}
+		return results[i].elapsed < results[j].elapsed
+	})
+	return results
+}
+
+func formatElapsed(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+func (s *server) handleSpeed(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding speed ack: %v", err)
+	}
+
+	respond := func(content string) error {
+		_, err := dg.InteractionResponseEdit(i.Interaction,
+			&discordgo.WebhookEdit{
+				Content: &content,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("editing speed response: %v", err)
+		}
+		return nil
+	}
+
+	// look up instance
+	instance := s.instanceFor(i)
+	if reason := instance.modeDisabled("speed"); reason != "" {
+		return respond(reason)
+	}
+
+	diff := instance.settings().defaultDifficulty()
+	durationMinutes := defaultSpeedDuration
+	for _, opt := range i.Interaction.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "difficulty":
+			if d := parseDifficulty(opt.StringValue()); d == EASY || d == MEDIUM || d == HARD {
+				diff = d
+			}
+		case "duration":
+			durationMinutes = int(opt.IntValue())
+		}
+	}
+	if durationMinutes < 1 {
+		durationMinutes = 1
+	}
+	if durationMinutes > 10 {
+		durationMinutes = 10
+	}
+	duration := time.Minute * time.Duration(durationMinutes)
+
+	if reason := instance.claimPuzzle(); reason != "" {
+		return respond(reason)
+	}
+
+	s.closeActiveRound(dg, instance)
+	g := s.servePuzzle(instance, diff)
+	round := newSpeedRound(g, instance.clk().Now())
+	// the speed round must be in place before the puzzle can be solved
+	instance.update(func() { instance.activeSpeed = round })
+	instance.showPuzzle(g)
+
+	endSpeed := func() {
+		instance.update(func() { instance.activeSpeed = nil })
+		instance.clearGame(g)
+	}
+
+	content := speedPuzzleContent(i.Interaction.Member, g, round.posted.Add(duration))
+	if err := postPuzzle(dg, instance.channelID, content, g); err != nil {
+		endSpeed()
+		respond(":x: Unable to create puzzle, please try again later")
+		return err
+	}
+	respond("Speed round created")
+
+	instance.clk().Sleep(duration)
+	endSpeed()
+
+	if _, err := dg.ChannelMessageSend(instance.channelID, speedSummaryContent(round, s.playerName)); err != nil {
+		return fmt.Errorf("printing speed results: %v", err)
+	}
+	return nil
+}
+
+func speedPuzzleContent(member *discordgo.Member, g *game, endTime time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s used **/speed**\n", displayName(member)))
+	sb.WriteString(fmt.Sprintf("**Speed Puzzle: #%s** -- %s\n", g.id, g.difficulty))
+	sb.WriteString(fmt.Sprintf("Points halve every **%s** and shrink the further you are from optimal\n", speedHalfLife))
+	sb.WriteString(fmt.Sprintf("Round ends: **<t:%d:R>**", endTime.Unix()))
+	return sb.String()
+}
+
+func speedSummaryContent(round *speedRound, name nameFunc) string {
+	results := round.summary()
+	if len(results) == 0 {
+		return fmt.Sprintf("No one solved speed puzzle #%s :cry:", round.g.id)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Speed Results: #%s** -- optimal is %d moves\n", round.g.id, round.g.lenOptimalSolution))
+	for idx, res := range results {
+		var prefix string
+		switch idx {
+		case 0:
+			prefix = ":first_place:"
+		case 1:
+			prefix = ":second_place:"
+		case 2:
+			prefix = ":third_place:"
+		default:
+			prefix = fmt.Sprintf("%d ", idx+1)
+		}
+		sb.WriteString(fmt.Sprintf("%s| %s **%d points**: %d moves in %s\n", prefix, name(res.userID), res.points, res.moves, formatElapsed(res.elapsed)))
+	}
+	return sb.String()
+}
+
+// announceSpeedSolve posts the points earned by a solution to the active speed puzzle. Guilds that
+// don't announce solves only see the result in the user's reply. Returns the reply
+func announceSpeedSolve(dg messenger, i *discordgo.Interaction, instance *discordInstance, reply string, res speedResult) string {
+	content := fmt.Sprintf("<@%s> solved with a %d move solution in %s for **%d** points", res.userID, res.moves, formatElapsed(res.elapsed), res.points)
+	if instance.settings().AnnounceSolves {
+		if _, err := dg.ChannelMessageSend(i.ChannelID, content); err != nil {
+			log.Printf("announcing speed solve: %v", err)
+		}
+		return reply
+	}
+	reply += "\n" + content
+	if _, err := dg.InteractionResponseEdit(i, &discordgo.WebhookEdit{Content: &reply}); err != nil {
+		log.Printf("showing speed solve: %v", err)
+	}
+	return reply
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpeedPoints(t *testing.T) {
+	tests := []struct {
+		moves, optimal int
+		elapsed        time.Duration
+		points         int
+	}{
+		{8, 8, 0, maxSpeedPoints},
+		{8, 8, speedHalfLife, maxSpeedPoints / 2},
+		{8, 8, 2 * speedHalfLife, maxSpeedPoints / 4},
+		{10, 8, 0, 800},
+		{10, 8, speedHalfLife, 400},
+		{8, 0, 0, maxSpeedPoints}, // unknown optimal only decays with time
+		{8, 8, -time.Second, maxSpeedPoints},
+		{0, 8, 0, 0},
+	}
+	for _, tc := range tests {
+		if got := speedPoints(tc.moves, tc.optimal, tc.elapsed); got != tc.points {
+			t.Fatalf("speedPoints(%d, %d, %s) = %d, expected %d", tc.moves, tc.optimal, tc.elapsed, got, tc.points)
+		}
+	}
+}
+
+func TestSpeedRound(t *testing.T) {
+	g := &game{lenOptimalSolution: 4}
+	posted := time.Now()
+	sr := newSpeedRound(g, posted)
+
+	four := make([]move, 4)
+	five := make([]move, 5)
+
+	sr.submit("slow", four, posted.Add(3*speedHalfLife))
+	sr.submit("fast", five, posted.Add(time.Second))
+	// a later optimal solution that scores less doesn't replace the earlier one
+	res := sr.submit("fast", four, posted.Add(2*speedHalfLife))
+	if res.points != maxSpeedPoints/4 {
+		t.Fatalf("expected submission to score %d, got %d", maxSpeedPoints/4, res.points)
+	}
+
+	summary := sr.summary()
+	if len(summary) != 2 || summary[0].userID != "fast" || summary[0].moves != 5 || summary[1].userID != "slow" {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary[0].elapsed != time.Second {
+		t.Fatalf("expected elapsed time to be recorded, got %s", summary[0].elapsed)
+	}
+}
+
+func TestAnnounceSpeedSolve(t *testing.T) {
+	s, fm := newTestServer(t, nil)
+	config := defaultGuildConfig()
+	config.AnnounceSolves = false
+	s.setPrimaryChannel("quiet-guild", "quiet", config)
+	res := speedResult{userID: "alice", moves: 5, elapsed: time.Second, points: 700}
+
+	// the points stay private when solves aren't announced
+	quiet := command("quiet-guild", "quiet", "alice", "solve", "moves", "RU")
+	reply := announceSpeedSolve(fm, quiet.Interaction, s.instanceFor(quiet), "Puzzle Solved", res)
+	if !strings.Contains(reply, "**700** points") || fm.reply(quiet) != reply {
+		t.Fatalf("points weren't added to the reply: %q", fm.reply(quiet))
+	}
+	if msg, ok := fm.find("quiet", "points"); ok {
+		t.Fatalf("speed solve was announced: %q", msg.content)
+	}
+
+	loud := command(testGuild, testChannel, "alice", "solve", "moves", "RU")
+	if reply := announceSpeedSolve(fm, loud.Interaction, s.instanceFor(loud), "Puzzle Solved", res); reply != "Puzzle Solved" {
+		t.Fatalf("announced points were added to the reply: %q", reply)
+	}
+	fm.waitFor(t, testChannel, "**700** points")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"time"
+)
+
+// The fields of discordInstance describing what is being played are shared between command
+// handlers and the goroutines running rounds, races, speed rounds and tournaments. They must
+// only be read and written through the methods below, which hold instance.lock
+
+func (di *discordInstance) game() *game {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	return di.activeGame
+}
+
+func (di *discordInstance) currentTournament() *tournament {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	return di.activeTournament
+}
+
+func (di *discordInstance) currentRace() *race {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	return di.activeRace
+}
+
+func (di *discordInstance) currentSpeed() *speedRound {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	return di.activeSpeed
+}
+
+func (di *discordInstance) currentBidding() *bidding {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	return di.bidding
+}
+
+func (di *discordInstance) currentRound() *puzzleRound {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	return di.activeRound
+}
+
+// postedAt is when the active puzzle was posted
+func (di *discordInstance) postedAt() time.Time {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	return di.puzzleTimestamp
+}
+
+// gameInProgress reports whether a tournament, race or speed round is running
+func (di *discordInstance) gameInProgress() bool {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	return di.activeTournament != nil || di.activeRace != nil || di.activeSpeed != nil
+}
+
+func (di *discordInstance) settings() guildConfig {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	return di.config
+}
+
+func (di *discordInstance) setConfig(gc guildConfig) {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	di.config = gc
+}
+
+// update runs fn with the lock held. fn must not block or call other methods of di
+func (di *discordInstance) update(fn func()) {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	fn()
+}
+
+// claim checks the channel is free for a new puzzle and runs start before anyone else can
+// check, so two commands can't both take over the channel. Returns why the channel isn't
+// free, or "" once start has run
+func (di *discordInstance) claim(start func()) string {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	if reason := di.newPuzzleBlockedLocked(); reason != "" {
+		return reason
+	}
+	start()
+	return ""
+}
+
+// claimPuzzle reserves the channel while a puzzle is generated. The reservation is released
+// by showPuzzle or releasePuzzle
+func (di *discordInstance) claimPuzzle() string {
+	return di.claim(func() { di.starting = true })
+}
+
+func (di *discordInstance) releasePuzzle() {
+	di.update(func() { di.starting = false })
+}
+
+// showPuzzle makes g the active puzzle
+func (di *discordInstance) showPuzzle(g *game) {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	di.activeGame = g
+	di.puzzleTimestamp = di.clk().Now()
+	di.puzzleIdx += 1
+	di.starting = false
+}
+
+// clearGame removes g as the active puzzle if it still is
+func (di *discordInstance) clearGame(g *game) {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	if di.activeGame == g {
+		di.activeGame = nil
+	}
+}
+
+// biddingFor returns the bidding on the active puzzle, starting it if this is the first bid.
+// Returns nil if there is no active puzzle to bid on
+func (di *discordInstance) biddingFor() (b *bidding, first bool) {
+	di.lock.Lock()
+	defer di.lock.Unlock()
+	if di.activeGame == nil {
+		return nil, false
+	}
+	if di.bidding == nil || di.bidding.g != di.activeGame {
+		di.bidding = newBidding(di.activeGame)
+		return di.bidding, true
+	}
+	return di.bidding, false
+}
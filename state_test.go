@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// these tests are most useful with go test -race
+
+func TestConcurrentClaims(t *testing.T) {
+	instance := &discordInstance{config: defaultGuildConfig()}
+
+	// /puzzle, /speed and /tournament racing to take over an idle channel
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	claimed := 0
+	for x := 0; x < 30; x++ {
+		wg.Add(1)
+		go func(x int) {
+			defer wg.Done()
+			var reason string
+			switch x % 3 {
+			case 0:
+				reason = instance.claimPuzzle()
+			case 1:
+				reason = instance.claim(func() { instance.activeTournament = &tournament{} })
+			case 2:
+				reason = instance.claim(func() { instance.activeSpeed = newSpeedRound(nil, time.Now()) })
+			}
+			if reason == "" {
+				lock.Lock()
+				claimed++
+				lock.Unlock()
+			}
+		}(x)
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Fatalf("%d commands claimed the channel, expected 1", claimed)
+	}
+}
+
+func TestConcurrentPuzzleSolveTournament(t *testing.T) {
+	g, err := decode("3BxvKmWMqjKASyDq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance := &discordInstance{config: defaultGuildConfig()}
+	instance.config.LockoutMinutes = 0
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	// /puzzle replacing the active puzzle
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for x := 0; x < 200; x++ {
+			if instance.claimPuzzle() != "" {
+				continue
+			}
+			cpy := g.clone()
+			cpy.id = fmt.Sprintf("puzzle-%d", x)
+			pr := newPuzzleRound(&cpy)
+			instance.update(func() { instance.activeRound = pr })
+			instance.showPuzzle(&cpy)
+		}
+	}()
+
+	// a tournament taking over the channel and releasing it
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for x := 0; x < 50; x++ {
+			tr := &tournament{}
+			if instance.claim(func() { instance.activeTournament = tr }) != "" {
+				continue
+			}
+			cpy := g.clone()
+			instance.showPuzzle(&cpy)
+			tr.games = append(tr.games, tournamentGame{g: &cpy, id: cpy.id})
+			instance.update(func() {
+				instance.activeTournament = nil
+				instance.activeGame = nil
+			})
+		}
+	}()
+
+	// players solving whatever is active
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			userID := fmt.Sprintf("user-%d", p)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				active := instance.game()
+				if active == nil {
+					continue
+				}
+				_ = time.Since(instance.postedAt())
+				_ = instance.currentTournament() == nil && instance.currentSpeed() == nil && instance.settings().AnnounceSolves
+				instance.getSolutions(active.id).set(userID, nil)
+				instance.solvedActiveRound(active, nil)
+				if b := instance.currentBidding(); b != nil {
+					b.finished()
+				}
+			}
+		}(p)
+	}
+
+	// /bid and /config while everything else is running
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for x := 0; x < 100; x++ {
+			instance.biddingFor()
+			instance.newPuzzleBlocked()
+			gc := instance.settings()
+			instance.setConfig(gc)
+		}
+	}()
+
+	time.Sleep(time.Millisecond * 200)
+	close(done)
+	wg.Wait()
+}
+
+func TestConcurrentInstances(t *testing.T) {
+	s := &server{
+		instances:       make(map[string]*discordInstance),
+		primaryChannels: make(map[string]string),
+	}
+
+	// guilds joining while commands are routed
+	var wg sync.WaitGroup
+	for x := 0; x < 10; x++ {
+		wg.Add(2)
+		guildID := fmt.Sprintf("guild-%d", x)
+		go func() {
+			defer wg.Done()
+			s.setPrimaryChannel(guildID, guildID+"-ricochet", defaultGuildConfig())
+			s.addGameChannel(guildID, guildID+"-hard", defaultGuildConfig())
+		}()
+		go func() {
+			defer wg.Done()
+			s.instanceFor(interactionIn(guildID, guildID+"-hard"))
+			s.instanceFor(interactionIn("", guildID+"-dm"))
+			s.guildInstances(guildID)
+		}()
+	}
+	wg.Wait()
+
+	for x := 0; x < 10; x++ {
+		guildID := fmt.Sprintf("guild-%d", x)
+		if n := len(s.guildInstances(guildID)); n != 2 {
+			t.Fatalf("%s has %d game channels, expected 2", guildID, n)
+		}
+	}
+}
+
+func TestGeneratorSupervisor(t *testing.T) {
+	mini := boardSetByName("mini")
+	if mini == nil {
+		t.Skip("mini board set not loaded")
+	}
+	s := &server{}
+	instance := &discordInstance{config: defaultGuildConfig()}
+	instance.config.BoardSets = []string{"mini"}
+
+	// many /puzzle commands draining the buffer at once share the one generator
+	var wg sync.WaitGroup
+	games := make(chan *game, 2*gameBuffer)
+	for x := 0; x < 2*gameBuffer; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			games <- s.servePuzzle(instance, EASY)
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Minute):
+		t.Fatalf("generator did not keep up with concurrent requests")
+	}
+	close(games)
+
+	seen := make(map[*game]bool)
+	for g := range games {
+		if seen[g] {
+			t.Fatalf("the same puzzle was served twice")
+		}
+		seen[g] = true
+	}
+}
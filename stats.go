@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"golang.org/x/image/draw"
+)
+
+// tournamentResult is where a user placed in a finished tournament
+type tournamentResult struct {
+	TournamentID string    `json:"tournamentId"`
+	GuildID      string    `json:"guildId"`
+	Place        int       `json:"place"`
+	Players      int       `json:"players"`
+	Moves        int       `json:"moves"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// tournamentLog is the persisted record of every user's tournament results keyed by discord user ID
+type tournamentLog struct {
+	path string
+
+	lock    sync.RWMutex
+	results map[string][]tournamentResult
+}
+
+// loadTournamentLog reads the tournament file at path. A missing file is treated as no results
+func loadTournamentLog(path string) (*tournamentLog, error) {
+	tl := &tournamentLog{
+		path:    path,
+		results: make(map[string][]tournamentResult),
+	}
+
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return tl, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading tournament results: %v", err)
+	}
+	if err := json.Unmarshal(buf, &tl.results); err != nil {
+		return nil, fmt.Errorf("parsing tournament results: %v", err)
+	}
+	return tl, nil
+}
+
+// record saves the result of every player in a tournament at once
+func (tl *tournamentLog) record(results map[string]tournamentResult) error {
+	if tl == nil {
+		return nil
+	}
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	for userID, result := range results {
+		tl.results[userID] = append(tl.results[userID], result)
+	}
+	if tl.path == "" {
+		return nil
+	}
+	return writeJSONFile(tl.path, tl.results)
+}
+
+// forUser returns a copy of the user's tournament results, oldest first
+func (tl *tournamentLog) forUser(userID string) []tournamentResult {
+	if tl == nil {
+		return nil
+	}
+	tl.lock.RLock()
+	defer tl.lock.RUnlock()
+
+	results := make([]tournamentResult, len(tl.results[userID]))
+	copy(results, tl.results[userID])
+	return results
+}
+
+// attemptLog is the persisted count of failed solutions each user submitted per puzzle so /stats
+// can count puzzles that were attempted but never solved. Keyed by discord user ID then puzzle key
+type attemptLog struct {
+	path string
+
+	lock   sync.RWMutex
+	failed map[string]map[string]int
+}
+
+// loadAttempts reads the attempts file at path. A missing file is treated as no attempts
+func loadAttempts(path string) (*attemptLog, error) {
+	al := &attemptLog{
+		path:   path,
+		failed: make(map[string]map[string]int),
+	}
+
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return al, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading attempts: %v", err)
+	}
+	if err := json.Unmarshal(buf, &al.failed); err != nil {
+		return nil, fmt.Errorf("parsing attempts: %v", err)
+	}
+	return al, nil
+}
+
+// record counts a failed solution to the puzzle
+func (al *attemptLog) record(userID, key string) error {
+	if al == nil {
+		return nil
+	}
+	al.lock.Lock()
+	defer al.lock.Unlock()
+	if al.failed[userID] == nil {
+		al.failed[userID] = make(map[string]int)
+	}
+	al.failed[userID][key]++
+	if al.path == "" {
+		return nil
+	}
+	return writeJSONFile(al.path, al.failed)
+}
+
+// forUser returns the keys of every puzzle the user submitted a failed solution to
+func (al *attemptLog) forUser(userID string) []string {
+	if al == nil {
+		return nil
+	}
+	al.lock.RLock()
+	defer al.lock.RUnlock()
+
+	var keys []string
+	for key := range al.failed[userID] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// recordFailedSolve notes that the user attempted the puzzle
+func (s *server) recordFailedSolve(userID string, g *game) {
+	if err := s.attempts.record(userID, puzzleKey(g)); err != nil {
+		log.Printf("recording attempt: %v", err)
+	}
+}
+
+// submissionKey is the puzzle key of a recorded submission so it can be matched with attempts
+func submissionKey(sub submission) string {
+	g, err := decode(sub.PuzzleID)
+	if err != nil {
+		return sub.PuzzleID
+	}
+	return puzzleKey(g)
+}
+
+// statsDifficulties are the difficulties broken out by /stats, in display order
+var statsDifficulties = []difficulty{EASY, MEDIUM, HARD, EXTREME}
+
+// number of fastest solves listed by /stats
+const fastestSolves = 3
+
+type difficultyStats struct {
+	solved  int
+	optimal int
+	// known counts solves with a known optimal length, which excess is summed over
+	known  int
+	excess int
+}
+
+func (ds difficultyStats) averageExcess() float64 {
+	if ds.known == 0 {
+		return 0
+	}
+	return float64(ds.excess) / float64(ds.known)
+}
+
+type tournamentRecord struct {
+	played  int
+	wins    int
+	podiums int
+	best    int // best place, 0 if they haven't played
+}
+
+type playerStats struct {
+	submissions int
+	attempted   int // distinct puzzles solved or failed
+	solved      int // distinct puzzles
+	optimal     int
+	known       int // distinct puzzles with a known optimal length
+	byDiff      map[difficulty]difficultyStats
+	fastest     []submission
+	// moves made with each robot across every submission
+	robots      map[byte]int
+	tournaments tournamentRecord
+	// optimal solves in a row in the guild the stats were requested from
+	streak       int
+	achievements []achievementRule
+	rating       rating
+}
+
+// playerStatsFor summarizes a user's submissions, failed attempts and tournament results. Each
+// puzzle only counts once, using the best solution submitted for it
+func playerStatsFor(subs []submission, failed []string, results []tournamentResult, guildID string) playerStats {
+	stats := playerStats{
+		submissions: len(subs),
+		byDiff:      make(map[difficulty]difficultyStats),
+		robots:      make(map[byte]int),
+		streak:      optimalStreak(subs, guildID),
+	}
+
+	best := make(map[string]submission)
+	for _, sub := range subs {
+		prev, ok := best[sub.PuzzleID]
+		if !ok || sub.NumMoves < prev.NumMoves {
+			best[sub.PuzzleID] = sub
+		}
+		// a puzzle is only listed once with its fastest solve
+		if sub.ElapsedMs > 0 {
+			listed := false
+			for idx, fast := range stats.fastest {
+				if fast.PuzzleID == sub.PuzzleID {
+					if sub.ElapsedMs < fast.ElapsedMs {
+						stats.fastest[idx] = sub
+					}
+					listed = true
+					break
+				}
+			}
+			if !listed {
+				stats.fastest = append(stats.fastest, sub)
+			}
+		}
+		moves, err := parseMoves(sub.Moves)
+		if err != nil {
+			continue
+		}
+		for _, m := range moves {
+			stats.robots[m.id]++
+		}
+	}
+
+	attempted := make(map[string]bool)
+	for _, key := range failed {
+		attempted[key] = true
+	}
+	for _, sub := range best {
+		attempted[submissionKey(sub)] = true
+		stats.solved++
+		diff := parseDifficulty(sub.Difficulty)
+		ds := stats.byDiff[diff]
+		ds.solved++
+		if excess := sub.excess(); excess >= 0 {
+			stats.known++
+			ds.known++
+			ds.excess += excess
+			if excess == 0 {
+				stats.optimal++
+				ds.optimal++
+			}
+		}
+		stats.byDiff[diff] = ds
+	}
+
+	stats.attempted = len(attempted)
+
+	sort.SliceStable(stats.fastest, func(a, b int) bool {
+		return stats.fastest[a].ElapsedMs < stats.fastest[b].ElapsedMs
+	})
+	if len(stats.fastest) > fastestSolves {
+		stats.fastest = stats.fastest[:fastestSolves]
+	}
+
+	for _, r := range results {
+		rec := &stats.tournaments
+		rec.played++
+		if r.Place == 1 {
+			rec.wins++
+		}
+		if r.Place <= 3 {
+			rec.podiums++
+		}
+		if rec.best == 0 || r.Place < rec.best {
+			rec.best = r.Place
+		}
+	}
+	return stats
+}
+
+// favoriteRobot is the robot the user has moved the most and how many times they moved it
+func (ps playerStats) favoriteRobot() (byte, int) {
+	var favorite byte
+	most := 0
+	for _, id := range []byte{'R', 'B', 'G', 'Y'} {
+		if ps.robots[id] > most {
+			favorite, most = id, ps.robots[id]
+		}
+	}
+	return favorite, most
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+func statsContent(name string, stats playerStats) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Stats for %s**\n", name))
+	if stats.submissions == 0 {
+		if stats.attempted > 0 {
+			sb.WriteString(fmt.Sprintf("No puzzles solved yet, **%d** attempted", stats.attempted))
+			return sb.String()
+		}
+		sb.WriteString("No puzzles solved yet. Use **/solve** to get started")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("Attempted **%d** puzzles and solved **%d** with **%d** submissions\n", stats.attempted, stats.solved, stats.submissions))
+	sb.WriteString(ratingContent(stats.rating) + "\n")
+	if stats.known > 0 {
+		sb.WriteString(fmt.Sprintf("Optimal: **%d%%** (%d of %d)\n", stats.optimal*100/stats.known, stats.optimal, stats.known))
+	}
+
+	var excess []string
+	for _, diff := range statsDifficulties {
+		if ds := stats.byDiff[diff]; ds.known > 0 {
+			excess = append(excess, fmt.Sprintf("%s **%.1f**", diff, ds.averageExcess()))
+		}
+	}
+	if len(excess) > 0 {
+		sb.WriteString(fmt.Sprintf("Average moves over optimal: %s\n", strings.Join(excess, ", ")))
+	}
+
+	if len(stats.fastest) > 0 {
+		var fastest []string
+		for _, sub := range stats.fastest {
+			elapsed := time.Duration(sub.ElapsedMs) * time.Millisecond
+			fastest = append(fastest, fmt.Sprintf("#%s %d moves in **%s**", sub.PuzzleID, sub.NumMoves, formatElapsed(elapsed)))
+		}
+		sb.WriteString(fmt.Sprintf("Fastest solves: %s\n", strings.Join(fastest, ", ")))
+	}
+
+	if robot, moves := stats.favoriteRobot(); moves > 0 {
+		sb.WriteString(fmt.Sprintf("Favorite robot: **%s** (%d moves)\n", goalColorName(robot), moves))
+	}
+
+	rec := stats.tournaments
+	if rec.played > 0 {
+		sb.WriteString(fmt.Sprintf("Tournaments: **%d** played, **%d** won, **%d** podiums, best finish **%s**\n", rec.played, rec.wins, rec.podiums, ordinal(rec.best)))
+	} else {
+		sb.WriteString("Tournaments: none played\n")
+	}
+	sb.WriteString(fmt.Sprintf("Current optimal streak: **%d**\n", stats.streak))
+	if len(stats.achievements) > 0 {
+		var badges []string
+		for _, rule := range stats.achievements {
+			badges = append(badges, fmt.Sprintf("%s **%s**", rule.Emoji, rule.Name))
+		}
+		sb.WriteString(fmt.Sprintf("Achievements: %s\n", strings.Join(badges, ", ")))
+	} else {
+		sb.WriteString("Achievements: none yet\n")
+	}
+	sb.WriteString("Chart: puzzles solved by difficulty, the bright part of each bar was solved optimally")
+	return sb.String()
+}
+
+// colors of the easy, medium, hard and extreme bars in the stats chart
+var statsChartColors = []color.NRGBA{
+	{0x4c, 0xaf, 0x50, 0xff},
+	{0xff, 0xc1, 0x07, 0xff},
+	{0xf4, 0x43, 0x36, 0xff},
+	{0x9c, 0x27, 0xb0, 0xff},
+}
+
+const (
+	statsChartWidth  = 240
+	statsChartHeight = 120
+	statsChartMargin = 10
+)
+
+// statsChart draws a bar for each difficulty sized by the number of puzzles solved. The optimal
+// solves are drawn at full color and the rest faded
+func statsChart(stats playerStats) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, statsChartWidth, statsChartHeight))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{color.NRGBA{0x2f, 0x31, 0x36, 0xff}}, image.Point{}, draw.Src)
+
+	most := 0
+	for _, diff := range statsDifficulties {
+		if n := stats.byDiff[diff].solved; n > most {
+			most = n
+		}
+	}
+	if most == 0 {
+		return dst
+	}
+
+	slot := (statsChartWidth - 2*statsChartMargin) / len(statsDifficulties)
+	barWidth := slot * 2 / 3
+	maxHeight := statsChartHeight - 2*statsChartMargin
+	bottom := statsChartHeight - statsChartMargin
+	for idx, diff := range statsDifficulties {
+		ds := stats.byDiff[diff]
+		left := statsChartMargin + idx*slot + (slot-barWidth)/2
+		solvedHeight := ds.solved * maxHeight / most
+		optimalHeight := ds.optimal * maxHeight / most
+
+		full := statsChartColors[idx]
+		faded := full
+		faded.A = 0x60
+		draw.Draw(dst, image.Rect(left, bottom-solvedHeight, left+barWidth, bottom), &image.Uniform{faded}, image.Point{}, draw.Over)
+		draw.Draw(dst, image.Rect(left, bottom-optimalHeight, left+barWidth, bottom), &image.Uniform{full}, image.Point{}, draw.Src)
+	}
+	return dst
+}
+
+func (s *server) handleStats(dg messenger, i *discordgo.InteractionCreate) error {
+	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: 1 << 6, // ephemeral
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("responding stats ack: %v", err)
+	}
+
+	userID := interactionUserID(i)
+	for _, opt := range i.Interaction.ApplicationCommandData().Options {
+		if opt.Name == "user" {
+			userID = opt.Value.(string)
+		}
+	}
+
+	stats := playerStatsFor(s.history.forUser(userID), s.attempts.forUser(userID), s.tournamentLog.forUser(userID), i.GuildID)
+	stats.achievements = s.unlockedAchievements(userID)
+	stats.rating = s.ratings.get(userID, s.clk().Now())
+	content := statsContent(s.playerName(userID), stats)
+	edit := &discordgo.WebhookEdit{
+		Content: &content,
+	}
+	if stats.submissions > 0 {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, statsChart(stats)); err != nil {
+			return fmt.Errorf("encoding stats chart: %v", err)
+		}
+		edit.Files = []*discordgo.File{{
+			Name:        "stats.png",
+			ContentType: "image/png",
+			Reader:      &buf,
+		}}
+	}
+	if _, err := dg.InteractionResponseEdit(i.Interaction, edit); err != nil {
+		return fmt.Errorf("sending stats: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlayerStats(t *testing.T) {
+	subs := []submission{
+		{GuildID: "guild", PuzzleID: "a", Moves: "RU-RD-BL", NumMoves: 3, Optimal: 3, Difficulty: "easy", ElapsedMs: 9000},
+		{GuildID: "guild", PuzzleID: "b", Moves: "RU-GD-YL-RR-RU", NumMoves: 5, Optimal: 4, Difficulty: "medium", ElapsedMs: 4000},
+		{GuildID: "guild", PuzzleID: "b", Moves: "RU-GD-RR-RU", NumMoves: 4, Optimal: 4, Difficulty: "medium", ElapsedMs: 7000},
+		{GuildID: "guild", PuzzleID: "c", Moves: "BU-BL-BD-BR-YU-YL-RD", NumMoves: 7, Optimal: 5, Difficulty: "medium", Archived: true},
+		{GuildID: "guild", PuzzleID: "d", Moves: "RU-BL", NumMoves: 2, Optimal: 0, Difficulty: "hard", ElapsedMs: 2000},
+	}
+	results := []tournamentResult{{Place: 2}, {Place: 1}, {Place: 5}}
+
+	// e was attempted but never solved, a failed attempt at b doesn't count twice
+	stats := playerStatsFor(subs, []string{"b", "e"}, results, "guild")
+	if stats.submissions != 5 || stats.attempted != 5 || stats.solved != 4 || stats.optimal != 2 || stats.known != 3 {
+		t.Fatalf("unexpected totals %+v", stats)
+	}
+	if avg := stats.byDiff[MEDIUM].averageExcess(); avg != 1 {
+		t.Fatalf("medium average excess is %v, expected 1", avg)
+	}
+	// b was solved twice but only its fastest solve is listed
+	if len(stats.fastest) != 3 || stats.fastest[0].PuzzleID != "d" || stats.fastest[1].ElapsedMs != 4000 || stats.fastest[2].PuzzleID != "a" {
+		t.Fatalf("unexpected fastest solves %+v", stats.fastest)
+	}
+	if robot, moves := stats.favoriteRobot(); robot != 'R' || moves != 10 {
+		t.Fatalf("favorite robot is %c with %d moves", robot, moves)
+	}
+	if rec := stats.tournaments; rec.played != 3 || rec.wins != 1 || rec.podiums != 2 || rec.best != 1 {
+		t.Fatalf("unexpected tournament record %+v", rec)
+	}
+	// the unknown optimal length ends the streak
+	if stats.streak != 0 {
+		t.Fatalf("expected no streak, got %d", stats.streak)
+	}
+
+	content := statsContent("alice", stats)
+	for _, want := range []string{"Attempted **5** puzzles and solved **4**", "Optimal: **66%**", "medium **1.0**", "Favorite robot: **Red**", "best finish **1st**"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("stats missing %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestStatsChart(t *testing.T) {
+	stats := playerStatsFor([]submission{
+		{PuzzleID: "a", Moves: "RU", NumMoves: 1, Optimal: 1, Difficulty: "easy"},
+		{PuzzleID: "b", Moves: "RU-RD", NumMoves: 2, Optimal: 1, Difficulty: "hard"},
+	}, nil, nil, "")
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, statsChart(stats)); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != statsChartWidth || b.Dy() != statsChartHeight {
+		t.Fatalf("unexpected chart size %v", b)
+	}
+
+	// the easy bar is solid where it was solved optimally, the hard bar is faded
+	bottom := statsChartHeight - statsChartMargin - 1
+	slot := (statsChartWidth - 2*statsChartMargin) / len(statsDifficulties)
+	easy := img.At(statsChartMargin+slot/2, bottom)
+	hard := img.At(statsChartMargin+2*slot+slot/2, bottom)
+	if _, _, _, a := easy.RGBA(); a != 0xffff || easy == hard {
+		t.Fatalf("easy bar %v, hard bar %v", easy, hard)
+	}
+}
+
+func TestStatsCommand(t *testing.T) {
+	puzzles := testPuzzles(t)
+	s, fm := newTestServer(t, puzzles)
+	h, err := loadHistory(filepath.Join(t.TempDir(), "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.history = h
+	s.tournamentLog, _ = loadTournamentLog("")
+	s.attempts, _ = loadAttempts("")
+	g := puzzles[0]
+
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "puzzle"))
+	s.handleInteraction(fm, command(testGuild, testChannel, "alice", "solve", "moves", formatMoves(g.moves)))
+
+	// bob looks up alice
+	stats := command(testGuild, testChannel, "bob", "stats", "user", "alice")
+	s.handleInteraction(fm, stats)
+	reply := fm.reply(stats)
+	if !strings.Contains(reply, "**Stats for <@alice>**") || !strings.Contains(reply, "Current optimal streak: **1**") {
+		t.Fatalf("unexpected stats:\n%s", reply)
+	}
+	fm.lock.Lock()
+	files := fm.replyFiles[stats.Interaction]
+	fm.lock.Unlock()
+	if len(files) != 1 || files[0] != "stats.png" {
+		t.Fatalf("stats sent without the chart: %v", files)
+	}
+
+	// no history yet
+	empty := command(testGuild, testChannel, "bob", "stats")
+	s.handleInteraction(fm, empty)
+	if reply := fm.reply(empty); !strings.Contains(reply, "No puzzles solved yet. Use") {
+		t.Fatalf("unexpected stats for bob:\n%s", reply)
+	}
+
+	// failed solutions count as attempts
+	s.handleInteraction(fm, command(testGuild, testChannel, "bob", "solve", "moves", "RU"))
+	s.handleInteraction(fm, command(testGuild, testChannel, "bob", "solve", "moves", "RD"))
+	failed := command(testGuild, testChannel, "bob", "stats")
+	s.handleInteraction(fm, failed)
+	if reply := fm.reply(failed); !strings.Contains(reply, "No puzzles solved yet, **1** attempted") {
+		t.Fatalf("unexpected stats for bob:\n%s", reply)
+	}
+}
@@ -21,12 +21,13 @@ Any puzzle you do not solve in the time limit will count as **30** moves.
 
 Tournament begins: **<t:%d:R>**`
 
-//var tournamentPuzzleTime = time.Second * 15
 var tournamentStartTime = time.Second * 60
 
 var defaultTournamentDuration = 3
 
 type tournament struct {
+	// id identifies the tournament in the reward ledger
+	id    string
 	games []tournamentGame
 }
 
@@ -36,7 +37,7 @@ type tournamentGame struct {
 	index int
 }
 
-func (s *server) handleTournament(dg *discordgo.Session, i *discordgo.InteractionCreate) error {
+func (s *server) handleTournament(dg messenger, i *discordgo.InteractionCreate) error {
 	err := dg.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -50,7 +51,7 @@ func (s *server) handleTournament(dg *discordgo.Session, i *discordgo.Interactio
 
 	// Parse command options
 	// TODO: difficulty enum
-	difficulty := "medium" // default
+	difficulty := "" // defaults to the guild's configured difficulty
 	var durationMinutes int
 	for _, opt := range i.Interaction.ApplicationCommandData().Options {
 		if opt.Name == "difficulty" {
@@ -89,33 +90,29 @@ func (s *server) handleTournament(dg *discordgo.Session, i *discordgo.Interactio
 	//	fmt.Printf("difficulty: %s, duration: %d\n", difficulty, durationMinutes)
 
 	// look up instance
-	instance := s.instances[i.GuildID]
-
-	// haven't solved current puzzle
-	if instance.activeGame != nil {
-		optimalFound := len(instance.getSolutions(instance.activeGame.id).currentBest()) == instance.activeGame.lenOptimalSolution
-		timePassed := time.Since(instance.puzzleTimestamp) > (time.Second * 60 * 5)
-		if !optimalFound && !timePassed {
-			content := "Current puzzle must be solved optimally or 5 minutes have passed before requesting a new one"
-			dg.InteractionResponseEdit(i.Interaction,
-				&discordgo.WebhookEdit{
-					Content: &content,
-				},
-			)
-			return nil
-		}
+	instance := s.instanceFor(i)
+	if difficulty == "" {
+		difficulty = instance.settings().defaultDifficulty().String()
 	}
-	if instance.activeTournament != nil {
-		content := ":x: There is already an active tournament"
+
+	if reason := instance.modeDisabled("tournament"); reason != "" {
 		dg.InteractionResponseEdit(i.Interaction,
 			&discordgo.WebhookEdit{
-				Content: &content,
+				Content: &reason,
 			},
 		)
 		return nil
 	}
-
-	instance.activeTournament = &tournament{}
+	t := &tournament{id: fmt.Sprintf("tournament-%s-%d", instance.channelID, instance.clk().Now().Unix())}
+	if reason := instance.claim(func() { instance.activeTournament = t }); reason != "" {
+		dg.InteractionResponseEdit(i.Interaction,
+			&discordgo.WebhookEdit{
+				Content: &reason,
+			},
+		)
+		return nil
+	}
+	s.closeActiveRound(dg, instance)
 
 	// serve welcome message
 	var displayName string
@@ -124,9 +121,10 @@ func (s *server) handleTournament(dg *discordgo.Session, i *discordgo.Interactio
 	} else {
 		displayName = i.Interaction.Member.User.Username
 	}
-	tournyText := fmt.Sprintf(tournamentTemplate, displayName, durationMinutes, time.Now().Add(tournamentStartTime).Unix())
+	tournyText := fmt.Sprintf(tournamentTemplate, displayName, durationMinutes, instance.clk().Now().Add(tournamentStartTime).Unix())
 	_, err = dg.ChannelMessageSend(instance.channelID, tournyText)
 	if err != nil {
+		instance.update(func() { instance.activeTournament = nil })
 		content := ":x: Unable to create tournament, please try again later"
 		dg.InteractionResponseEdit(i.Interaction,
 			&discordgo.WebhookEdit{
@@ -144,41 +142,17 @@ func (s *server) handleTournament(dg *discordgo.Session, i *discordgo.Interactio
 		},
 	)
 	if err != nil {
-		fmt.Errorf("tournament success ack: %v", err)
+		log.Printf("tournament success ack: %v", err)
 	}
 
 	// sleep until its time for the puzzles to start
-	time.Sleep(tournamentStartTime)
+	instance.clk().Sleep(tournamentStartTime)
 
 	// serve 3 puzzles one at a time
 	numPuzzles := 3
 	for x := 0; x < numPuzzles; x++ {
-		var g *game
-		/*
-			if x == numPuzzles-1 { // harder puzzle for final
-				// TODO: change both back
-				g = <-s.categorizer.easy
-			} else {
-				g = <-s.categorizer.easy
-			}
-		*/
-		switch difficulty {
-		case "easy":
-			g = <-s.categorizer.easy
-		case "medium":
-			g = <-s.categorizer.medium
-		case "hard":
-			g = <-s.categorizer.hard
-		default:
-			g = <-s.categorizer.medium
-		}
-
-		if !s.isSearching {
-			go lookForSolutions(s)
-		}
-
-		instance.activeGame = g
-		instance.puzzleTimestamp = time.Now()
+		g := s.servePuzzle(instance, parseDifficulty(difficulty))
+		instance.showPuzzle(g)
 
 		var moveStrs []string
 		for _, m := range g.moves {
@@ -188,26 +162,25 @@ func (s *server) handleTournament(dg *discordgo.Session, i *discordgo.Interactio
 
 		img, err := render(g)
 		if err != nil {
-			cancelTournament(dg, instance, instance.activeTournament)
+			cancelTournament(dg, instance, t)
 			return fmt.Errorf("rendering board: %v", err)
 		}
 
 		var buf bytes.Buffer
 		if err := png.Encode(&buf, img); err != nil {
+			cancelTournament(dg, instance, t)
 			return fmt.Errorf("encoding board image: %v", err)
 		}
-		instance.puzzleIdx += 1
-
 		file := &discordgo.File{
 			Name:        "board.png",
 			ContentType: "image/png",
 			Reader:      &buf,
 		}
 
-		tg := tournamentGame{g: instance.activeGame, id: instance.activeGame.id, index: x}
-		instance.activeTournament.games = append(instance.activeTournament.games, tg)
+		tg := tournamentGame{g: g, id: g.id, index: x}
+		t.games = append(t.games, tg)
 		_, err = dg.ChannelMessageSendComplex(instance.channelID, &discordgo.MessageSend{
-			Content: tournamentPuzzleContent(i.Interaction.Member, tg, time.Now().Add(time.Second*60*time.Duration(durationMinutes))),
+			Content: tournamentPuzzleContent(i.Interaction.Member, tg, instance.clk().Now().Add(time.Minute*time.Duration(durationMinutes))),
 			Files:   []*discordgo.File{file},
 		})
 		if err != nil {
@@ -217,21 +190,22 @@ func (s *server) handleTournament(dg *discordgo.Session, i *discordgo.Interactio
 					Content: &content,
 				},
 			)
+			cancelTournament(dg, instance, t)
 			return fmt.Errorf("uploading puzzle to discord: %v", err)
 		}
-		time.Sleep(time.Second * 60 * time.Duration(durationMinutes))
+		instance.clk().Sleep(time.Minute * time.Duration(durationMinutes))
 	}
 
-	endTournament(dg, instance, instance.activeTournament)
-
-	instance.activeTournament = nil
+	s.endTournament(dg, instance, t)
 
 	return nil
 }
 
-func endTournament(dg *discordgo.Session, instance *discordInstance, t *tournament) error {
-	instance.activeTournament = nil
-	instance.activeGame = nil
+func (s *server) endTournament(dg messenger, instance *discordInstance, t *tournament) error {
+	instance.update(func() {
+		instance.activeTournament = nil
+		instance.activeGame = nil
+	})
 
 	// find unique users across all puzzles in tournament
 	userIDs := make(map[string]bool)
@@ -298,7 +272,8 @@ func endTournament(dg *discordgo.Session, instance *discordInstance, t *tourname
 		return tournamentScores[i].total < tournamentScores[j].total
 	})
 
-	// build leaderboard
+	// build leaderboard and record everyone's place
+	results := make(map[string]tournamentResult)
 	var sb strings.Builder
 	sb.WriteString("**Tournament Results:**\n")
 	position := 0
@@ -323,19 +298,66 @@ func endTournament(dg *discordgo.Session, instance *discordInstance, t *tourname
 		}
 
 		// TODO: do this in more robust way to prevent OOB
-		sb.WriteString(fmt.Sprintf("%s| <@%s> **%d moves**:  %d  %d  %d\n", prefix, ts.userID, ts.total, ts.scores[0], ts.scores[1], ts.scores[2]))
+		sb.WriteString(fmt.Sprintf("%s| %s **%d moves**:  %d  %d  %d", prefix, s.playerName(ts.userID), ts.total, ts.scores[0], ts.scores[1], ts.scores[2]))
+		results[ts.userID] = tournamentResult{
+			TournamentID: t.id,
+			GuildID:      instance.serverID,
+			Place:        position,
+			Players:      len(tournamentScores),
+			Moves:        ts.total,
+			Timestamp:    instance.clk().Now(),
+		}
+		if reward := s.tournamentReward(instance, t, ts.userID, position); reward > 0 {
+			sb.WriteString(fmt.Sprintf(" +%d <:arena:917512583160930364>", reward))
+		}
+		sb.WriteString("\n")
 	}
 
+	if err := s.tournamentLog.record(results); err != nil {
+		log.Printf("recording tournament results: %v", err)
+	}
+	s.rateTournament(results)
+
 	// print leaderboard
 	_, err := dg.ChannelMessageSend(instance.channelID, sb.String())
 	if err != nil {
 		return fmt.Errorf("printing leaderboard: %v", err)
 	}
+
+	for _, ts := range tournamentScores {
+		unlocked := s.tournamentAchievements(ts.userID, results[ts.userID])
+		if len(unlocked) == 0 {
+			continue
+		}
+		if _, err := dg.ChannelMessageSend(instance.channelID, unlockContent(s.playerName(ts.userID), unlocked)); err != nil {
+			return fmt.Errorf("announcing achievements: %v", err)
+		}
+	}
 	return nil
 }
 
-func cancelTournament(dg *discordgo.Session, instance *discordInstance, t *tournament) error {
-	instance.activeTournament = nil
+// tournamentReward pays the user for their place in the final standings. Tied users share a
+// place and each earn its reward. Placings are set explicitly by the policy so the daily cap
+// doesn't apply
+func (s *server) tournamentReward(instance *discordInstance, t *tournament, userID string, place int) int {
+	rp := s.rewardPolicy()
+	if !rp.paysIn(instance.serverID) || place > len(rp.Tournament) {
+		return 0
+	}
+	reason := newRewardReason("tournament", instance.serverID, t.id)
+	reason.add(fmt.Sprintf("place-%d", place), rp.Tournament[place-1])
+	paid, err := s.payReward(userID, reason)
+	if err != nil {
+		log.Printf("paying tournament reward: %v", err)
+	}
+	return paid
+}
+
+func cancelTournament(dg messenger, instance *discordInstance, t *tournament) error {
+	instance.update(func() {
+		instance.activeTournament = nil
+		instance.activeGame = nil
+	})
 
 	content := ":x: tournament cancelled due to error, please try again later"
 	_, err := dg.ChannelMessageSend(instance.channelID, content)
@@ -70,3 +70,12 @@ func parseMove(in string) (move, error) {
 	}
 	return m, nil
 }
+
+// formatMoves is the inverse of parseMoves i.e. "RU-GD-BL"
+func formatMoves(moves []move) string {
+	var moveStrs []string
+	for _, m := range moves {
+		moveStrs = append(moveStrs, m.String())
+	}
+	return strings.Join(moveStrs, "-")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"os"
 	"sync"
 	"testing"
 	"time"
@@ -31,7 +32,10 @@ func TestValidate(t *testing.T) {
 •---•---•---•`
 
 	// TODO: redesign parse params
-	g := parseBoard(input, []int{1, 2, 3, 0})
+	g, err := parseBoard(input, []int{1, 2, 3, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
 	printBoard(g.board, g.size, g.robots, g.activeGoal)
 
 	moves, err := parseMoves("GL-BD-BL-RR-RR-RD")
@@ -44,7 +48,10 @@ func TestValidate(t *testing.T) {
 
 func TestBroken(t *testing.T) {
 	// TODO: redesign parse params
-	g := parseBoard(debugBoard, []int{1, 2, 3, 0})
+	g, err := parseBoard(readBoard(t, "testdata/debug.txt"), []int{1, 2, 3, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
 	g.precomputedMoves = g.preCompute(g.activeGoal.position)
 	g.activeRobot = g.robots['B']
 	fmt.Println(printBoard(g.board, g.size, g.robots, g.activeGoal))
@@ -55,6 +62,7 @@ func TestBroken(t *testing.T) {
 }
 
 func TestExtreme(t *testing.T) {
+	extremeBoard := readBoard(t, "boards/extreme/0.txt")
 
 	var wg sync.WaitGroup
 	for x := 0; x < 10; x++ {
@@ -62,7 +70,11 @@ func TestExtreme(t *testing.T) {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				g := parseBoard(extremeBoard, []int{1, 2, 3, 0})
+				g, err := parseBoard(extremeBoard, []int{1, 2, 3, 0})
+				if err != nil {
+					t.Error(err)
+					return
+				}
 				rand.Seed(time.Now().UnixNano())
 				goalIdx := rand.Intn(len(g.goals))
 				g.activeGoal = g.goals[goalIdx]
@@ -135,3 +147,11 @@ func TestRandomGame(t *testing.T) {
 	validate(dec, dec.board, moves, dec.activeGoal)
 
 }
+
+func readBoard(t *testing.T, path string) string {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}